@@ -5,6 +5,8 @@ import (
 	"os"
 
 	"github.com/urfave/cli"
+	"redcellpartners.com/users-posts-api/commands/config"
+	"redcellpartners.com/users-posts-api/commands/rotatekeys"
 	"redcellpartners.com/users-posts-api/commands/start"
 )
 
@@ -17,6 +19,8 @@ func main() {
 	app.Description = "User and Posts REST API"
 	app.Commands = []cli.Command{
 		start.StartCommand(),
+		rotatekeys.RotateKeysCommand(),
+		config.ConfigCommand(),
 	}
 
 	if err = app.Run(os.Args); err != nil {
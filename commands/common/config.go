@@ -0,0 +1,202 @@
+package common
+
+import (
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/BurntSushi/toml"
+	"github.com/urfave/cli"
+	"gopkg.in/yaml.v3"
+)
+
+const REDACTED = "REDACTED"
+
+// secretFlags are never printed with their value, only with the result of
+// their redact function.
+var secretFlags = map[string]func(value string) string{
+	"postgres-password": func(string) string { return REDACTED },
+	"postgres-url":      redactURL,
+}
+
+// ConfigOptions points at an optional YAML or TOML file supplying values for
+// a command's flags. Keys are the flag names, e.g. `postgres-conn-host: db`.
+type ConfigOptions struct {
+	ConfigFile string
+}
+
+func (options *ConfigOptions) Flags() []cli.Flag {
+	return []cli.Flag{
+		cli.StringFlag{
+			Name:        "config",
+			EnvVar:      "CONFIG_FILE",
+			Usage:       "path to a YAML (.yaml, .yml) or TOML (.toml) file with flag values, keyed by flag name",
+			Destination: &options.ConfigFile,
+		},
+	}
+}
+
+// ApplyConfigFile sets every flag of the command that was given neither on
+// the command line nor through its environment variable from the config
+// file, so values resolve as flag > env > file > default. Every problem with
+// the file is returned rather than just the first.
+func (options *ConfigOptions) ApplyConfigFile(cliContext *cli.Context) []error {
+	if options.ConfigFile == "" {
+		return nil
+	}
+
+	values, err := loadConfigFile(options.ConfigFile)
+	if err != nil {
+		return []error{err}
+	}
+
+	flagNames := make(map[string]bool)
+
+	for _, f := range cliContext.Command.Flags {
+		flagNames[flagName(f)] = true
+	}
+
+	keys := make([]string, 0, len(values))
+	for key := range values {
+		keys = append(keys, key)
+	}
+
+	sort.Strings(keys)
+
+	var problems []error
+
+	for _, key := range keys {
+		if !flagNames[key] || key == "config" {
+			problems = append(problems, fmt.Errorf("%s: unknown key %q", options.ConfigFile, key))
+			continue
+		}
+
+		if cliContext.IsSet(key) {
+			continue
+		}
+
+		for _, value := range configValues(values[key]) {
+			if err := cliContext.Set(key, value); err != nil {
+				problems = append(problems, fmt.Errorf("%s: invalid value %q for %q: %s", options.ConfigFile, value, key, err.Error()))
+			}
+		}
+	}
+
+	return problems
+}
+
+func loadConfigFile(path string) (map[string]interface{}, error) {
+	values := make(map[string]interface{})
+
+	contents, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("unable to read config file: %s", err.Error())
+	}
+
+	switch strings.ToLower(filepath.Ext(path)) {
+	case ".yaml", ".yml":
+		err = yaml.Unmarshal(contents, &values)
+	case ".toml":
+		err = toml.Unmarshal(contents, &values)
+	default:
+		return nil, fmt.Errorf("unknown config file extension %q, expected .yaml, .yml or .toml", filepath.Ext(path))
+	}
+
+	if err != nil {
+		return nil, fmt.Errorf("unable to parse config file %s: %s", path, err.Error())
+	}
+
+	return values, nil
+}
+
+// configValues flattens a config value into the strings the flag parses, one
+// per item for lists.
+func configValues(value interface{}) []string {
+	switch typed := value.(type) {
+	case []interface{}:
+		values := make([]string, 0, len(typed))
+		for _, item := range typed {
+			values = append(values, fmt.Sprint(item))
+		}
+
+		return values
+	case nil:
+		return nil
+	default:
+		return []string{fmt.Sprint(typed)}
+	}
+}
+
+// PrintConfig writes the effective value of every flag of the command as a
+// YAML or TOML config file, with secrets redacted.
+func PrintConfig(w io.Writer, cliContext *cli.Context, format string) error {
+	document := &yaml.Node{Kind: yaml.MappingNode}
+	values := make(map[string]interface{})
+
+	for _, f := range cliContext.Command.Flags {
+		name := flagName(f)
+		if name == "config" || name == "format" || name == flagName(cli.HelpFlag) {
+			continue
+		}
+
+		getter, ok := cliContext.Generic(name).(flag.Getter)
+		if !ok {
+			continue
+		}
+
+		value := getter.Get()
+
+		switch typed := value.(type) {
+		case time.Duration:
+			value = typed.String()
+		case string:
+			if redact, ok := secretFlags[name]; ok && typed != "" {
+				value = redact(typed)
+			}
+		}
+
+		valueNode := &yaml.Node{}
+		if err := valueNode.Encode(value); err != nil {
+			return fmt.Errorf("unable to encode %s: %s", name, err.Error())
+		}
+
+		document.Content = append(document.Content, &yaml.Node{Kind: yaml.ScalarNode, Value: name}, valueNode)
+		values[name] = value
+	}
+
+	switch format {
+	case "yaml":
+		encoder := yaml.NewEncoder(w)
+		encoder.SetIndent(2)
+
+		if err := encoder.Encode(document); err != nil {
+			return err
+		}
+
+		return encoder.Close()
+	case "toml":
+		return toml.NewEncoder(w).Encode(values)
+	default:
+		return fmt.Errorf("unknown config format %q, expected yaml or toml", format)
+	}
+}
+
+// ConfigProblems combines the problems found while loading or validating a
+// configuration into a single error.
+func ConfigProblems(problems []error) error {
+	messages := make([]string, 0, len(problems))
+	for _, problem := range problems {
+		messages = append(messages, problem.Error())
+	}
+
+	return fmt.Errorf("invalid configuration: %s", strings.Join(messages, "; "))
+}
+
+func flagName(f cli.Flag) string {
+	return strings.TrimSpace(strings.Split(f.GetName(), ",")[0])
+}
@@ -0,0 +1,42 @@
+package common
+
+import (
+	"fmt"
+
+	"github.com/urfave/cli"
+	"redcellpartners.com/users-posts-api/encryption"
+)
+
+// KeyringOptions points at the keyring used for field level encryption.
+type KeyringOptions struct {
+	KeyringFile string
+}
+
+func (options *KeyringOptions) Flags() []cli.Flag {
+	return []cli.Flag{
+		cli.StringFlag{
+			Name:        "keyring-file",
+			EnvVar:      "KEYRING_FILE",
+			Usage:       "path to the JSON keyring used to encrypt user emails at rest",
+			Destination: &options.KeyringFile,
+		},
+	}
+}
+
+// LoadKeyring returns nil when no keyring file is configured.
+func (options *KeyringOptions) LoadKeyring() (*encryption.Keyring, error) {
+	if options.KeyringFile == "" {
+		return nil, nil
+	}
+
+	return encryption.LoadKeyring(options.KeyringFile)
+}
+
+// Validate returns a problem when the configured keyring cannot be loaded.
+func (options *KeyringOptions) Validate() []error {
+	if _, err := options.LoadKeyring(); err != nil {
+		return []error{fmt.Errorf("--keyring-file: %s", err.Error())}
+	}
+
+	return nil
+}
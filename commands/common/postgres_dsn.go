@@ -0,0 +1,165 @@
+package common
+
+import (
+	"fmt"
+	"net/url"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+const (
+	DEFAULT_POSTGRES_PORT     = 5432
+	DEFAULT_POSTGRES_SSL_MODE = "disable"
+)
+
+// connParams resolves the libpq connection parameters from --postgres-url
+// and the individual settings. A setting fills in what the URL leaves out,
+// but a setting that disagrees with the URL is a conflict rather than an
+// override, so a stale env var can not silently win over a mounted DSN.
+func (options *PostgresOptions) connParams() (map[string]string, []error) {
+	var (
+		params   = make(map[string]string)
+		sources  = make(map[string]string)
+		problems []error
+	)
+
+	if options.PostgresURL != "" {
+		urlParams, err := parsePostgresURL(options.PostgresURL)
+		if err != nil {
+			return nil, []error{err}
+		}
+
+		for key, value := range urlParams {
+			params[key] = value
+			sources[key] = "--postgres-url"
+		}
+	}
+
+	merge := func(key, value, source string) {
+		if value == "" {
+			return
+		}
+
+		if existing, ok := params[key]; ok && existing != value {
+			problems = append(problems, fmt.Errorf("%s conflicts with the %s set by %s", source, key, sources[key]))
+			return
+		}
+
+		params[key] = value
+		sources[key] = source
+	}
+
+	merge("host", options.PostgresHost, "--postgres-conn-host")
+
+	if options.PostgresPort != 0 {
+		merge("port", strconv.Itoa(options.PostgresPort), "--postgres-conn-port")
+	}
+
+	merge("user", options.PostgresUsername, "--postgres-username")
+	merge("dbname", options.PostgresDatabase, "--postgres-database")
+	merge("sslmode", options.PostgresSSLMode, "--postgres-conn-ssl-mode")
+	merge("sslrootcert", options.PostgresSSLRootCert, "--postgres-conn-ssl-root-cert")
+
+	merge("password", options.PostgresPassword, "--postgres-password")
+
+	if options.PostgresPassword != "" && options.PostgresPasswordFile != "" {
+		problems = append(problems, fmt.Errorf("--postgres-password and --postgres-password-file are mutually exclusive"))
+	} else if options.PostgresPasswordFile != "" {
+		password, err := os.ReadFile(options.PostgresPasswordFile)
+		if err != nil {
+			problems = append(problems, fmt.Errorf("unable to read --postgres-password-file: %s", err.Error()))
+		} else {
+			merge("password", strings.TrimRight(string(password), "\r\n"), "--postgres-password-file")
+		}
+	}
+
+	if _, ok := params["port"]; !ok {
+		params["port"] = strconv.Itoa(DEFAULT_POSTGRES_PORT)
+	}
+
+	// without a URL keep the historical default, a URL without sslmode gets
+	// libpq's own default instead of a silent downgrade to disable
+	if _, ok := params["sslmode"]; !ok && options.PostgresURL == "" {
+		params["sslmode"] = DEFAULT_POSTGRES_SSL_MODE
+	}
+
+	return params, problems
+}
+
+// parsePostgresURL converts a postgres:// URL into libpq parameters. Query
+// parameters such as sslmode and sslrootcert are passed through.
+func parsePostgresURL(rawURL string) (map[string]string, error) {
+	parsed, err := url.Parse(rawURL)
+	if err != nil {
+		// the url error quotes the URL, which may hold the password
+		return nil, fmt.Errorf("--postgres-url is not a valid URL")
+	}
+
+	if parsed.Scheme != "postgres" && parsed.Scheme != "postgresql" {
+		return nil, fmt.Errorf("--postgres-url must use the postgres:// or postgresql:// scheme, got %q", parsed.Scheme)
+	}
+
+	params := make(map[string]string)
+
+	if host := parsed.Hostname(); host != "" {
+		params["host"] = host
+	}
+
+	if port := parsed.Port(); port != "" {
+		params["port"] = port
+	}
+
+	if parsed.User != nil {
+		if username := parsed.User.Username(); username != "" {
+			params["user"] = username
+		}
+
+		if password, ok := parsed.User.Password(); ok {
+			params["password"] = password
+		}
+	}
+
+	if database := strings.TrimPrefix(parsed.Path, "/"); database != "" {
+		params["dbname"] = database
+	}
+
+	for key, values := range parsed.Query() {
+		if len(values) > 0 {
+			params[key] = values[len(values)-1]
+		}
+	}
+
+	return params, nil
+}
+
+// connString renders libpq parameters as a key/value connection string,
+// quoting every value so passwords may contain spaces and quotes.
+func connString(params map[string]string) string {
+	keys := make([]string, 0, len(params))
+	for key := range params {
+		keys = append(keys, key)
+	}
+
+	sort.Strings(keys)
+
+	pairs := make([]string, 0, len(keys))
+
+	for _, key := range keys {
+		value := strings.NewReplacer(`\`, `\\`, `'`, `\'`).Replace(params[key])
+		pairs = append(pairs, fmt.Sprintf("%s='%s'", key, value))
+	}
+
+	return strings.Join(pairs, " ")
+}
+
+// redactURL hides the password of a URL valued setting.
+func redactURL(value string) string {
+	parsed, err := url.Parse(value)
+	if err != nil {
+		return REDACTED
+	}
+
+	return parsed.Redacted()
+}
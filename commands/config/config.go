@@ -0,0 +1,39 @@
+package config
+
+import (
+	"github.com/urfave/cli"
+	"redcellpartners.com/users-posts-api/commands/start"
+)
+
+func ConfigCommand() cli.Command {
+	validateRunner := &ConfigRunner{StartRunner: &start.StartRunner{}}
+	printRunner := &ConfigRunner{StartRunner: &start.StartRunner{}}
+
+	printFlags := append(start.StartFlags(printRunner.StartRunner),
+		cli.StringFlag{
+			Name:        "format",
+			Usage:       "output format of the printed config: yaml or toml",
+			Value:       "yaml",
+			Destination: &printRunner.Format,
+		},
+	)
+
+	return cli.Command{
+		Name:        "config",
+		Description: "inspects the configuration start resolves from flags, environment variables and the config file",
+		Subcommands: []cli.Command{
+			{
+				Name:        "validate",
+				Description: "reports every problem with the configuration start would run with",
+				Flags:       start.StartFlags(validateRunner.StartRunner),
+				Action:      validateRunner.Validate,
+			},
+			{
+				Name:        "print",
+				Description: "prints the effective configuration with secrets redacted",
+				Flags:       printFlags,
+				Action:      printRunner.Print,
+			},
+		},
+	}
+}
@@ -0,0 +1,39 @@
+package config
+
+import (
+	"fmt"
+
+	"github.com/urfave/cli"
+	"redcellpartners.com/users-posts-api/commands/common"
+	"redcellpartners.com/users-posts-api/commands/start"
+)
+
+type ConfigRunner struct {
+	*start.StartRunner
+
+	Format string
+}
+
+func (runner *ConfigRunner) Validate(cliContext *cli.Context) error {
+	problems := runner.ApplyConfigFile(cliContext)
+	problems = append(problems, runner.StartRunner.Validate()...)
+
+	if len(problems) == 0 {
+		fmt.Fprintln(cliContext.App.Writer, "configuration is valid")
+		return nil
+	}
+
+	for _, problem := range problems {
+		fmt.Fprintf(cliContext.App.Writer, "- %s\n", problem.Error())
+	}
+
+	return fmt.Errorf("found %d configuration problems", len(problems))
+}
+
+func (runner *ConfigRunner) Print(cliContext *cli.Context) error {
+	if problems := runner.ApplyConfigFile(cliContext); len(problems) > 0 {
+		return common.ConfigProblems(problems)
+	}
+
+	return common.PrintConfig(cliContext.App.Writer, cliContext, runner.Format)
+}
@@ -0,0 +1,35 @@
+package rotatekeys
+
+import (
+	"github.com/urfave/cli"
+)
+
+func RotateKeysCommand() cli.Command {
+	runner := &RotateKeysRunner{}
+
+	flags := []cli.Flag{
+		cli.IntFlag{
+			Name:        "batch-size",
+			EnvVar:      "ROTATE_KEYS_BATCH_SIZE",
+			Usage:       "number of users re-encrypted per transaction",
+			Value:       500,
+			Destination: &runner.BatchSize,
+		},
+		cli.BoolFlag{
+			Name:        "logging-production",
+			EnvVar:      "LOGGING_PRODUCTION",
+			Usage:       "enable logging for a system in production",
+			Destination: &runner.LoggingProduction,
+		},
+	}
+
+	flags = append(flags, runner.PostgresOptions.Flags()...)
+	flags = append(flags, runner.KeyringOptions.Flags()...)
+
+	return cli.Command{
+		Name:        "rotate-keys",
+		Description: "re-encrypts every user email that is in plaintext or not under the keyring's primary key",
+		Flags:       flags,
+		Action:      runner.Run,
+	}
+}
@@ -0,0 +1,90 @@
+package rotatekeys
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"log"
+
+	"github.com/urfave/cli"
+	"go.uber.org/zap"
+	"redcellpartners.com/users-posts-api/commands/common"
+	"redcellpartners.com/users-posts-api/store/postgres"
+)
+
+type RotateKeysRunner struct {
+	common.PostgresOptions
+	common.KeyringOptions
+
+	BatchSize         int
+	LoggingProduction bool
+
+	logger *zap.Logger
+}
+
+func (runner *RotateKeysRunner) Run(cliContext *cli.Context) error {
+	var (
+		err error
+	)
+
+	loggerConfig := zap.NewDevelopmentConfig()
+
+	if runner.LoggingProduction {
+		loggerConfig = zap.NewProductionConfig()
+	}
+
+	runner.logger, err = loggerConfig.Build()
+	if err != nil {
+		log.Fatalf("unable to build zap logger: %s", err.Error())
+	}
+
+	defer runner.logger.Sync()
+
+	if runner.BatchSize < 1 {
+		return fmt.Errorf("batch size must be at least 1")
+	}
+
+	keyring, err := runner.LoadKeyring()
+	if err != nil {
+		return fmt.Errorf("unable to load keyring: %s", err.Error())
+	}
+
+	if keyring == nil {
+		return fmt.Errorf("a keyring file is required to rotate keys")
+	}
+
+	db, err := runner.Connect(runner.logger.Named("postgres"), func(db *sql.DB) error {
+		return postgres.Migrate(db, runner.logger.Named("postgres_migrations"))
+	})
+	if err != nil {
+		return fmt.Errorf("unable to set up postgres database: %s", err.Error())
+	}
+
+	defer db.Close()
+
+	userStore, err := postgres.NewPostgresUserClient(db, keyring, runner.logger.Named("user_postgres_client"))
+	if err != nil {
+		return fmt.Errorf("unable to create new postgres user client: %s", err.Error())
+	}
+
+	total := 0
+
+	for {
+		rotated, err := userStore.RotateEmailKeys(context.Background(), runner.BatchSize)
+		if err != nil {
+			return fmt.Errorf("unable to rotate keys after %d users: %s", total, err.Error())
+		}
+
+		if rotated == 0 {
+			break
+		}
+
+		total += rotated
+
+		runner.logger.Info("rotated batch of user emails", zap.Int("batch", rotated), zap.Int("total", total))
+	}
+
+	runner.logger.Info("finished rotating user email keys", zap.Int("total", total), zap.String("primary_key_id", keyring.PrimaryKeyID()))
+
+	return nil
+}
@@ -0,0 +1,44 @@
+package start
+
+import (
+	"context"
+	"time"
+
+	"go.uber.org/zap"
+	"redcellpartners.com/users-posts-api/store"
+)
+
+// PURGE_BATCH_SIZE is how many deleted rows are purged per transaction.
+const PURGE_BATCH_SIZE = 100
+
+// startPurger permanently deletes users and posts that have been deleted for
+// longer than --purge-retention, every --purge-interval. Replicas skip the
+// rows another replica is purging. The returned function stops it.
+func (runner *StartRunner) startPurger(userStore store.UserStore, postStore store.PostStore) func() {
+	if runner.PurgeInterval == 0 {
+		runner.logger.Info("purging deleted users and posts is disabled on this replica")
+		return func() {}
+	}
+
+	return runner.startPeriodic(store.PurgeActor, runner.PurgeInterval, func(ctx context.Context) {
+		cutoff := time.Now().Add(-runner.PurgeRetention)
+
+		users, err := runBatches(ctx, PURGE_BATCH_SIZE, func(ctx context.Context) (int, error) {
+			return userStore.PurgeDeletedUsers(ctx, cutoff, PURGE_BATCH_SIZE)
+		})
+		if err != nil && ctx.Err() == nil {
+			runner.logger.Error("unable to purge deleted users", zap.Error(err))
+		}
+
+		posts, err := runBatches(ctx, PURGE_BATCH_SIZE, func(ctx context.Context) (int, error) {
+			return postStore.PurgeDeletedPosts(ctx, cutoff, PURGE_BATCH_SIZE)
+		})
+		if err != nil && ctx.Err() == nil {
+			runner.logger.Error("unable to purge deleted posts", zap.Error(err))
+		}
+
+		if users > 0 || posts > 0 {
+			runner.logger.Info("purged deleted users and posts", zap.Int("users", users), zap.Int("posts", posts), zap.Time("deleted_before", cutoff))
+		}
+	})
+}
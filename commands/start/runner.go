@@ -1,37 +1,138 @@
 package start
 
 import (
+	"context"
+	"crypto/tls"
 	"database/sql"
+	"errors"
 	"fmt"
+	"io"
 	"log"
 	"net/http"
+	"os"
+	"os/signal"
+	"strings"
+	"syscall"
 	"time"
 
 	"github.com/go-chi/chi"
 	"github.com/go-chi/chi/middleware"
-	_ "github.com/lib/pq"
 	"github.com/urfave/cli"
+	"go.opentelemetry.io/otel/trace/noop"
 	"go.uber.org/zap"
+	"redcellpartners.com/users-posts-api/commands/common"
+	"redcellpartners.com/users-posts-api/encryption"
+	"redcellpartners.com/users-posts-api/health"
+	"redcellpartners.com/users-posts-api/markdown"
+	"redcellpartners.com/users-posts-api/metrics"
+	apimiddleware "redcellpartners.com/users-posts-api/middleware"
 	"redcellpartners.com/users-posts-api/routes"
+	"redcellpartners.com/users-posts-api/store"
+	"redcellpartners.com/users-posts-api/store/instrumented"
+	"redcellpartners.com/users-posts-api/store/memory"
 	"redcellpartners.com/users-posts-api/store/postgres"
+	"redcellpartners.com/users-posts-api/store/rendered"
+	"redcellpartners.com/users-posts-api/store/substring"
+	"redcellpartners.com/users-posts-api/tracing"
 )
 
-const DEFAULT_TIMEOUT = time.Second * 60
+const (
+	DEFAULT_TIMEOUT = time.Second * 60
+
+	// buckets idle for this long have refilled and can be forgotten
+	RATE_LIMIT_PRUNE_AGE      = time.Hour
+	RATE_LIMIT_PRUNE_INTERVAL = time.Minute * 10
+)
+
+var (
+	DEFAULT_CORS_ALLOWED_METHODS = []string{"GET", "POST", "PUT", "DELETE"}
+	DEFAULT_CORS_ALLOWED_HEADERS = []string{"Content-Type", "Authorization", apimiddleware.APIKeyHeader, apimiddleware.UserIDHeader, apimiddleware.RequestIDHeader}
+	DEFAULT_CORS_EXPOSED_HEADERS = []string{"RateLimit-Limit", "RateLimit-Remaining", "RateLimit-Reset", "RateLimit-Policy", "Retry-After", apimiddleware.RequestIDHeader}
+)
 
 type StartRunner struct {
-	ListenAddr string
+	ListenAddr      string
+	AdminListenAddr string
 
-	PostgresHost     string
-	PostgresPort     int
-	PostgresUsername string
-	PostgresPassword string
-	PostgresDatabase string
-	PostgresSSLMode  string
+	TLSCertFile       string
+	TLSKeyFile        string
+	TLSClientCAFile   string
+	TLSClientAuth     string
+	TLSReloadInterval time.Duration
+
+	common.PostgresOptions
+	common.KeyringOptions
+	common.ConfigOptions
 
 	LoggingProduction bool
 	LoggingLevel      string
 
-	logger *zap.Logger
+	HealthCheckTimeout time.Duration
+
+	CommentMaxDepth int
+
+	PostPublishInterval time.Duration
+	PostRevisionsKept   int
+	PostRenderCacheSize int
+	SearchBackend       string
+
+	PurgeRetention time.Duration
+	PurgeInterval  time.Duration
+
+	SlowQueryThreshold time.Duration
+	SlowQueryExplain   bool
+	SlowQueryInterval  time.Duration
+
+	TracingExporter    string
+	TracingEndpoint    string
+	TracingInsecure    bool
+	TracingSampleRatio float64
+	TracingServiceName string
+
+	RateLimits       cli.StringSlice
+	RateLimitKey     string
+	RateLimitBackend string
+
+	CORSAllowedOrigins   cli.StringSlice
+	CORSAllowedMethods   cli.StringSlice
+	CORSAllowedHeaders   cli.StringSlice
+	CORSExposedHeaders   cli.StringSlice
+	CORSAllowCredentials bool
+	CORSMaxAge           int
+
+	HSTSMaxAge            int
+	HSTSIncludeSubdomains bool
+	ContentSecurityPolicy string
+
+	ShutdownDelay       time.Duration
+	ShutdownGracePeriod time.Duration
+
+	logger        *zap.Logger
+	logLevel      zap.AtomicLevel
+	healthChecker *health.Checker
+	closers       []io.Closer
+
+	keyring       *encryption.Keyring
+	userStore     *postgres.PostgresUserClient
+	postStore     *postgres.PostgresPostClient
+	auditStore    *postgres.PostgresAuditClient
+	commentStore  *postgres.PostgresCommentClient
+	reactionStore *postgres.PostgresReactionClient
+	followStore   *postgres.PostgresFollowClient
+}
+
+// Configure fills in flags from the config file and refuses to start with an
+// invalid configuration.
+func (runner *StartRunner) Configure(cliContext *cli.Context) error {
+	if problems := runner.ApplyConfigFile(cliContext); len(problems) > 0 {
+		return common.ConfigProblems(problems)
+	}
+
+	if problems := runner.Validate(); len(problems) > 0 {
+		return common.ConfigProblems(problems)
+	}
+
+	return nil
 }
 
 func (runner *StartRunner) Run(cliContext *cli.Context) error {
@@ -49,61 +150,483 @@ func (runner *StartRunner) Run(cliContext *cli.Context) error {
 		log.Fatalf("unable to unmarshal zap logging level: %s", err.Error())
 	}
 
+	runner.logLevel = loggerConfig.Level
+
 	runner.logger, err = loggerConfig.Build()
 	if err != nil {
 		log.Fatalf("unable to build zap logger: %s", err.Error())
 	}
 
 	defer func() {
-		if err := runner.logger.Sync(); err != nil {
-			log.Fatalf("error syncing logger: %s", err.Error())
+		// syncing a console (stdout/stderr) is not supported on linux and
+		// reports EINVAL, which is not a lost log line
+		if err := runner.logger.Sync(); err != nil && !errors.Is(err, syscall.EINVAL) && !errors.Is(err, syscall.ENOTTY) {
+			log.Printf("error syncing logger: %s", err.Error())
 		}
 	}()
 
-	// connect to postgres
-	dbConnString := fmt.Sprintf("host=%s port=%d user=%s password=%s dbname=%s sslmode=%s",
-		runner.PostgresHost,
-		runner.PostgresPort,
-		runner.PostgresUsername,
-		runner.PostgresPassword,
-		runner.PostgresDatabase,
-		runner.PostgresSSLMode,
-	)
+	shutdownTracing, err := runner.setupTracing()
+	if err != nil {
+		log.Fatalf("unable to set up tracing: %s", err.Error())
+	}
 
-	runner.logger.Debug("checking db connection string", zap.String("db_connection_str", dbConnString))
+	defer shutdownTracing()
 
-	db, err := sql.Open("postgres", dbConnString)
+	runner.keyring, err = runner.LoadKeyring()
 	if err != nil {
-		log.Fatalf("unable to connect to postgres database: %s", err.Error())
+		log.Fatalf("unable to load keyring: %s", err.Error())
+	}
+
+	if runner.keyring == nil {
+		runner.logger.Warn("no keyring file configured, user emails will be stored in plaintext")
 	}
 
-	userStore, err := postgres.NewPostgresUserClient(db, runner.logger.Named("user_postgres_client"))
+	db, err := runner.Connect(runner.logger.Named("postgres"), runner.setupStores)
 	if err != nil {
-		log.Fatalf("unable to create new postgres user client: %s", err.Error())
+		log.Fatalf("unable to set up postgres database: %s", err.Error())
 	}
 
-	postsStore, err := postgres.NewPostgresPostClient(db, runner.logger.Named("post_postgres_client"))
+	postgres.InstallSlowQueryLog(postgres.NewSlowQueryLog(db, runner.SlowQueryThreshold, runner.SlowQueryExplain, runner.SlowQueryInterval, runner.logger.Named("slow_query_log")))
+
+	apiMetrics := metrics.New()
+
+	if err = apiMetrics.RegisterDB(db, "postgres"); err != nil {
+		log.Fatalf("unable to register db metrics: %s", err.Error())
+	}
+
+	rateLimitMiddleware, stopPruning, err := runner.newRateLimitMiddleware(db)
 	if err != nil {
-		log.Fatalf("unable to create new postgres post client: %s", err.Error())
+		log.Fatalf("unable to create rate limit middleware: %s", err.Error())
 	}
 
+	runner.healthChecker = runner.newHealthChecker(db)
+
 	router := chi.NewRouter()
 
+	router.Use(apimiddleware.TracingMiddleware)
+	router.Use(apimiddleware.RequestIDMiddleware)
+	router.Use(apimiddleware.NewAccessLogMiddleware([]string{"/livez", "/readyz", "/metrics"}, runner.logger.Named("access_log")).AccessLog)
+	router.Use(apimiddleware.NewMetricsMiddleware(apiMetrics).Metrics)
 	router.Use(middleware.Recoverer)
+	router.Use(apimiddleware.ClientIdentityMiddleware)
+	router.Use(apimiddleware.AuditContextMiddleware)
+	router.Use(apimiddleware.ViewerMiddleware)
+	router.Use(runner.newSecurityHeadersMiddleware().SecurityHeaders)
+	router.Use(runner.newCORSMiddleware().CORS)
 	router.Use(middleware.Timeout(DEFAULT_TIMEOUT))
 
-	usersResource := routes.NewUsersResource(userStore, runner.logger.Named("users_resource"))
+	renderer := markdown.NewRenderer(runner.PostRenderCacheSize)
+
+	var searchedPostStore store.PostStore = instrumented.NewPostStore(runner.postStore, apiMetrics)
 
-	postsResource := routes.NewPostsResource(postsStore, runner.logger.Named("posts_resource"))
+	if runner.SearchBackend == "substring" {
+		searchedPostStore = substring.NewPostStore(searchedPostStore)
+	}
 
-	router.Mount("/users", usersResource.Routes())
-	router.Mount("/posts", postsResource.Routes())
+	postStore := rendered.NewPostStore(searchedPostStore, renderer)
 
-	runner.logger.Info("starting users-posts-api REST API server")
+	userStore := instrumented.NewUserStore(runner.userStore, apiMetrics)
+
+	followsResource := routes.NewFollowsResource(instrumented.NewFollowStore(runner.followStore, apiMetrics), postStore, runner.logger.Named("follows_resource"))
+
+	usersResource := routes.NewUsersResource(userStore, followsResource, runner.logger.Named("users_resource"))
+
+	commentsResource := routes.NewCommentsResource(instrumented.NewCommentStore(runner.commentStore, apiMetrics), runner.logger.Named("comments_resource"))
+
+	reactionsResource := routes.NewReactionsResource(instrumented.NewReactionStore(runner.reactionStore, apiMetrics), runner.logger.Named("reactions_resource"))
+
+	revisionsResource := routes.NewRevisionsResource(postStore, runner.logger.Named("revisions_resource"))
+
+	postsResource := routes.NewPostsResource(postStore, renderer, commentsResource, reactionsResource, revisionsResource, runner.logger.Named("posts_resource"))
+
+	tagsResource := routes.NewTagsResource(postStore, runner.logger.Named("tags_resource"))
+
+	stopPublishing := runner.startPostScheduler(postStore)
+
+	stopPurging := runner.startPurger(userStore, postStore)
+
+	router.Mount("/users", rateLimitMiddleware.RateLimit("users")(usersResource.Routes()))
+	router.Mount("/posts", rateLimitMiddleware.RateLimit("posts")(postsResource.Routes()))
+	router.Mount("/comments", rateLimitMiddleware.RateLimit("comments")(commentsResource.Routes()))
+	router.Mount("/tags", rateLimitMiddleware.RateLimit("posts")(tagsResource.Routes()))
+
+	router.Handle("/metrics", apiMetrics.Handler())
+
+	healthResource := routes.NewHealthResource(runner.healthChecker, runner.logger.Named("health_resource"))
+
+	router.Get("/livez", healthResource.Livez)
+	router.Get("/readyz", healthResource.Readyz)
+	router.Get("/healthz", healthResource.Healthz)
 
-	if err = http.ListenAndServe(runner.ListenAddr, router); err != nil {
+	server := &http.Server{
+		Addr:    runner.ListenAddr,
+		Handler: router,
+	}
+
+	stopWatching := func() {}
+
+	if runner.TLSCertFile != "" || runner.TLSKeyFile != "" {
+		if server.TLSConfig, stopWatching, err = runner.newTLSConfig(); err != nil {
+			log.Fatalf("unable to configure tls: %s", err.Error())
+		}
+	}
+
+	signals := make(chan os.Signal, 1)
+	signal.Notify(signals, syscall.SIGINT, syscall.SIGTERM)
+
+	serverErrors := make(chan error, 2)
+
+	go func() {
+		serverErrors <- runner.serve(server)
+	}()
+
+	adminServer := runner.newAdminServer(usersResource, postsResource)
+
+	if adminServer != nil {
+		go func() {
+			runner.logger.Info("starting admin server", zap.String("addr", adminServer.Addr))
+			serverErrors <- fmt.Errorf("admin server: %s", adminServer.ListenAndServe().Error())
+		}()
+	}
+
+	select {
+	case err = <-serverErrors:
 		runner.logger.Error("error listening and serving user posts router", zap.Error(err))
+	case sig := <-signals:
+		runner.logger.Info("received signal, shutting down", zap.String("signal", sig.String()))
+		runner.shutdown(server)
+	}
+
+	stopWatching()
+
+	if adminServer != nil {
+		adminServer.Close()
+	}
+
+	stopPublishing()
+	stopPurging()
+	stopPruning()
+
+	runner.closeStores(db)
+
+	return nil
+}
+
+func (runner *StartRunner) serve(server *http.Server) error {
+	if server.TLSConfig != nil {
+		runner.logger.Info("starting users-posts-api REST API server with tls", zap.Bool("mutual_tls", runner.TLSClientCAFile != ""))
+
+		return server.ListenAndServeTLS("", "")
+	}
+
+	runner.logger.Info("starting users-posts-api REST API server")
+
+	return server.ListenAndServe()
+}
+
+// shutdown fails readiness, gives load balancers time to stop routing new
+// requests here and then drains in-flight requests within the grace period.
+func (runner *StartRunner) shutdown(server *http.Server) {
+	runner.healthChecker.SetShuttingDown()
+
+	if runner.ShutdownDelay > 0 {
+		runner.logger.Info("waiting for load balancers to observe failing readiness", zap.Duration("delay", runner.ShutdownDelay))
+		time.Sleep(runner.ShutdownDelay)
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), runner.ShutdownGracePeriod)
+	defer cancel()
+
+	if err := server.Shutdown(ctx); err != nil {
+		runner.logger.Error("unable to drain in-flight requests within the grace period", zap.Duration("grace_period", runner.ShutdownGracePeriod), zap.Error(err))
+
+		server.Close()
+		return
+	}
+
+	runner.logger.Info("drained in-flight requests")
+}
+
+// setupStores migrates the database and prepares the store clients. It is
+// retried by Connect, so clients left over from a failed attempt are closed
+// first.
+func (runner *StartRunner) setupStores(db *sql.DB) error {
+	runner.closeClients()
+
+	if err := postgres.Migrate(db, runner.logger.Named("postgres_migrations")); err != nil {
+		return fmt.Errorf("unable to migrate postgres database: %s", err.Error())
+	}
+
+	var err error
+
+	runner.userStore, err = postgres.NewPostgresUserClient(db, runner.keyring, runner.logger.Named("user_postgres_client"))
+	if err != nil {
+		return fmt.Errorf("unable to create new postgres user client: %s", err.Error())
+	}
+
+	runner.closers = append(runner.closers, runner.userStore)
+
+	if runner.keyring != nil {
+		hashed, err := runner.userStore.BackfillEmailHashes(context.Background())
+		if err != nil {
+			return fmt.Errorf("unable to backfill user email hashes: %s", err.Error())
+		}
+
+		if hashed > 0 {
+			runner.logger.Info("hashed plaintext user emails", zap.Int("count", hashed))
+		}
 	}
 
+	runner.postStore, err = postgres.NewPostgresPostClient(db, runner.PostRevisionsKept, runner.logger.Named("post_postgres_client"))
+	if err != nil {
+		return fmt.Errorf("unable to create new postgres post client: %s", err.Error())
+	}
+
+	runner.closers = append(runner.closers, runner.postStore)
+
+	runner.auditStore, err = postgres.NewPostgresAuditClient(db, runner.logger.Named("audit_postgres_client"))
+	if err != nil {
+		return fmt.Errorf("unable to create new postgres audit client: %s", err.Error())
+	}
+
+	runner.closers = append(runner.closers, runner.auditStore)
+
+	runner.commentStore, err = postgres.NewPostgresCommentClient(db, runner.CommentMaxDepth, runner.logger.Named("comment_postgres_client"))
+	if err != nil {
+		return fmt.Errorf("unable to create new postgres comment client: %s", err.Error())
+	}
+
+	runner.closers = append(runner.closers, runner.commentStore)
+
+	runner.reactionStore, err = postgres.NewPostgresReactionClient(db, runner.logger.Named("reaction_postgres_client"))
+	if err != nil {
+		return fmt.Errorf("unable to create new postgres reaction client: %s", err.Error())
+	}
+
+	runner.closers = append(runner.closers, runner.reactionStore)
+
+	runner.followStore, err = postgres.NewPostgresFollowClient(db, runner.logger.Named("follow_postgres_client"))
+	if err != nil {
+		return fmt.Errorf("unable to create new postgres follow client: %s", err.Error())
+	}
+
+	runner.closers = append(runner.closers, runner.followStore)
+
 	return nil
 }
+
+// closeClients closes the store clients' prepared statements, newest first.
+func (runner *StartRunner) closeClients() {
+	for i := len(runner.closers) - 1; i >= 0; i-- {
+		if err := runner.closers[i].Close(); err != nil {
+			runner.logger.Warn("unable to close store client", zap.Error(err))
+		}
+	}
+
+	runner.closers = nil
+}
+
+// closeStores closes the store clients and then the database pool.
+func (runner *StartRunner) closeStores(db *sql.DB) {
+	runner.closeClients()
+
+	if err := db.Close(); err != nil {
+		runner.logger.Warn("unable to close postgres database", zap.Error(err))
+	}
+}
+
+// newAdminServer returns the internal server for profiling, build info, log
+// level changes, the audit log and deleted users and posts, or nil when
+// --admin-listen-addr is empty. It has its own router so none of its
+// endpoints are reachable through the public listener.
+func (runner *StartRunner) newAdminServer(usersResource *routes.UsersResource, postsResource *routes.PostsResource) *http.Server {
+	if runner.AdminListenAddr == "" {
+		return nil
+	}
+
+	router := chi.NewRouter()
+
+	router.Use(apimiddleware.RequestIDMiddleware)
+	router.Use(middleware.Recoverer)
+	router.Use(apimiddleware.NewAccessLogMiddleware(nil, runner.logger.Named("admin_access_log")).AccessLog)
+	router.Use(apimiddleware.AdminAuditContextMiddleware)
+
+	adminResource := routes.NewAdminResource(runner.logLevel, runner.logger.Named("admin_resource"))
+	auditResource := routes.NewAuditResource(runner.auditStore, runner.logger.Named("audit_resource"))
+
+	router.Mount("/audit", auditResource.Routes())
+	router.Mount("/users", usersResource.AdminRoutes())
+	router.Mount("/posts", postsResource.AdminRoutes())
+	router.Mount("/", adminResource.Routes())
+
+	return &http.Server{
+		Addr:    runner.AdminListenAddr,
+		Handler: router,
+	}
+}
+
+// newTLSConfig returns the server tls config and a function that stops
+// watching the certificate files for changes.
+func (runner *StartRunner) newTLSConfig() (*tls.Config, func(), error) {
+	if runner.TLSCertFile == "" || runner.TLSKeyFile == "" {
+		return nil, nil, fmt.Errorf("both --tls-cert and --tls-key must be set to serve tls")
+	}
+
+	clientAuth := tls.NoClientCert
+
+	if runner.TLSClientCAFile != "" {
+		var err error
+
+		clientAuth, err = parseClientAuth(runner.TLSClientAuth)
+		if err != nil {
+			return nil, nil, err
+		}
+	}
+
+	reloader, err := newCertificateReloader(runner.TLSCertFile, runner.TLSKeyFile, runner.TLSClientCAFile, clientAuth, runner.logger.Named("tls_reloader"))
+	if err != nil {
+		return nil, nil, err
+	}
+
+	if runner.TLSReloadInterval <= 0 {
+		return reloader.tlsConfig(), func() {}, nil
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan struct{})
+
+	go func() {
+		defer close(done)
+		reloader.watch(ctx, runner.TLSReloadInterval)
+	}()
+
+	return reloader.tlsConfig(), func() {
+		cancel()
+		<-done
+	}, nil
+}
+
+type rateLimitStore interface {
+	store.RateLimitStore
+	Prune(ctx context.Context, olderThan time.Duration)
+}
+
+// newRateLimitMiddleware returns the rate limit middleware and the function
+// that stops pruning its idle buckets.
+func (runner *StartRunner) newRateLimitMiddleware(db *sql.DB) (*apimiddleware.RateLimitMiddleware, func(), error) {
+	limits, err := apimiddleware.ParseRateLimits(runner.RateLimits.Value())
+	if err != nil {
+		return nil, nil, err
+	}
+
+	keyFunc, err := apimiddleware.RateLimitKeyFuncFromName(runner.RateLimitKey)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	var limitStore rateLimitStore
+
+	switch runner.RateLimitBackend {
+	case "memory":
+		limitStore = memory.NewMemoryRateLimitClient()
+	case "postgres":
+		postgresStore, err := postgres.NewPostgresRateLimitClient(db, runner.logger.Named("rate_limit_postgres_client"))
+		if err != nil {
+			return nil, nil, err
+		}
+
+		runner.closers = append(runner.closers, postgresStore)
+		limitStore = postgresStore
+	default:
+		return nil, nil, fmt.Errorf("unknown rate limit backend %q, expected memory or postgres", runner.RateLimitBackend)
+	}
+
+	stopPruning := runner.startPeriodic(store.AnonymousActor, RATE_LIMIT_PRUNE_INTERVAL, func(ctx context.Context) {
+		limitStore.Prune(ctx, RATE_LIMIT_PRUNE_AGE)
+	})
+
+	return apimiddleware.NewRateLimitMiddleware(limitStore, limits, keyFunc, runner.logger.Named("rate_limit_middleware")), stopPruning, nil
+}
+
+func (runner *StartRunner) newCORSMiddleware() *apimiddleware.CORSMiddleware {
+	return apimiddleware.NewCORSMiddleware(apimiddleware.CORSOptions{
+		AllowedOrigins:   runner.CORSAllowedOrigins.Value(),
+		AllowedMethods:   valuesOrDefault(runner.CORSAllowedMethods.Value(), DEFAULT_CORS_ALLOWED_METHODS),
+		AllowedHeaders:   valuesOrDefault(runner.CORSAllowedHeaders.Value(), DEFAULT_CORS_ALLOWED_HEADERS),
+		ExposedHeaders:   valuesOrDefault(runner.CORSExposedHeaders.Value(), DEFAULT_CORS_EXPOSED_HEADERS),
+		AllowCredentials: runner.CORSAllowCredentials,
+		MaxAge:           runner.CORSMaxAge,
+	})
+}
+
+func (runner *StartRunner) newSecurityHeadersMiddleware() *apimiddleware.SecurityHeadersMiddleware {
+	return apimiddleware.NewSecurityHeadersMiddleware(apimiddleware.SecurityHeadersOptions{
+		HSTSMaxAge:            runner.HSTSMaxAge,
+		HSTSIncludeSubdomains: runner.HSTSIncludeSubdomains,
+		ContentTypeNosniff:    true,
+		FrameOptions:          "DENY",
+		ReferrerPolicy:        "no-referrer",
+		ContentSecurityPolicy: runner.ContentSecurityPolicy,
+	})
+}
+
+// valuesOrDefault works around urfave/cli appending flag values onto a
+// slice flag's default instead of replacing it.
+func valuesOrDefault(values, defaults []string) []string {
+	if len(values) == 0 {
+		return defaults
+	}
+
+	return values
+}
+
+// setupTracing installs the global tracer provider and returns the function
+// that flushes buffered spans on shutdown.
+func (runner *StartRunner) setupTracing() (func(), error) {
+	switch runner.TracingExporter {
+	case tracing.ExporterNone:
+		tracing.Install(noop.NewTracerProvider())
+		return func() {}, nil
+	case tracing.ExporterOTLP:
+	default:
+		return nil, fmt.Errorf("unknown tracing exporter %q, expected none or otlp", runner.TracingExporter)
+	}
+
+	processor, err := tracing.NewOTLPProcessor(context.Background(), runner.TracingEndpoint, runner.TracingInsecure)
+	if err != nil {
+		return nil, err
+	}
+
+	provider := tracing.NewTracerProvider(processor, runner.TracingServiceName, runner.TracingSampleRatio)
+
+	tracing.Install(provider)
+
+	return func() {
+		ctx, cancel := context.WithTimeout(context.Background(), time.Second*5)
+		defer cancel()
+
+		if err := provider.Shutdown(ctx); err != nil {
+			runner.logger.Warn("unable to flush traces", zap.Error(err))
+		}
+	}, nil
+}
+
+func (runner *StartRunner) newHealthChecker(db *sql.DB) *health.Checker {
+	checker := health.NewChecker(runner.HealthCheckTimeout)
+
+	checker.AddCheck("postgres", db.PingContext)
+	checker.AddCheck("migrations", func(ctx context.Context) error {
+		pending, err := postgres.PendingMigrations(ctx, db)
+		if err != nil {
+			return err
+		}
+
+		if len(pending) > 0 {
+			return fmt.Errorf("%d migrations pending: %s", len(pending), strings.Join(pending, ", "))
+		}
+
+		return nil
+	})
+
+	return checker
+}
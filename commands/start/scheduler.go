@@ -0,0 +1,87 @@
+package start
+
+import (
+	"context"
+	"time"
+
+	"go.uber.org/zap"
+	"redcellpartners.com/users-posts-api/store"
+)
+
+// SCHEDULED_POSTS_BATCH_SIZE is how many due posts are published per
+// transaction, batches repeat until no due post is left.
+const SCHEDULED_POSTS_BATCH_SIZE = 100
+
+// startPostScheduler publishes scheduled posts as they come due, every
+// --post-publish-interval. Every replica runs it; the store makes sure a post
+// is published by exactly one of them. The returned function stops it and
+// waits for a running batch to finish.
+func (runner *StartRunner) startPostScheduler(postStore store.PostStore) func() {
+	if runner.PostPublishInterval == 0 {
+		runner.logger.Info("publishing scheduled posts is disabled on this replica")
+		return func() {}
+	}
+
+	return runner.startPeriodic(store.SchedulerActor, runner.PostPublishInterval, func(ctx context.Context) {
+		runner.publishDuePosts(ctx, postStore)
+	})
+}
+
+func (runner *StartRunner) publishDuePosts(ctx context.Context, postStore store.PostStore) {
+	published, err := runBatches(ctx, SCHEDULED_POSTS_BATCH_SIZE, func(ctx context.Context) (int, error) {
+		return postStore.PublishScheduledPosts(ctx, time.Now(), SCHEDULED_POSTS_BATCH_SIZE)
+	})
+	if err != nil && ctx.Err() == nil {
+		runner.logger.Error("unable to publish scheduled posts", zap.Error(err))
+	}
+
+	if published > 0 {
+		runner.logger.Info("published scheduled posts", zap.Int("count", published))
+	}
+}
+
+// startPeriodic calls run every interval, with a context carrying actor for
+// the audit log, until the returned function is called. That function waits
+// for a running call to return.
+func (runner *StartRunner) startPeriodic(actor string, interval time.Duration, run func(ctx context.Context)) func() {
+	ctx, cancel := context.WithCancel(store.WithActor(context.Background(), actor))
+	done := make(chan struct{})
+
+	go func() {
+		defer close(done)
+
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case <-ticker.C:
+				run(ctx)
+			}
+		}
+	}()
+
+	return func() {
+		cancel()
+		<-done
+	}
+}
+
+// runBatches calls batch until it handles fewer than batchSize rows, fails or
+// the context is done, and returns the number of rows handled in total.
+func runBatches(ctx context.Context, batchSize int, batch func(ctx context.Context) (int, error)) (int, error) {
+	total := 0
+
+	for ctx.Err() == nil {
+		handled, err := batch(ctx)
+		total += handled
+
+		if err != nil || handled < batchSize {
+			return total, err
+		}
+	}
+
+	return total, ctx.Err()
+}
@@ -1,6 +1,10 @@
 package start
 
-import "github.com/urfave/cli"
+import (
+	"time"
+
+	"github.com/urfave/cli"
+)
 
 func StartCommand() cli.Command {
 	runner := &StartRunner{}
@@ -8,65 +12,280 @@ func StartCommand() cli.Command {
 	return cli.Command{
 		Name:        "start",
 		Description: "starts the users and posts REST api",
-		Flags: []cli.Flag{
-			cli.StringFlag{
-				Name:        "listen-addr",
-				EnvVar:      "LISTEN_ADDR",
-				Usage:       "address that the server will listen on",
-				Value:       ":8080",
-				Destination: &runner.ListenAddr,
-			},
-			cli.BoolFlag{
-				Name:        "logging-production",
-				EnvVar:      "LOGGING_PRODUCTION",
-				Usage:       "enable logging for a system in production",
-				Destination: &runner.LoggingProduction,
-			},
-			cli.StringFlag{
-				Name:        "loggging-level",
-				EnvVar:      "LOGGING_LEVEL",
-				Usage:       "sets the loggging level of all logged messages",
-				Destination: &runner.LoggingLevel,
-			},
-			cli.StringFlag{
-				Name:        "postgres-username",
-				EnvVar:      "POSTGRES_CONN_USERNAME",
-				Usage:       "username for the postgres connection",
-				Destination: &runner.PostgresUsername,
-			},
-			cli.StringFlag{
-				Name:        "postgres-password",
-				EnvVar:      "POSTGRES_CONN_PASSWORD",
-				Usage:       "password for the postgres connection",
-				Destination: &runner.PostgresPassword,
-			},
-			cli.StringFlag{
-				Name:        "postgres-database",
-				EnvVar:      "POSTGRES_CONN_DATABASE",
-				Usage:       "database for the postgres connection",
-				Destination: &runner.PostgresDatabase,
-			},
-			cli.StringFlag{
-				Name:        "postgres-conn-host",
-				EnvVar:      "POSTGRES_CONN_HOST",
-				Usage:       "hostname of the postgres database",
-				Destination: &runner.PostgresHost,
-			},
-			cli.IntFlag{
-				Name:        "postgres-conn-port",
-				EnvVar:      "POSTGRES_CONN_PORT",
-				Usage:       "port of the postgres database",
-				Destination: &runner.PostgresPort,
-				Value:       5432,
-			},
-			cli.StringFlag{
-				Name:        "postgres-conn-ssl-mode",
-				EnvVar:      "POSTGRES_CONN_SSL_MODE",
-				Usage:       "ssl mode of the postgres database",
-				Destination: &runner.PostgresSSLMode,
-				Value:       "disable",
-			},
-		},
-		Action: runner.Run,
+		Flags:       StartFlags(runner),
+		Before:      runner.Configure,
+		Action:      runner.Run,
 	}
 }
+
+// StartFlags returns the flags of the start command bound to the runner, so
+// the config commands resolve exactly the configuration start would.
+func StartFlags(runner *StartRunner) []cli.Flag {
+	flags := []cli.Flag{
+		cli.StringFlag{
+			Name:        "listen-addr",
+			EnvVar:      "LISTEN_ADDR",
+			Usage:       "address that the server will listen on",
+			Value:       ":8080",
+			Destination: &runner.ListenAddr,
+		},
+		cli.StringFlag{
+			Name:        "admin-listen-addr",
+			EnvVar:      "ADMIN_LISTEN_ADDR",
+			Usage:       "internal address serving pprof, build info and runtime log level changes, empty disables it, never expose it publicly",
+			Value:       "127.0.0.1:6060",
+			Destination: &runner.AdminListenAddr,
+		},
+		cli.StringFlag{
+			Name:        "tls-cert",
+			EnvVar:      "TLS_CERT_FILE",
+			Usage:       "path to the PEM encoded certificate to serve https with",
+			Destination: &runner.TLSCertFile,
+		},
+		cli.StringFlag{
+			Name:        "tls-key",
+			EnvVar:      "TLS_KEY_FILE",
+			Usage:       "path to the PEM encoded private key for --tls-cert",
+			Destination: &runner.TLSKeyFile,
+		},
+		cli.StringFlag{
+			Name:        "tls-client-ca",
+			EnvVar:      "TLS_CLIENT_CA_FILE",
+			Usage:       "path to the PEM encoded CA bundle used to verify client certificates, enables mutual tls",
+			Destination: &runner.TLSClientCAFile,
+		},
+		cli.StringFlag{
+			Name:        "tls-client-auth",
+			EnvVar:      "TLS_CLIENT_AUTH",
+			Usage:       "client certificate policy when --tls-client-ca is set: require or verify-if-given",
+			Value:       "require",
+			Destination: &runner.TLSClientAuth,
+		},
+		cli.DurationFlag{
+			Name:        "tls-reload-interval",
+			EnvVar:      "TLS_RELOAD_INTERVAL",
+			Usage:       "how often the tls files are checked for changes, 0 disables reloading",
+			Value:       time.Second * 30,
+			Destination: &runner.TLSReloadInterval,
+		},
+		cli.BoolFlag{
+			Name:        "logging-production",
+			EnvVar:      "LOGGING_PRODUCTION",
+			Usage:       "enable logging for a system in production",
+			Destination: &runner.LoggingProduction,
+		},
+		cli.StringFlag{
+			Name:        "loggging-level",
+			EnvVar:      "LOGGING_LEVEL",
+			Usage:       "sets the loggging level of all logged messages",
+			Destination: &runner.LoggingLevel,
+		},
+		cli.DurationFlag{
+			Name:        "shutdown-delay",
+			EnvVar:      "SHUTDOWN_DELAY",
+			Usage:       "how long to keep serving with failing readiness after SIGTERM before draining, so load balancers stop sending new requests",
+			Value:       time.Second * 5,
+			Destination: &runner.ShutdownDelay,
+		},
+		cli.DurationFlag{
+			Name:        "shutdown-grace-period",
+			EnvVar:      "SHUTDOWN_GRACE_PERIOD",
+			Usage:       "how long in-flight requests may take to finish before they are cut off on shutdown",
+			Value:       time.Second * 20,
+			Destination: &runner.ShutdownGracePeriod,
+		},
+		cli.DurationFlag{
+			Name:        "health-check-timeout",
+			EnvVar:      "HEALTH_CHECK_TIMEOUT",
+			Usage:       "how long each dependency check of /readyz and /healthz may take",
+			Value:       time.Second * 2,
+			Destination: &runner.HealthCheckTimeout,
+		},
+		cli.IntFlag{
+			Name:        "comment-max-depth",
+			EnvVar:      "COMMENT_MAX_DEPTH",
+			Usage:       "deepest reply allowed in a comment thread, top level comments have depth 0",
+			Value:       5,
+			Destination: &runner.CommentMaxDepth,
+		},
+		cli.DurationFlag{
+			Name:        "post-publish-interval",
+			EnvVar:      "POST_PUBLISH_INTERVAL",
+			Usage:       "how often scheduled posts that are due get published, 0 disables publishing them on this replica",
+			Value:       time.Second * 30,
+			Destination: &runner.PostPublishInterval,
+		},
+		cli.IntFlag{
+			Name:        "post-revisions-kept",
+			EnvVar:      "POST_REVISIONS_KEPT",
+			Usage:       "how many of the latest revisions of each post are kept, 0 keeps every revision",
+			Value:       100,
+			Destination: &runner.PostRevisionsKept,
+		},
+		cli.IntFlag{
+			Name:        "post-render-cache-size",
+			EnvVar:      "POST_RENDER_CACHE_SIZE",
+			Usage:       "how many rendered post revisions are kept in memory, 0 renders posts on every request",
+			Value:       10000,
+			Destination: &runner.PostRenderCacheSize,
+		},
+		cli.StringFlag{
+			Name:        "search-backend",
+			EnvVar:      "SEARCH_BACKEND",
+			Usage:       "how GET /posts/search matches posts: postgres (full text search) or substring (case insensitive substring of the listed posts)",
+			Value:       "postgres",
+			Destination: &runner.SearchBackend,
+		},
+		cli.DurationFlag{
+			Name:        "purge-retention",
+			EnvVar:      "PURGE_RETENTION",
+			Usage:       "how long deleted users and posts can be restored before they are permanently deleted",
+			Value:       time.Hour * 24 * 30,
+			Destination: &runner.PurgeRetention,
+		},
+		cli.DurationFlag{
+			Name:        "purge-interval",
+			EnvVar:      "PURGE_INTERVAL",
+			Usage:       "how often users and posts deleted longer than --purge-retention ago are permanently deleted, 0 disables purging on this replica",
+			Value:       time.Hour,
+			Destination: &runner.PurgeInterval,
+		},
+		cli.DurationFlag{
+			Name:        "slow-query-threshold",
+			EnvVar:      "SLOW_QUERY_THRESHOLD",
+			Usage:       "SQL statements taking longer than this are logged with their argument types, 0 disables slow query logging",
+			Value:       time.Millisecond * 200,
+			Destination: &runner.SlowQueryThreshold,
+		},
+		cli.BoolFlag{
+			Name:        "slow-query-explain",
+			EnvVar:      "SLOW_QUERY_EXPLAIN",
+			Usage:       "capture the EXPLAIN (ANALYZE OFF) plan of every logged slow query",
+			Destination: &runner.SlowQueryExplain,
+		},
+		cli.DurationFlag{
+			Name:        "slow-query-log-interval",
+			EnvVar:      "SLOW_QUERY_LOG_INTERVAL",
+			Usage:       "minimum time between two slow query logs of the same statement, slow executions in between are only counted",
+			Value:       time.Minute,
+			Destination: &runner.SlowQueryInterval,
+		},
+		cli.StringFlag{
+			Name:        "tracing-exporter",
+			EnvVar:      "TRACING_EXPORTER",
+			Usage:       "where spans are exported to: none or otlp",
+			Value:       "none",
+			Destination: &runner.TracingExporter,
+		},
+		cli.StringFlag{
+			Name:        "tracing-otlp-endpoint",
+			EnvVar:      "TRACING_OTLP_ENDPOINT",
+			Usage:       "host:port of the OTLP HTTP collector spans are sent to",
+			Value:       "localhost:4318",
+			Destination: &runner.TracingEndpoint,
+		},
+		cli.BoolFlag{
+			Name:        "tracing-otlp-insecure",
+			EnvVar:      "TRACING_OTLP_INSECURE",
+			Usage:       "send spans to the OTLP collector over plain http",
+			Destination: &runner.TracingInsecure,
+		},
+		cli.Float64Flag{
+			Name:        "tracing-sample-ratio",
+			EnvVar:      "TRACING_SAMPLE_RATIO",
+			Usage:       "fraction of new traces that are sampled, traces continued from a traceparent header follow the caller's decision",
+			Value:       1,
+			Destination: &runner.TracingSampleRatio,
+		},
+		cli.StringFlag{
+			Name:        "tracing-service-name",
+			EnvVar:      "TRACING_SERVICE_NAME",
+			Usage:       "service.name resource attribute reported with every span",
+			Value:       "users-posts-api",
+			Destination: &runner.TracingServiceName,
+		},
+		cli.StringSliceFlag{
+			Name:   "rate-limit",
+			EnvVar: "RATE_LIMITS",
+			Usage:  "token bucket limit for a route group as group=rate/burst, e.g. posts=5/20 (repeatable)",
+			Value:  &runner.RateLimits,
+		},
+		cli.StringFlag{
+			Name:        "rate-limit-key",
+			EnvVar:      "RATE_LIMIT_KEY",
+			Usage:       "what requests are rate limited by: ip, client (mutual TLS certificate) or user (mutual TLS certificate common name), unverified requests fall back to ip",
+			Value:       "ip",
+			Destination: &runner.RateLimitKey,
+		},
+		cli.StringFlag{
+			Name:        "rate-limit-backend",
+			EnvVar:      "RATE_LIMIT_BACKEND",
+			Usage:       "where rate limit buckets are stored: memory (single replica) or postgres (shared across replicas)",
+			Value:       "memory",
+			Destination: &runner.RateLimitBackend,
+		},
+		cli.StringSliceFlag{
+			Name:   "cors-allowed-origin",
+			EnvVar: "CORS_ALLOWED_ORIGINS",
+			Usage:  "origin allowed to make cross origin requests, * allows any origin (repeatable)",
+			Value:  &runner.CORSAllowedOrigins,
+		},
+		cli.StringSliceFlag{
+			Name:   "cors-allowed-method",
+			EnvVar: "CORS_ALLOWED_METHODS",
+			Usage:  "method allowed on cross origin requests (repeatable, default: GET, POST, PUT, DELETE)",
+			Value:  &runner.CORSAllowedMethods,
+		},
+		cli.StringSliceFlag{
+			Name:   "cors-allowed-header",
+			EnvVar: "CORS_ALLOWED_HEADERS",
+			Usage:  "request header allowed on cross origin requests (repeatable, default: Content-Type, Authorization, X-API-Key, X-User-ID, X-Request-ID)",
+			Value:  &runner.CORSAllowedHeaders,
+		},
+		cli.StringSliceFlag{
+			Name:   "cors-exposed-header",
+			EnvVar: "CORS_EXPOSED_HEADERS",
+			Usage:  "response header exposed to cross origin callers (repeatable, default: the RateLimit-*, Retry-After and X-Request-ID headers)",
+			Value:  &runner.CORSExposedHeaders,
+		},
+		cli.BoolFlag{
+			Name:        "cors-allow-credentials",
+			EnvVar:      "CORS_ALLOW_CREDENTIALS",
+			Usage:       "allow cross origin requests to include credentials (not allowed with --cors-allowed-origin=*)",
+			Destination: &runner.CORSAllowCredentials,
+		},
+		cli.IntFlag{
+			Name:        "cors-max-age",
+			EnvVar:      "CORS_MAX_AGE",
+			Usage:       "seconds browsers may cache a preflight response",
+			Value:       600,
+			Destination: &runner.CORSMaxAge,
+		},
+		cli.IntFlag{
+			Name:        "hsts-max-age",
+			EnvVar:      "HSTS_MAX_AGE",
+			Usage:       "Strict-Transport-Security max-age in seconds for requests served over https, 0 disables it",
+			Value:       31536000,
+			Destination: &runner.HSTSMaxAge,
+		},
+		cli.BoolFlag{
+			Name:        "hsts-include-subdomains",
+			EnvVar:      "HSTS_INCLUDE_SUBDOMAINS",
+			Usage:       "add includeSubDomains to the Strict-Transport-Security header",
+			Destination: &runner.HSTSIncludeSubdomains,
+		},
+		cli.StringFlag{
+			Name:        "content-security-policy",
+			EnvVar:      "CONTENT_SECURITY_POLICY",
+			Usage:       "Content-Security-Policy header sent with every response, empty disables it",
+			Value:       "default-src 'none'; frame-ancestors 'none'",
+			Destination: &runner.ContentSecurityPolicy,
+		},
+	}
+
+	flags = append(flags, runner.PostgresOptions.Flags()...)
+	flags = append(flags, runner.KeyringOptions.Flags()...)
+	flags = append(flags, runner.ConfigOptions.Flags()...)
+
+	return flags
+}
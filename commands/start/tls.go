@@ -0,0 +1,181 @@
+package start
+
+import (
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"fmt"
+	"os"
+	"sync"
+	"time"
+
+	"go.uber.org/zap"
+)
+
+// certificateReloader keeps the serving certificate and the client CA pool in
+// sync with the files on disk so that rotated certificates (e.g. a renewed
+// kubernetes secret) are picked up without restarting the server.
+type certificateReloader struct {
+	certFile     string
+	keyFile      string
+	clientCAFile string
+	clientAuth   tls.ClientAuthType
+
+	mu          sync.RWMutex
+	certificate *tls.Certificate
+	clientCAs   *x509.CertPool
+	modTimes    map[string]time.Time
+
+	logger *zap.Logger
+}
+
+func newCertificateReloader(certFile, keyFile, clientCAFile string, clientAuth tls.ClientAuthType, logger *zap.Logger) (*certificateReloader, error) {
+	reloader := &certificateReloader{
+		certFile:     certFile,
+		keyFile:      keyFile,
+		clientCAFile: clientCAFile,
+		clientAuth:   clientAuth,
+		modTimes:     make(map[string]time.Time),
+		logger:       logger,
+	}
+
+	if err := reloader.reload(); err != nil {
+		return nil, err
+	}
+
+	return reloader, nil
+}
+
+func (reloader *certificateReloader) files() []string {
+	files := []string{reloader.certFile, reloader.keyFile}
+
+	if reloader.clientCAFile != "" {
+		files = append(files, reloader.clientCAFile)
+	}
+
+	return files
+}
+
+func (reloader *certificateReloader) reload() error {
+	certificate, err := tls.LoadX509KeyPair(reloader.certFile, reloader.keyFile)
+	if err != nil {
+		return fmt.Errorf("unable to load tls key pair: %s", err.Error())
+	}
+
+	var clientCAs *x509.CertPool
+
+	if reloader.clientCAFile != "" {
+		caBytes, err := os.ReadFile(reloader.clientCAFile)
+		if err != nil {
+			return fmt.Errorf("unable to read tls client ca file: %s", err.Error())
+		}
+
+		clientCAs = x509.NewCertPool()
+		if !clientCAs.AppendCertsFromPEM(caBytes) {
+			return fmt.Errorf("no certificates found in tls client ca file %s", reloader.clientCAFile)
+		}
+	}
+
+	modTimes := make(map[string]time.Time)
+
+	for _, file := range reloader.files() {
+		info, err := os.Stat(file)
+		if err != nil {
+			return fmt.Errorf("unable to stat %s: %s", file, err.Error())
+		}
+
+		modTimes[file] = info.ModTime()
+	}
+
+	reloader.mu.Lock()
+	defer reloader.mu.Unlock()
+
+	reloader.certificate = &certificate
+	reloader.clientCAs = clientCAs
+	reloader.modTimes = modTimes
+
+	return nil
+}
+
+func (reloader *certificateReloader) changed() bool {
+	reloader.mu.RLock()
+	defer reloader.mu.RUnlock()
+
+	for _, file := range reloader.files() {
+		info, err := os.Stat(file)
+		if err != nil {
+			// the file may be mid rotation, try again on the next tick
+			return false
+		}
+
+		if !info.ModTime().Equal(reloader.modTimes[file]) {
+			return true
+		}
+	}
+
+	return false
+}
+
+// watch polls the certificate files and reloads them when they change, until
+// the context is done. A failed reload keeps serving the previously loaded
+// certificates.
+func (reloader *certificateReloader) watch(ctx context.Context, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+
+		if !reloader.changed() {
+			continue
+		}
+
+		if err := reloader.reload(); err != nil {
+			reloader.logger.Error("unable to reload tls certificates, keeping the current ones", zap.Error(err))
+			continue
+		}
+
+		reloader.logger.Info("reloaded tls certificates")
+	}
+}
+
+// tlsConfig returns a server config that resolves the certificate and client
+// CAs on every handshake so reloads take effect for new connections.
+func (reloader *certificateReloader) tlsConfig() *tls.Config {
+	return &tls.Config{
+		MinVersion: tls.VersionTLS12,
+		NextProtos: []string{"h2", "http/1.1"},
+		GetConfigForClient: func(*tls.ClientHelloInfo) (*tls.Config, error) {
+			reloader.mu.RLock()
+			defer reloader.mu.RUnlock()
+
+			config := &tls.Config{
+				MinVersion:   tls.VersionTLS12,
+				NextProtos:   []string{"h2", "http/1.1"},
+				Certificates: []tls.Certificate{*reloader.certificate},
+			}
+
+			if reloader.clientCAs != nil {
+				config.ClientCAs = reloader.clientCAs
+				config.ClientAuth = reloader.clientAuth
+			}
+
+			return config, nil
+		},
+	}
+}
+
+func parseClientAuth(name string) (tls.ClientAuthType, error) {
+	switch name {
+	case "require":
+		return tls.RequireAndVerifyClientCert, nil
+	case "verify-if-given":
+		return tls.VerifyClientCertIfGiven, nil
+	default:
+		return tls.NoClientCert, fmt.Errorf("unknown tls client auth %q, expected require or verify-if-given", name)
+	}
+}
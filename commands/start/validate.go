@@ -0,0 +1,165 @@
+package start
+
+import (
+	"fmt"
+	"os"
+
+	"go.uber.org/zap/zapcore"
+	apimiddleware "redcellpartners.com/users-posts-api/middleware"
+	"redcellpartners.com/users-posts-api/tracing"
+)
+
+// Validate returns every problem with the runner's configuration so they can
+// all be fixed at once instead of one failed start at a time.
+func (runner *StartRunner) Validate() []error {
+	var problems []error
+
+	if runner.ListenAddr == "" {
+		problems = append(problems, fmt.Errorf("--listen-addr is required"))
+	}
+
+	if runner.AdminListenAddr != "" && runner.AdminListenAddr == runner.ListenAddr {
+		problems = append(problems, fmt.Errorf("--admin-listen-addr must differ from --listen-addr so admin endpoints are not served publicly"))
+	}
+
+	var level zapcore.Level
+	if err := level.UnmarshalText([]byte(runner.LoggingLevel)); err != nil {
+		problems = append(problems, fmt.Errorf("--loggging-level: %s", err.Error()))
+	}
+
+	problems = append(problems, runner.validateTLS()...)
+
+	switch runner.TracingExporter {
+	case tracing.ExporterNone, tracing.ExporterOTLP:
+	default:
+		problems = append(problems, fmt.Errorf("unknown --tracing-exporter %q, expected none or otlp", runner.TracingExporter))
+	}
+
+	if runner.TracingSampleRatio < 0 || runner.TracingSampleRatio > 1 {
+		problems = append(problems, fmt.Errorf("--tracing-sample-ratio must be between 0 and 1, got %g", runner.TracingSampleRatio))
+	}
+
+	if _, err := apimiddleware.ParseRateLimits(runner.RateLimits.Value()); err != nil {
+		problems = append(problems, fmt.Errorf("--rate-limit: %s", err.Error()))
+	}
+
+	if _, err := apimiddleware.RateLimitKeyFuncFromName(runner.RateLimitKey); err != nil {
+		problems = append(problems, fmt.Errorf("--rate-limit-key: %s", err.Error()))
+	}
+
+	switch runner.SearchBackend {
+	case "postgres", "substring":
+	default:
+		problems = append(problems, fmt.Errorf("unknown --search-backend %q, expected postgres or substring", runner.SearchBackend))
+	}
+
+	switch runner.RateLimitBackend {
+	case "memory", "postgres":
+	default:
+		problems = append(problems, fmt.Errorf("unknown --rate-limit-backend %q, expected memory or postgres", runner.RateLimitBackend))
+	}
+
+	if runner.CORSAllowCredentials {
+		for _, origin := range runner.CORSAllowedOrigins.Value() {
+			if origin == "*" {
+				problems = append(problems, fmt.Errorf("--cors-allow-credentials can not be used with --cors-allowed-origin=*, every site could make credentialed requests"))
+				break
+			}
+		}
+	}
+
+	if runner.CORSMaxAge < 0 {
+		problems = append(problems, fmt.Errorf("--cors-max-age must not be negative"))
+	}
+
+	if runner.HSTSMaxAge < 0 {
+		problems = append(problems, fmt.Errorf("--hsts-max-age must not be negative"))
+	}
+
+	if runner.HealthCheckTimeout <= 0 {
+		problems = append(problems, fmt.Errorf("--health-check-timeout must be positive"))
+	}
+
+	if runner.CommentMaxDepth < 0 {
+		problems = append(problems, fmt.Errorf("--comment-max-depth must not be negative"))
+	}
+
+	if runner.PostPublishInterval < 0 {
+		problems = append(problems, fmt.Errorf("--post-publish-interval must not be negative"))
+	}
+
+	if runner.PostRevisionsKept < 0 {
+		problems = append(problems, fmt.Errorf("--post-revisions-kept must not be negative"))
+	}
+
+	if runner.PostRenderCacheSize < 0 {
+		problems = append(problems, fmt.Errorf("--post-render-cache-size must not be negative"))
+	}
+
+	if runner.PurgeRetention < 0 {
+		problems = append(problems, fmt.Errorf("--purge-retention must not be negative"))
+	}
+
+	if runner.PurgeInterval < 0 {
+		problems = append(problems, fmt.Errorf("--purge-interval must not be negative"))
+	}
+
+	if runner.SlowQueryThreshold < 0 {
+		problems = append(problems, fmt.Errorf("--slow-query-threshold must not be negative"))
+	}
+
+	if runner.SlowQueryInterval < 0 {
+		problems = append(problems, fmt.Errorf("--slow-query-log-interval must not be negative"))
+	}
+
+	if runner.ShutdownDelay < 0 {
+		problems = append(problems, fmt.Errorf("--shutdown-delay must not be negative"))
+	}
+
+	if runner.ShutdownGracePeriod <= 0 {
+		problems = append(problems, fmt.Errorf("--shutdown-grace-period must be positive"))
+	}
+
+	problems = append(problems, runner.PostgresOptions.Validate()...)
+	problems = append(problems, runner.KeyringOptions.Validate()...)
+
+	return problems
+}
+
+func (runner *StartRunner) validateTLS() []error {
+	var problems []error
+
+	if runner.TLSCertFile == "" && runner.TLSKeyFile == "" {
+		if runner.TLSClientCAFile != "" {
+			problems = append(problems, fmt.Errorf("--tls-client-ca requires --tls-cert and --tls-key"))
+		}
+
+		return problems
+	}
+
+	if runner.TLSCertFile == "" || runner.TLSKeyFile == "" {
+		problems = append(problems, fmt.Errorf("both --tls-cert and --tls-key must be set to serve tls"))
+	}
+
+	for _, path := range []string{runner.TLSCertFile, runner.TLSKeyFile, runner.TLSClientCAFile} {
+		if path == "" {
+			continue
+		}
+
+		if _, err := os.Stat(path); err != nil {
+			problems = append(problems, fmt.Errorf("unable to read tls file: %s", err.Error()))
+		}
+	}
+
+	if runner.TLSClientCAFile != "" {
+		if _, err := parseClientAuth(runner.TLSClientAuth); err != nil {
+			problems = append(problems, fmt.Errorf("--tls-client-auth: %s", err.Error()))
+		}
+	}
+
+	if runner.TLSReloadInterval < 0 {
+		problems = append(problems, fmt.Errorf("--tls-reload-interval must not be negative"))
+	}
+
+	return problems
+}
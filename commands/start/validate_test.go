@@ -0,0 +1,43 @@
+package start
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/urfave/cli"
+)
+
+func TestValidateCORSCredentials(t *testing.T) {
+	tests := []struct {
+		name        string
+		origins     []string
+		credentials bool
+		rejected    bool
+	}{
+		{name: "listed origins with credentials", origins: []string{"https://app.example.com"}, credentials: true},
+		{name: "wildcard without credentials", origins: []string{"*"}},
+		{name: "wildcard with credentials", origins: []string{"*"}, credentials: true, rejected: true},
+		{name: "wildcard among listed origins with credentials", origins: []string{"https://app.example.com", "*"}, credentials: true, rejected: true},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			runner := &StartRunner{
+				CORSAllowedOrigins:   cli.StringSlice(test.origins),
+				CORSAllowCredentials: test.credentials,
+			}
+
+			rejected := false
+
+			for _, problem := range runner.Validate() {
+				if strings.Contains(problem.Error(), "--cors-allow-credentials") {
+					rejected = true
+				}
+			}
+
+			if rejected != test.rejected {
+				t.Errorf("expected the configuration to be rejected: %t, got %t", test.rejected, rejected)
+			}
+		})
+	}
+}
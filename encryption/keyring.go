@@ -0,0 +1,214 @@
+package encryption
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/binary"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+)
+
+const (
+	keySize = 32
+
+	// envelopeVersion prefixes every ciphertext so the format can change
+	// without having to guess how old rows were written.
+	envelopeVersion byte = 1
+)
+
+type keyringFile struct {
+	PrimaryKeyID string `json:"primary_key_id"`
+	HashKey      string `json:"hash_key"`
+	Keys         []struct {
+		ID  string `json:"id"`
+		Key string `json:"key"`
+	} `json:"keys"`
+}
+
+// Keyring holds the key encryption keys used to wrap the per value data keys
+// and the key used for the deterministic lookup hash. New values are always
+// encrypted with the primary key; the other keys are kept so values written
+// before a rotation can still be decrypted.
+type Keyring struct {
+	primaryKeyID string
+	keys         map[string]cipher.AEAD
+	hashKey      []byte
+}
+
+// LoadKeyring reads a JSON keyring file of the form
+//
+//	{
+//	  "primary_key_id": "2024-06",
+//	  "hash_key": "<base64 32 bytes>",
+//	  "keys": [{"id": "2024-06", "key": "<base64 32 bytes>"}]
+//	}
+func LoadKeyring(path string) (*Keyring, error) {
+	contents, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("unable to read keyring file: %s", err.Error())
+	}
+
+	var file keyringFile
+
+	if err = json.Unmarshal(contents, &file); err != nil {
+		return nil, fmt.Errorf("unable to parse keyring file: %s", err.Error())
+	}
+
+	keyring := &Keyring{
+		primaryKeyID: file.PrimaryKeyID,
+		keys:         make(map[string]cipher.AEAD, len(file.Keys)),
+	}
+
+	if keyring.hashKey, err = decodeKey(file.HashKey); err != nil {
+		return nil, fmt.Errorf("invalid hash_key in keyring: %s", err.Error())
+	}
+
+	for _, key := range file.Keys {
+		if key.ID == "" {
+			return nil, fmt.Errorf("keyring contains a key without an id")
+		}
+
+		if _, ok := keyring.keys[key.ID]; ok {
+			return nil, fmt.Errorf("keyring contains key %s more than once", key.ID)
+		}
+
+		keyBytes, err := decodeKey(key.Key)
+		if err != nil {
+			return nil, fmt.Errorf("invalid key %s in keyring: %s", key.ID, err.Error())
+		}
+
+		if keyring.keys[key.ID], err = newAEAD(keyBytes); err != nil {
+			return nil, fmt.Errorf("invalid key %s in keyring: %s", key.ID, err.Error())
+		}
+	}
+
+	if _, ok := keyring.keys[keyring.primaryKeyID]; !ok {
+		return nil, fmt.Errorf("primary key %q is not in the keyring", keyring.primaryKeyID)
+	}
+
+	return keyring, nil
+}
+
+func decodeKey(encoded string) ([]byte, error) {
+	key, err := base64.StdEncoding.DecodeString(strings.TrimSpace(encoded))
+	if err != nil {
+		return nil, err
+	}
+
+	if len(key) != keySize {
+		return nil, fmt.Errorf("expected a %d byte key, got %d bytes", keySize, len(key))
+	}
+
+	return key, nil
+}
+
+func newAEAD(key []byte) (cipher.AEAD, error) {
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, err
+	}
+
+	return cipher.NewGCM(block)
+}
+
+func (keyring *Keyring) PrimaryKeyID() string {
+	return keyring.primaryKeyID
+}
+
+// Encrypt seals the plaintext with a fresh data key and wraps that data key
+// with the primary key. The returned key id must be stored with the
+// ciphertext so it can be decrypted after the primary key changes.
+func (keyring *Keyring) Encrypt(plaintext []byte) ([]byte, string, error) {
+	dataKey := make([]byte, keySize)
+	if _, err := io.ReadFull(rand.Reader, dataKey); err != nil {
+		return nil, "", fmt.Errorf("unable to generate data key: %s", err.Error())
+	}
+
+	wrappedKey, err := seal(keyring.keys[keyring.primaryKeyID], dataKey, []byte(keyring.primaryKeyID))
+	if err != nil {
+		return nil, "", fmt.Errorf("unable to wrap data key: %s", err.Error())
+	}
+
+	dataAEAD, err := newAEAD(dataKey)
+	if err != nil {
+		return nil, "", fmt.Errorf("unable to create data cipher: %s", err.Error())
+	}
+
+	sealed, err := seal(dataAEAD, plaintext, nil)
+	if err != nil {
+		return nil, "", fmt.Errorf("unable to encrypt value: %s", err.Error())
+	}
+
+	envelope := make([]byte, 0, 3+len(wrappedKey)+len(sealed))
+	envelope = append(envelope, envelopeVersion)
+	envelope = binary.BigEndian.AppendUint16(envelope, uint16(len(wrappedKey)))
+	envelope = append(envelope, wrappedKey...)
+	envelope = append(envelope, sealed...)
+
+	return envelope, keyring.primaryKeyID, nil
+}
+
+func (keyring *Keyring) Decrypt(envelope []byte, keyID string) ([]byte, error) {
+	keyAEAD, ok := keyring.keys[keyID]
+	if !ok {
+		return nil, fmt.Errorf("key %q is not in the keyring", keyID)
+	}
+
+	if len(envelope) < 3 || envelope[0] != envelopeVersion {
+		return nil, fmt.Errorf("unsupported ciphertext format")
+	}
+
+	wrappedKeyLen := int(binary.BigEndian.Uint16(envelope[1:3]))
+	if len(envelope) < 3+wrappedKeyLen {
+		return nil, fmt.Errorf("ciphertext is truncated")
+	}
+
+	dataKey, err := open(keyAEAD, envelope[3:3+wrappedKeyLen], []byte(keyID))
+	if err != nil {
+		return nil, fmt.Errorf("unable to unwrap data key: %s", err.Error())
+	}
+
+	dataAEAD, err := newAEAD(dataKey)
+	if err != nil {
+		return nil, fmt.Errorf("unable to create data cipher: %s", err.Error())
+	}
+
+	plaintext, err := open(dataAEAD, envelope[3+wrappedKeyLen:], nil)
+	if err != nil {
+		return nil, fmt.Errorf("unable to decrypt value: %s", err.Error())
+	}
+
+	return plaintext, nil
+}
+
+// Hash returns a deterministic keyed hash of the value, suitable for unique
+// constraints and equality lookups on encrypted columns.
+func (keyring *Keyring) Hash(value []byte) []byte {
+	mac := hmac.New(sha256.New, keyring.hashKey)
+	mac.Write(value)
+	return mac.Sum(nil)
+}
+
+func seal(aead cipher.AEAD, plaintext, additionalData []byte) ([]byte, error) {
+	nonce := make([]byte, aead.NonceSize())
+	if _, err := io.ReadFull(rand.Reader, nonce); err != nil {
+		return nil, err
+	}
+
+	return aead.Seal(nonce, nonce, plaintext, additionalData), nil
+}
+
+func open(aead cipher.AEAD, sealed, additionalData []byte) ([]byte, error) {
+	if len(sealed) < aead.NonceSize() {
+		return nil, fmt.Errorf("sealed value is truncated")
+	}
+
+	return aead.Open(nil, sealed[:aead.NonceSize()], sealed[aead.NonceSize():], additionalData)
+}
@@ -0,0 +1,121 @@
+package health
+
+import (
+	"context"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+const (
+	StatusOK   = "ok"
+	StatusFail = "fail"
+)
+
+// CheckFunc reports a dependency as healthy by returning nil.
+type CheckFunc func(ctx context.Context) error
+
+type check struct {
+	name string
+	fn   CheckFunc
+}
+
+type CheckResult struct {
+	Status   string  `json:"status"`
+	Duration float64 `json:"duration_ms"`
+	Error    string  `json:"error,omitempty"`
+}
+
+type Report struct {
+	Status string                  `json:"status"`
+	Checks map[string]*CheckResult `json:"checks"`
+}
+
+// Checker runs the registered dependency checks and tracks whether the
+// process is shutting down, which fails readiness regardless of the checks.
+type Checker struct {
+	checks       []check
+	timeout      time.Duration
+	shuttingDown atomic.Bool
+}
+
+func NewChecker(timeout time.Duration) *Checker {
+	return &Checker{
+		timeout: timeout,
+	}
+}
+
+// AddCheck registers a named dependency check. It is not safe to call once
+// the checker is serving requests.
+func (checker *Checker) AddCheck(name string, fn CheckFunc) {
+	checker.checks = append(checker.checks, check{name: name, fn: fn})
+}
+
+// SetShuttingDown makes every following readiness check fail so load
+// balancers stop routing new requests to the process.
+func (checker *Checker) SetShuttingDown() {
+	checker.shuttingDown.Store(true)
+}
+
+func (checker *Checker) ShuttingDown() bool {
+	return checker.shuttingDown.Load()
+}
+
+// Check runs every check concurrently, each bounded by the checker timeout.
+func (checker *Checker) Check(ctx context.Context) *Report {
+	report := &Report{
+		Status: StatusOK,
+		Checks: make(map[string]*CheckResult, len(checker.checks)+1),
+	}
+
+	var (
+		mu sync.Mutex
+		wg sync.WaitGroup
+	)
+
+	for _, c := range checker.checks {
+		wg.Add(1)
+
+		go func(c check) {
+			defer wg.Done()
+
+			checkCtx, cancel := context.WithTimeout(ctx, checker.timeout)
+			defer cancel()
+
+			start := time.Now()
+			err := c.fn(checkCtx)
+
+			result := &CheckResult{
+				Status:   StatusOK,
+				Duration: float64(time.Since(start).Microseconds()) / 1000,
+			}
+
+			if err != nil {
+				result.Status = StatusFail
+				result.Error = err.Error()
+			}
+
+			mu.Lock()
+			report.Checks[c.name] = result
+			mu.Unlock()
+		}(c)
+	}
+
+	wg.Wait()
+
+	shutdown := &CheckResult{Status: StatusOK}
+	if checker.ShuttingDown() {
+		shutdown.Status = StatusFail
+		shutdown.Error = "server is shutting down"
+	}
+
+	report.Checks["shutdown"] = shutdown
+
+	for _, result := range report.Checks {
+		if result.Status != StatusOK {
+			report.Status = StatusFail
+		}
+	}
+
+	return report
+}
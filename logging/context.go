@@ -0,0 +1,32 @@
+package logging
+
+import (
+	"context"
+
+	"go.uber.org/zap"
+)
+
+type fieldsContextKey struct{}
+
+// WithFields returns a context carrying fields that every logger obtained
+// through FromContext adds to its log lines, e.g. the request id.
+func WithFields(ctx context.Context, fields ...zap.Field) context.Context {
+	existing, _ := ctx.Value(fieldsContextKey{}).([]zap.Field)
+
+	combined := make([]zap.Field, 0, len(existing)+len(fields))
+	combined = append(combined, existing...)
+	combined = append(combined, fields...)
+
+	return context.WithValue(ctx, fieldsContextKey{}, combined)
+}
+
+// FromContext returns the logger with the request scoped fields of the
+// context attached, keeping the name and fields of the given logger.
+func FromContext(ctx context.Context, logger *zap.Logger) *zap.Logger {
+	fields, _ := ctx.Value(fieldsContextKey{}).([]zap.Field)
+	if len(fields) == 0 {
+		return logger
+	}
+
+	return logger.With(fields...)
+}
@@ -0,0 +1,174 @@
+package markdown
+
+import (
+	"bytes"
+	"container/list"
+	"html"
+	"regexp"
+	"strings"
+	"sync"
+	"unicode/utf8"
+
+	"github.com/microcosm-cc/bluemonday"
+	"github.com/yuin/goldmark"
+)
+
+const (
+	// EXCERPT_RUNES is about how long an excerpt is, it is cut at the last
+	// word that fits.
+	EXCERPT_RUNES = 200
+
+	// WORDS_PER_MINUTE is the reading speed reading times are estimated at.
+	WORDS_PER_MINUTE = 200
+)
+
+// Rendered is a post's content rendered from CommonMark.
+type Rendered struct {
+	// HTML is safe to embed in a page, anything that could run script or
+	// restyle the page around it is removed.
+	HTML string
+	// Excerpt is the start of the content as plain text.
+	Excerpt string
+	// ReadingMinutes estimates how long the content takes to read, at least
+	// a minute.
+	ReadingMinutes int
+}
+
+type cacheKey struct {
+	postID   int
+	revision int
+}
+
+type cacheEntry struct {
+	key      cacheKey
+	rendered *Rendered
+}
+
+// Renderer renders post content, keeping the most recently rendered
+// revisions in memory. A revision's content never changes, so its rendering
+// never goes stale.
+type Renderer struct {
+	markdown  goldmark.Markdown
+	sanitizer *bluemonday.Policy
+	stripper  *bluemonday.Policy
+
+	mu        sync.Mutex
+	cacheSize int
+	entries   map[cacheKey]*list.Element
+	recent    *list.List
+}
+
+// NewRenderer returns a renderer caching up to cacheSize renderings, or none
+// when cacheSize is 0.
+func NewRenderer(cacheSize int) *Renderer {
+	sanitizer := bluemonday.UGCPolicy()
+
+	// fenced code keeps its language for syntax highlighting
+	sanitizer.AllowAttrs("class").Matching(regexp.MustCompile(`^language-[\w+-]+$`)).OnElements("code")
+
+	return &Renderer{
+		markdown:  goldmark.New(),
+		sanitizer: sanitizer,
+		stripper:  bluemonday.StrictPolicy(),
+		cacheSize: cacheSize,
+		entries:   make(map[cacheKey]*list.Element),
+		recent:    list.New(),
+	}
+}
+
+// Render renders the content of a revision of a post. Rendering a revision
+// already in the cache returns the cached rendering.
+func (renderer *Renderer) Render(postID, revision int, content string) (*Rendered, error) {
+	key := cacheKey{postID: postID, revision: revision}
+
+	if rendered, ok := renderer.cached(key); ok {
+		return rendered, nil
+	}
+
+	var converted bytes.Buffer
+
+	if err := renderer.markdown.Convert([]byte(content), &converted); err != nil {
+		return nil, err
+	}
+
+	rendered := &Rendered{
+		HTML: renderer.sanitizer.Sanitize(converted.String()),
+	}
+
+	text := strings.Fields(html.UnescapeString(renderer.stripper.Sanitize(rendered.HTML)))
+
+	rendered.Excerpt = excerpt(text)
+	rendered.ReadingMinutes = (len(text) + WORDS_PER_MINUTE - 1) / WORDS_PER_MINUTE
+	if rendered.ReadingMinutes < 1 {
+		rendered.ReadingMinutes = 1
+	}
+
+	renderer.store(key, rendered)
+
+	return rendered, nil
+}
+
+func (renderer *Renderer) cached(key cacheKey) (*Rendered, bool) {
+	renderer.mu.Lock()
+	defer renderer.mu.Unlock()
+
+	element, ok := renderer.entries[key]
+	if !ok {
+		return nil, false
+	}
+
+	renderer.recent.MoveToFront(element)
+
+	return element.Value.(*cacheEntry).rendered, true
+}
+
+func (renderer *Renderer) store(key cacheKey, rendered *Rendered) {
+	if renderer.cacheSize <= 0 {
+		return
+	}
+
+	renderer.mu.Lock()
+	defer renderer.mu.Unlock()
+
+	if element, ok := renderer.entries[key]; ok {
+		renderer.recent.MoveToFront(element)
+		return
+	}
+
+	renderer.entries[key] = renderer.recent.PushFront(&cacheEntry{key: key, rendered: rendered})
+
+	if renderer.recent.Len() > renderer.cacheSize {
+		oldest := renderer.recent.Back()
+		renderer.recent.Remove(oldest)
+		delete(renderer.entries, oldest.Value.(*cacheEntry).key)
+	}
+}
+
+// excerpt joins words up to about EXCERPT_RUNES, marking a cut with an
+// ellipsis.
+func excerpt(words []string) string {
+	var (
+		text  strings.Builder
+		runes int
+	)
+
+	for i, word := range words {
+		length := utf8.RuneCountInString(word)
+
+		if i > 0 {
+			if runes+1+length > EXCERPT_RUNES {
+				return text.String() + "…"
+			}
+
+			text.WriteByte(' ')
+			runes++
+		} else if length > EXCERPT_RUNES {
+			return string([]rune(word)[:EXCERPT_RUNES]) + "…"
+		}
+
+		text.WriteString(word)
+		runes += length
+	}
+
+	return text.String()
+}
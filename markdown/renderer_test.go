@@ -0,0 +1,164 @@
+package markdown
+
+import (
+	"strings"
+	"testing"
+	"unicode/utf8"
+)
+
+func TestExcerpt(t *testing.T) {
+	long := strings.Repeat("a", EXCERPT_RUNES+5)
+	word := strings.Repeat("b", 9)
+
+	// 20 words of 9 runes and 19 spaces fill 199 runes
+	fitting := strings.Fields(strings.Repeat(word+" ", 20))
+
+	tests := []struct {
+		name     string
+		words    []string
+		expected string
+	}{
+		{name: "empty", words: nil, expected: ""},
+		{name: "short text is kept whole", words: []string{"Hello", "world"}, expected: "Hello world"},
+		{name: "text that fits is not marked", words: fitting, expected: strings.Join(fitting, " ")},
+		{name: "cut at the last word that fits", words: append(fitting, "cc"), expected: strings.Join(fitting, " ") + "…"},
+		{name: "overlong first word is cut", words: []string{long, "next"}, expected: strings.Repeat("a", EXCERPT_RUNES) + "…"},
+		{name: "runes are counted, not bytes", words: []string{strings.Repeat("é", EXCERPT_RUNES)}, expected: strings.Repeat("é", EXCERPT_RUNES)},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			if excerpt := excerpt(test.words); excerpt != test.expected {
+				t.Errorf("expected %q, got %q", test.expected, excerpt)
+			}
+		})
+	}
+}
+
+func TestRender(t *testing.T) {
+	tests := []struct {
+		name           string
+		content        string
+		html           []string
+		notHTML        []string
+		excerpt        string
+		readingMinutes int
+	}{
+		{
+			name:           "markdown",
+			content:        "# Title\n\nSome *emphasis* and a [link](https://example.com).",
+			html:           []string{"<h1>Title</h1>", "<em>emphasis</em>", `<a href="https://example.com" rel="nofollow">link</a>`},
+			excerpt:        "Title Some emphasis and a link.",
+			readingMinutes: 1,
+		},
+		{
+			name:           "script is removed",
+			content:        "Hello <script>alert(1)</script> world",
+			notHTML:        []string{"<script"},
+			readingMinutes: 1,
+		},
+		{
+			name:           "event handlers are removed",
+			content:        `<img src="x.png" onerror="alert(1)">`,
+			notHTML:        []string{"onerror", "alert(1)"},
+			readingMinutes: 1,
+		},
+		{
+			name:           "javascript links are removed",
+			content:        "[click](javascript:alert(1))",
+			notHTML:        []string{"javascript:"},
+			excerpt:        "click",
+			readingMinutes: 1,
+		},
+		{
+			name:           "code language is kept",
+			content:        "```go\nfmt.Println(\"hi\")\n```",
+			html:           []string{`<code class="language-go">`},
+			excerpt:        `fmt.Println("hi")`,
+			readingMinutes: 1,
+		},
+		{
+			name:           "empty content takes a minute",
+			content:        "",
+			readingMinutes: 1,
+		},
+		{
+			name:           "reading time rounds up",
+			content:        strings.Repeat("word ", WORDS_PER_MINUTE+1),
+			readingMinutes: 2,
+		},
+		{
+			name:           "reading time of whole minutes",
+			content:        strings.Repeat("word ", WORDS_PER_MINUTE*3),
+			readingMinutes: 3,
+		},
+	}
+
+	renderer := NewRenderer(0)
+
+	for i, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			rendered, err := renderer.Render(i, 1, test.content)
+			if err != nil {
+				t.Fatalf("unexpected error: %s", err.Error())
+			}
+
+			for _, expected := range test.html {
+				if !strings.Contains(rendered.HTML, expected) {
+					t.Errorf("expected html to contain %q, got %q", expected, rendered.HTML)
+				}
+			}
+
+			for _, unexpected := range test.notHTML {
+				if strings.Contains(rendered.HTML, unexpected) {
+					t.Errorf("expected html not to contain %q, got %q", unexpected, rendered.HTML)
+				}
+			}
+
+			if test.excerpt != "" && rendered.Excerpt != test.excerpt {
+				t.Errorf("expected excerpt %q, got %q", test.excerpt, rendered.Excerpt)
+			}
+
+			if utf8.RuneCountInString(rendered.Excerpt) > EXCERPT_RUNES+1 {
+				t.Errorf("expected an excerpt of at most %d runes, got %q", EXCERPT_RUNES, rendered.Excerpt)
+			}
+
+			if rendered.ReadingMinutes != test.readingMinutes {
+				t.Errorf("expected %d reading minutes, got %d", test.readingMinutes, rendered.ReadingMinutes)
+			}
+		})
+	}
+}
+
+func TestRenderCache(t *testing.T) {
+	renderer := NewRenderer(1)
+
+	first, err := renderer.Render(1, 1, "first")
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err.Error())
+	}
+
+	// a revision's content never changes, so the cached rendering is returned
+	cached, err := renderer.Render(1, 1, "changed")
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err.Error())
+	}
+
+	if cached != first {
+		t.Errorf("expected the cached rendering of revision 1")
+	}
+
+	if _, err = renderer.Render(2, 1, "second"); err != nil {
+		t.Fatalf("unexpected error: %s", err.Error())
+	}
+
+	// the cache holds one rendering, so revision 1 of post 1 was evicted
+	evicted, err := renderer.Render(1, 1, "changed")
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err.Error())
+	}
+
+	if evicted.Excerpt != "changed" {
+		t.Errorf("expected revision 1 to be rendered again, got %q", evicted.Excerpt)
+	}
+}
@@ -0,0 +1,86 @@
+package middleware
+
+import (
+	"net"
+	"net/http"
+	"time"
+
+	"github.com/go-chi/chi"
+	chimiddleware "github.com/go-chi/chi/middleware"
+	"go.uber.org/zap"
+	"redcellpartners.com/users-posts-api/logging"
+)
+
+type AccessLogMiddleware struct {
+	skipPaths map[string]bool
+	logger    *zap.Logger
+}
+
+// NewAccessLogMiddleware creates the access log middleware. Requests to the
+// skip paths (e.g. probes and metrics scrapes) are not logged.
+func NewAccessLogMiddleware(skipPaths []string, logger *zap.Logger) *AccessLogMiddleware {
+	middleware := &AccessLogMiddleware{
+		skipPaths: make(map[string]bool, len(skipPaths)),
+		logger:    logger,
+	}
+
+	for _, path := range skipPaths {
+		middleware.skipPaths[path] = true
+	}
+
+	return middleware
+}
+
+// AccessLog writes one log line per request once it has been served. It must
+// run after RequestIDMiddleware so the line carries the request id.
+func (middleware *AccessLogMiddleware) AccessLog(next http.Handler) http.Handler {
+	fn := func(w http.ResponseWriter, r *http.Request) {
+		if middleware.skipPaths[r.URL.Path] {
+			next.ServeHTTP(w, r)
+			return
+		}
+
+		start := time.Now()
+		ww := chimiddleware.NewWrapResponseWriter(w, r.ProtoMajor)
+
+		next.ServeHTTP(ww, r)
+
+		route := unmatchedRoute
+		if routeContext := chi.RouteContext(r.Context()); routeContext != nil {
+			if pattern := routeContext.RoutePattern(); pattern != "" {
+				route = pattern
+			}
+		}
+
+		status := ww.Status()
+		if status == 0 {
+			status = http.StatusOK
+		}
+
+		clientIP, _, err := net.SplitHostPort(r.RemoteAddr)
+		if err != nil {
+			clientIP = r.RemoteAddr
+		}
+
+		fields := []zap.Field{
+			zap.String("method", r.Method),
+			zap.String("path", r.URL.Path),
+			zap.String("route", route),
+			zap.Int("status", status),
+			zap.Int("bytes", ww.BytesWritten()),
+			zap.Duration("latency", time.Since(start)),
+			zap.String("client_ip", clientIP),
+			zap.String("user_agent", r.UserAgent()),
+		}
+
+		logger := logging.FromContext(r.Context(), middleware.logger)
+
+		if status >= http.StatusInternalServerError {
+			logger.Warn("request served", fields...)
+		} else {
+			logger.Info("request served", fields...)
+		}
+	}
+
+	return http.HandlerFunc(fn)
+}
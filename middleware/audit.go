@@ -0,0 +1,43 @@
+package middleware
+
+import (
+	"net/http"
+
+	"redcellpartners.com/users-posts-api/store"
+)
+
+// RequestActor identifies who is making the request for the audit trail.
+// Only the verified client certificate is trusted, the X-User-ID header can be
+// set by any caller so requests without a certificate are anonymous.
+func RequestActor(r *http.Request) string {
+	if identity := ClientIdentityFromContext(r.Context()); identity != nil && identity.CommonName != "" {
+		return "cert:" + identity.CommonName
+	}
+
+	return store.AnonymousActor
+}
+
+// AuditContextMiddleware stores the request actor on the request context so
+// that the stores can attribute the changes they audit. It must run after the
+// ClientIdentity middleware.
+func AuditContextMiddleware(next http.Handler) http.Handler {
+	fn := func(w http.ResponseWriter, r *http.Request) {
+		ctx := store.WithActor(r.Context(), RequestActor(r))
+
+		next.ServeHTTP(w, r.WithContext(ctx))
+	}
+
+	return http.HandlerFunc(fn)
+}
+
+// AdminAuditContextMiddleware attributes the changes made through the admin
+// listener, which only operators can reach, to the admin actor.
+func AdminAuditContextMiddleware(next http.Handler) http.Handler {
+	fn := func(w http.ResponseWriter, r *http.Request) {
+		ctx := store.WithActor(r.Context(), store.AdminActor)
+
+		next.ServeHTTP(w, r.WithContext(ctx))
+	}
+
+	return http.HandlerFunc(fn)
+}
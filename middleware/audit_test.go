@@ -0,0 +1,40 @@
+package middleware
+
+import (
+	"context"
+	"net/http/httptest"
+	"testing"
+
+	"redcellpartners.com/users-posts-api/store"
+)
+
+func TestRequestActor(t *testing.T) {
+	tests := []struct {
+		name     string
+		identity *ClientIdentity
+		userID   string
+		expected string
+	}{
+		{name: "anonymous", expected: store.AnonymousActor},
+		{name: "verified certificate", identity: &ClientIdentity{CommonName: "billing"}, expected: "cert:billing"},
+		{name: "unverified header is ignored", userID: "42", expected: store.AnonymousActor},
+		{name: "certificate wins over the header", identity: &ClientIdentity{CommonName: "billing"}, userID: "42", expected: "cert:billing"},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			r := httptest.NewRequest("POST", "/posts", nil)
+			if test.identity != nil {
+				r = r.WithContext(context.WithValue(r.Context(), clientIdentityContextKey{}, test.identity))
+			}
+
+			if test.userID != "" {
+				r.Header.Set(UserIDHeader, test.userID)
+			}
+
+			if actor := RequestActor(r); actor != test.expected {
+				t.Errorf("expected actor %q, got %q", test.expected, actor)
+			}
+		})
+	}
+}
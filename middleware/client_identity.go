@@ -0,0 +1,73 @@
+package middleware
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"net/http"
+	"strconv"
+)
+
+type clientIdentityContextKey struct{}
+
+// ClientIdentity describes the verified client certificate presented on a
+// mutual TLS connection.
+type ClientIdentity struct {
+	CommonName   string
+	Organization []string
+	DNSNames     []string
+	EmailAddrs   []string
+	SerialNumber string
+	Fingerprint  string
+}
+
+// ClientIdentityFromContext returns the identity stored by the ClientIdentity
+// middleware, or nil when the client did not present a verified certificate.
+func ClientIdentityFromContext(ctx context.Context) *ClientIdentity {
+	identity, _ := ctx.Value(clientIdentityContextKey{}).(*ClientIdentity)
+	return identity
+}
+
+// VerifiedUserID returns the id of the user a request is made for when the
+// client presented a verified certificate whose common name is that user's
+// id. Unlike the X-User-ID header it can't be set by just any caller.
+func VerifiedUserID(ctx context.Context) (int, bool) {
+	identity := ClientIdentityFromContext(ctx)
+	if identity == nil {
+		return 0, false
+	}
+
+	userID, err := strconv.Atoi(identity.CommonName)
+	if err != nil || userID <= 0 {
+		return 0, false
+	}
+
+	return userID, true
+}
+
+// ClientIdentityMiddleware exposes the verified client certificate to the
+// handlers further down the chain.
+func ClientIdentityMiddleware(next http.Handler) http.Handler {
+	fn := func(w http.ResponseWriter, r *http.Request) {
+		if r.TLS == nil || len(r.TLS.VerifiedChains) == 0 || len(r.TLS.VerifiedChains[0]) == 0 {
+			next.ServeHTTP(w, r)
+			return
+		}
+
+		cert := r.TLS.VerifiedChains[0][0]
+		fingerprint := sha256.Sum256(cert.Raw)
+
+		identity := &ClientIdentity{
+			CommonName:   cert.Subject.CommonName,
+			Organization: cert.Subject.Organization,
+			DNSNames:     cert.DNSNames,
+			EmailAddrs:   cert.EmailAddresses,
+			SerialNumber: cert.SerialNumber.String(),
+			Fingerprint:  hex.EncodeToString(fingerprint[:]),
+		}
+
+		next.ServeHTTP(w, r.WithContext(context.WithValue(r.Context(), clientIdentityContextKey{}, identity)))
+	}
+
+	return http.HandlerFunc(fn)
+}
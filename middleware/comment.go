@@ -0,0 +1,54 @@
+package middleware
+
+import (
+	"database/sql"
+	"fmt"
+	"net/http"
+	"strconv"
+
+	"github.com/go-chi/chi"
+	"go.uber.org/zap"
+	"redcellpartners.com/users-posts-api/logging"
+	"redcellpartners.com/users-posts-api/store"
+)
+
+type CommentExistsMiddleware struct {
+	commentStore store.CommentStore
+	logger       *zap.Logger
+}
+
+func NewCommentExistsMiddleware(commentStore store.CommentStore, logger *zap.Logger) *CommentExistsMiddleware {
+	return &CommentExistsMiddleware{
+		commentStore: commentStore,
+		logger:       logger,
+	}
+}
+
+func (middleware *CommentExistsMiddleware) CommentExists(next http.Handler) http.Handler {
+	fn := func(w http.ResponseWriter, r *http.Request) {
+		commentID := chi.URLParam(r, "id")
+
+		commentIDInt, err := strconv.Atoi(commentID)
+		if err != nil {
+			logging.FromContext(r.Context(), middleware.logger).Error("unable to convert comment_id provided", zap.Error(err))
+			w.WriteHeader(http.StatusBadRequest)
+			w.Write([]byte("Invalid comment_id provided"))
+			return
+		}
+
+		_, err = middleware.commentStore.GetComment(r.Context(), commentIDInt)
+		if err != nil && err == sql.ErrNoRows {
+			w.WriteHeader(http.StatusNotFound)
+			w.Write([]byte(fmt.Sprintf("comment with comment_id: %d does not exist", commentIDInt)))
+			return
+		} else if err != nil && err != sql.ErrNoRows {
+			logging.FromContext(r.Context(), middleware.logger).Error("unable to get comment for existence check", zap.Int("comment_id", commentIDInt), zap.Error(err))
+			w.WriteHeader(http.StatusInternalServerError)
+			return
+		}
+
+		next.ServeHTTP(w, r)
+	}
+
+	return http.HandlerFunc(fn)
+}
@@ -0,0 +1,160 @@
+package middleware
+
+import (
+	"net/http"
+	"strconv"
+	"strings"
+)
+
+type CORSOptions struct {
+	AllowedOrigins   []string
+	AllowedMethods   []string
+	AllowedHeaders   []string
+	ExposedHeaders   []string
+	AllowCredentials bool
+	MaxAge           int
+}
+
+type CORSMiddleware struct {
+	options        CORSOptions
+	allowAllOrigin bool
+	origins        map[string]bool
+	methods        map[string]bool
+	headers        map[string]bool
+}
+
+func NewCORSMiddleware(options CORSOptions) *CORSMiddleware {
+	middleware := &CORSMiddleware{
+		options: options,
+		origins: make(map[string]bool, len(options.AllowedOrigins)),
+		methods: make(map[string]bool, len(options.AllowedMethods)),
+		headers: make(map[string]bool, len(options.AllowedHeaders)),
+	}
+
+	for _, origin := range options.AllowedOrigins {
+		if origin == "*" {
+			middleware.allowAllOrigin = true
+		}
+
+		middleware.origins[strings.ToLower(origin)] = true
+	}
+
+	for _, method := range options.AllowedMethods {
+		middleware.methods[strings.ToUpper(method)] = true
+	}
+
+	for _, header := range options.AllowedHeaders {
+		middleware.headers[http.CanonicalHeaderKey(header)] = true
+	}
+
+	return middleware
+}
+
+func (middleware *CORSMiddleware) originAllowed(origin string) bool {
+	return middleware.allowAllOrigin || middleware.origins[strings.ToLower(origin)]
+}
+
+// allowOriginValue is the Access-Control-Allow-Origin value for the origin.
+func (middleware *CORSMiddleware) allowOriginValue(origin string) string {
+	if middleware.allowAllOrigin {
+		return "*"
+	}
+
+	return origin
+}
+
+// allowCredentials reports whether responses allow credentials. Browsers
+// ignore credentials with a wildcard origin, and echoing every origin instead
+// would give any site credentialed access, so a wildcard never allows them.
+func (middleware *CORSMiddleware) allowCredentials() bool {
+	return middleware.options.AllowCredentials && !middleware.allowAllOrigin
+}
+
+// CORS answers preflight requests for every route on the router it is used on
+// and decorates actual cross origin requests. It must be registered on the
+// root router so that preflights are handled before routing happens.
+func (middleware *CORSMiddleware) CORS(next http.Handler) http.Handler {
+	fn := func(w http.ResponseWriter, r *http.Request) {
+		origin := r.Header.Get("Origin")
+		if origin == "" {
+			next.ServeHTTP(w, r)
+			return
+		}
+
+		w.Header().Add("Vary", "Origin")
+
+		isPreflight := r.Method == http.MethodOptions && r.Header.Get("Access-Control-Request-Method") != ""
+
+		if isPreflight {
+			middleware.handlePreflight(w, r, origin)
+			return
+		}
+
+		if middleware.originAllowed(origin) {
+			w.Header().Set("Access-Control-Allow-Origin", middleware.allowOriginValue(origin))
+
+			if middleware.allowCredentials() {
+				w.Header().Set("Access-Control-Allow-Credentials", "true")
+			}
+
+			if len(middleware.options.ExposedHeaders) > 0 {
+				w.Header().Set("Access-Control-Expose-Headers", strings.Join(middleware.options.ExposedHeaders, ", "))
+			}
+		}
+
+		next.ServeHTTP(w, r)
+	}
+
+	return http.HandlerFunc(fn)
+}
+
+func (middleware *CORSMiddleware) handlePreflight(w http.ResponseWriter, r *http.Request, origin string) {
+	w.Header().Add("Vary", "Access-Control-Request-Method")
+	w.Header().Add("Vary", "Access-Control-Request-Headers")
+
+	if !middleware.originAllowed(origin) {
+		w.WriteHeader(http.StatusNoContent)
+		return
+	}
+
+	method := strings.ToUpper(r.Header.Get("Access-Control-Request-Method"))
+	if !middleware.methods[method] {
+		w.WriteHeader(http.StatusNoContent)
+		return
+	}
+
+	requestedHeaders := make([]string, 0)
+
+	for _, value := range r.Header.Values("Access-Control-Request-Headers") {
+		for _, header := range strings.Split(value, ",") {
+			header = http.CanonicalHeaderKey(strings.TrimSpace(header))
+			if header == "" {
+				continue
+			}
+
+			if !middleware.headers[header] {
+				w.WriteHeader(http.StatusNoContent)
+				return
+			}
+
+			requestedHeaders = append(requestedHeaders, header)
+		}
+	}
+
+	w.Header().Set("Access-Control-Allow-Origin", middleware.allowOriginValue(origin))
+	w.Header().Set("Access-Control-Allow-Methods", strings.Join(middleware.options.AllowedMethods, ", "))
+
+	if len(requestedHeaders) > 0 {
+		w.Header().Set("Access-Control-Allow-Headers", strings.Join(requestedHeaders, ", "))
+	}
+
+	if middleware.allowCredentials() {
+		w.Header().Set("Access-Control-Allow-Credentials", "true")
+	}
+
+	if middleware.options.MaxAge > 0 {
+		w.Header().Set("Access-Control-Max-Age", strconv.Itoa(middleware.options.MaxAge))
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
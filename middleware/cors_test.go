@@ -0,0 +1,192 @@
+package middleware
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestCORS(t *testing.T) {
+	options := CORSOptions{
+		AllowedOrigins:   []string{"https://app.example.com"},
+		AllowedMethods:   []string{"GET", "POST"},
+		AllowedHeaders:   []string{"Content-Type", "X-Request-ID"},
+		ExposedHeaders:   []string{"X-Request-ID"},
+		AllowCredentials: true,
+		MaxAge:           600,
+	}
+
+	wildcard := options
+	wildcard.AllowedOrigins = []string{"*"}
+
+	tests := []struct {
+		name    string
+		options CORSOptions
+		method  string
+		headers map[string]string
+		// reached is whether the request is passed on to the router
+		reached  bool
+		status   int
+		expected map[string]string
+		vary     []string
+	}{
+		{
+			name:     "same origin request is untouched",
+			options:  options,
+			method:   "GET",
+			reached:  true,
+			status:   http.StatusOK,
+			expected: map[string]string{"Access-Control-Allow-Origin": "", "Vary": ""},
+		},
+		{
+			name:    "allowed origin",
+			options: options,
+			method:  "GET",
+			headers: map[string]string{"Origin": "https://app.example.com"},
+			reached: true,
+			status:  http.StatusOK,
+			expected: map[string]string{
+				"Access-Control-Allow-Origin":      "https://app.example.com",
+				"Access-Control-Allow-Credentials": "true",
+				"Access-Control-Expose-Headers":    "X-Request-ID",
+			},
+			vary: []string{"Origin"},
+		},
+		{
+			name:    "disallowed origin",
+			options: options,
+			method:  "GET",
+			headers: map[string]string{"Origin": "https://evil.example.com"},
+			reached: true,
+			status:  http.StatusOK,
+			expected: map[string]string{
+				"Access-Control-Allow-Origin":      "",
+				"Access-Control-Allow-Credentials": "",
+			},
+			vary: []string{"Origin"},
+		},
+		{
+			name:    "preflight",
+			options: options,
+			method:  "OPTIONS",
+			headers: map[string]string{
+				"Origin":                         "https://app.example.com",
+				"Access-Control-Request-Method":  "POST",
+				"Access-Control-Request-Headers": "content-type, x-request-id",
+			},
+			status: http.StatusNoContent,
+			expected: map[string]string{
+				"Access-Control-Allow-Origin":      "https://app.example.com",
+				"Access-Control-Allow-Methods":     "GET, POST",
+				"Access-Control-Allow-Headers":     "Content-Type, X-Request-Id",
+				"Access-Control-Allow-Credentials": "true",
+				"Access-Control-Max-Age":           "600",
+			},
+			vary: []string{"Origin", "Access-Control-Request-Method", "Access-Control-Request-Headers"},
+		},
+		{
+			name:    "preflight from a disallowed origin",
+			options: options,
+			method:  "OPTIONS",
+			headers: map[string]string{
+				"Origin":                        "https://evil.example.com",
+				"Access-Control-Request-Method": "POST",
+			},
+			status:   http.StatusNoContent,
+			expected: map[string]string{"Access-Control-Allow-Origin": "", "Access-Control-Allow-Methods": ""},
+			vary:     []string{"Origin"},
+		},
+		{
+			name:    "preflight for a disallowed method",
+			options: options,
+			method:  "OPTIONS",
+			headers: map[string]string{
+				"Origin":                        "https://app.example.com",
+				"Access-Control-Request-Method": "DELETE",
+			},
+			status:   http.StatusNoContent,
+			expected: map[string]string{"Access-Control-Allow-Origin": ""},
+		},
+		{
+			name:    "preflight for a disallowed header",
+			options: options,
+			method:  "OPTIONS",
+			headers: map[string]string{
+				"Origin":                         "https://app.example.com",
+				"Access-Control-Request-Method":  "GET",
+				"Access-Control-Request-Headers": "X-User-ID",
+			},
+			status:   http.StatusNoContent,
+			expected: map[string]string{"Access-Control-Allow-Origin": ""},
+		},
+		{
+			name:    "options without a request method is not a preflight",
+			options: options,
+			method:  "OPTIONS",
+			headers: map[string]string{"Origin": "https://app.example.com"},
+			reached: true,
+			status:  http.StatusOK,
+		},
+		{
+			name:    "wildcard never allows credentials",
+			options: wildcard,
+			method:  "GET",
+			headers: map[string]string{"Origin": "https://evil.example.com"},
+			reached: true,
+			status:  http.StatusOK,
+			expected: map[string]string{
+				"Access-Control-Allow-Origin":      "*",
+				"Access-Control-Allow-Credentials": "",
+			},
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			reached := false
+
+			handler := NewCORSMiddleware(test.options).CORS(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				reached = true
+			}))
+
+			r := httptest.NewRequest(test.method, "/posts", nil)
+			for name, value := range test.headers {
+				r.Header.Set(name, value)
+			}
+
+			w := httptest.NewRecorder()
+			handler.ServeHTTP(w, r)
+
+			if reached != test.reached {
+				t.Errorf("expected the router to be reached: %t, got %t", test.reached, reached)
+			}
+
+			if w.Code != test.status {
+				t.Errorf("expected status %d, got %d", test.status, w.Code)
+			}
+
+			for name, value := range test.expected {
+				if got := w.Header().Get(name); got != value {
+					t.Errorf("expected %s %q, got %q", name, value, got)
+				}
+			}
+
+			vary := w.Header().Values("Vary")
+			for _, expected := range test.vary {
+				if !contains(vary, expected) {
+					t.Errorf("expected Vary to contain %s, got %v", expected, vary)
+				}
+			}
+		})
+	}
+}
+
+func contains(values []string, value string) bool {
+	for _, v := range values {
+		if v == value {
+			return true
+		}
+	}
+
+	return false
+}
@@ -8,6 +8,7 @@ import (
 
 	"github.com/go-chi/chi"
 	"go.uber.org/zap"
+	"redcellpartners.com/users-posts-api/logging"
 	"redcellpartners.com/users-posts-api/store"
 )
 
@@ -29,19 +30,19 @@ func (middleware *PostExistsMiddleware) PostExists(next http.Handler) http.Handl
 
 		postIDInt, err := strconv.Atoi(postID)
 		if err != nil {
-			middleware.logger.Error("unable to convert post_id provied", zap.Error(err))
+			logging.FromContext(r.Context(), middleware.logger).Error("unable to convert post_id provied", zap.Error(err))
 			w.WriteHeader(http.StatusBadRequest)
 			w.Write([]byte("Invalid post_id provided"))
 			return
 		}
 
-		_, err = middleware.postStore.GetPost(postIDInt)
+		_, err = middleware.postStore.GetPost(r.Context(), postIDInt)
 		if err != nil && err == sql.ErrNoRows {
 			w.WriteHeader(http.StatusNotFound)
 			w.Write([]byte(fmt.Sprintf("post with post_id: %d does not exist", postIDInt)))
 			return
 		} else if err != nil && err != sql.ErrNoRows {
-			middleware.logger.Error("unable to get post for existance check", zap.Int("post_id", postIDInt), zap.Error(err))
+			logging.FromContext(r.Context(), middleware.logger).Error("unable to get post for existance check", zap.Int("post_id", postIDInt), zap.Error(err))
 			w.WriteHeader(http.StatusInternalServerError)
 			return
 		}
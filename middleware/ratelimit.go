@@ -0,0 +1,166 @@
+package middleware
+
+import (
+	"fmt"
+	"math"
+	"net"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"go.uber.org/zap"
+	"redcellpartners.com/users-posts-api/store"
+)
+
+const (
+	APIKeyHeader = "X-API-Key"
+	UserIDHeader = "X-User-ID"
+)
+
+// RateLimitKeyFunc returns the identity that a request is rate limited by.
+type RateLimitKeyFunc func(r *http.Request) string
+
+// RateLimitKeyByIP limits requests by the remote address of the client. Put
+// chi's RealIP middleware in front of the router when running behind a proxy.
+func RateLimitKeyByIP(r *http.Request) string {
+	host, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		return "ip:" + r.RemoteAddr
+	}
+
+	return "ip:" + host
+}
+
+// RateLimitKeyByAPIClient limits requests by the verified client certificate
+// presented by the client, falling back to the client IP otherwise. Headers
+// such as X-API-Key are not verified by the API, a client could pick a fresh
+// bucket on every request by changing them, so they never choose the bucket.
+func RateLimitKeyByAPIClient(r *http.Request) string {
+	if identity := ClientIdentityFromContext(r.Context()); identity != nil {
+		return "cert:" + identity.Fingerprint
+	}
+
+	return RateLimitKeyByIP(r)
+}
+
+// RateLimitKeyByUser limits requests by the common name of the verified client
+// certificate, which every certificate issued to the same user shares,
+// falling back to the client IP otherwise. Like X-API-Key, the X-User-ID
+// header is not verified and never chooses the bucket.
+func RateLimitKeyByUser(r *http.Request) string {
+	if identity := ClientIdentityFromContext(r.Context()); identity != nil && identity.CommonName != "" {
+		return "user:" + identity.CommonName
+	}
+
+	return RateLimitKeyByIP(r)
+}
+
+func RateLimitKeyFuncFromName(name string) (RateLimitKeyFunc, error) {
+	switch name {
+	case "ip":
+		return RateLimitKeyByIP, nil
+	case "client":
+		return RateLimitKeyByAPIClient, nil
+	case "user":
+		return RateLimitKeyByUser, nil
+	default:
+		return nil, fmt.Errorf("unknown rate limit key %q, expected one of ip, client or user", name)
+	}
+}
+
+// ParseRateLimits parses route group limits of the form group=rate/burst,
+// e.g. posts=5/20 allows bursts of 20 requests refilled at 5 per second.
+func ParseRateLimits(values []string) (map[string]store.RateLimit, error) {
+	limits := make(map[string]store.RateLimit, len(values))
+
+	for _, value := range values {
+		group, spec, found := strings.Cut(value, "=")
+		if !found || group == "" {
+			return nil, fmt.Errorf("invalid rate limit %q, expected group=rate/burst", value)
+		}
+
+		rateStr, burstStr, found := strings.Cut(spec, "/")
+		if !found {
+			return nil, fmt.Errorf("invalid rate limit %q, expected group=rate/burst", value)
+		}
+
+		rate, err := strconv.ParseFloat(rateStr, 64)
+		if err != nil || rate <= 0 {
+			return nil, fmt.Errorf("invalid rate in rate limit %q", value)
+		}
+
+		burst, err := strconv.Atoi(burstStr)
+		if err != nil || burst < 1 {
+			return nil, fmt.Errorf("invalid burst in rate limit %q", value)
+		}
+
+		limits[group] = store.RateLimit{
+			Rate:  rate,
+			Burst: burst,
+		}
+	}
+
+	return limits, nil
+}
+
+type RateLimitMiddleware struct {
+	rateLimitStore store.RateLimitStore
+	limits         map[string]store.RateLimit
+	keyFunc        RateLimitKeyFunc
+	logger         *zap.Logger
+}
+
+func NewRateLimitMiddleware(rateLimitStore store.RateLimitStore, limits map[string]store.RateLimit, keyFunc RateLimitKeyFunc, logger *zap.Logger) *RateLimitMiddleware {
+	return &RateLimitMiddleware{
+		rateLimitStore: rateLimitStore,
+		limits:         limits,
+		keyFunc:        keyFunc,
+		logger:         logger,
+	}
+}
+
+// RateLimit returns a middleware enforcing the limit configured for the route
+// group. Groups without a configured limit are passed through untouched.
+func (middleware *RateLimitMiddleware) RateLimit(group string) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		limit, ok := middleware.limits[group]
+		if !ok {
+			return next
+		}
+
+		fn := func(w http.ResponseWriter, r *http.Request) {
+			key := group + ":" + middleware.keyFunc(r)
+
+			result, err := middleware.rateLimitStore.Take(r.Context(), key, limit)
+			if err != nil {
+				// fail open so that a rate limit backend outage does not take
+				// the whole API down with it
+				middleware.logger.Error("unable to take rate limit token", zap.String("group", group), zap.Error(err))
+				next.ServeHTTP(w, r)
+				return
+			}
+
+			w.Header().Set("RateLimit-Limit", strconv.Itoa(result.Limit))
+			w.Header().Set("RateLimit-Remaining", strconv.Itoa(result.Remaining))
+			w.Header().Set("RateLimit-Reset", strconv.Itoa(ceilSeconds(result.ResetAfter)))
+			w.Header().Set("RateLimit-Policy", fmt.Sprintf("%d;w=%d", limit.Burst, ceilSeconds(time.Duration(float64(limit.Burst)/limit.Rate*float64(time.Second)))))
+
+			if !result.Allowed {
+				retryAfter := ceilSeconds(result.RetryAfter)
+
+				w.Header().Set("Retry-After", strconv.Itoa(retryAfter))
+				WriteProblem(w, http.StatusTooManyRequests, fmt.Sprintf("rate limit exceeded for %s, retry in %d seconds", group, retryAfter))
+				return
+			}
+
+			next.ServeHTTP(w, r)
+		}
+
+		return http.HandlerFunc(fn)
+	}
+}
+
+func ceilSeconds(d time.Duration) int {
+	return int(math.Ceil(d.Seconds()))
+}
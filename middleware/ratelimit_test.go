@@ -0,0 +1,89 @@
+package middleware
+
+import (
+	"context"
+	"net/http/httptest"
+	"testing"
+
+	"redcellpartners.com/users-posts-api/store"
+)
+
+func TestRateLimitKeys(t *testing.T) {
+	identity := &ClientIdentity{CommonName: "alice", Fingerprint: "abc123"}
+
+	tests := []struct {
+		name     string
+		keyFunc  RateLimitKeyFunc
+		identity *ClientIdentity
+		headers  map[string]string
+		expected string
+	}{
+		{name: "ip", keyFunc: RateLimitKeyByIP, expected: "ip:192.0.2.1"},
+		{name: "client with certificate", keyFunc: RateLimitKeyByAPIClient, identity: identity, expected: "cert:abc123"},
+		{name: "client ignores the api key header", keyFunc: RateLimitKeyByAPIClient, headers: map[string]string{APIKeyHeader: "rotated"}, expected: "ip:192.0.2.1"},
+		{name: "user with certificate", keyFunc: RateLimitKeyByUser, identity: identity, expected: "user:alice"},
+		{name: "user ignores the user id header", keyFunc: RateLimitKeyByUser, headers: map[string]string{UserIDHeader: "42"}, expected: "ip:192.0.2.1"},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			r := httptest.NewRequest("GET", "/posts", nil)
+			r.RemoteAddr = "192.0.2.1:1234"
+
+			for name, value := range test.headers {
+				r.Header.Set(name, value)
+			}
+
+			if test.identity != nil {
+				r = r.WithContext(context.WithValue(r.Context(), clientIdentityContextKey{}, test.identity))
+			}
+
+			if key := test.keyFunc(r); key != test.expected {
+				t.Errorf("expected key %q, got %q", test.expected, key)
+			}
+		})
+	}
+}
+
+func TestParseRateLimits(t *testing.T) {
+	tests := []struct {
+		name     string
+		values   []string
+		expected map[string]store.RateLimit
+		wantErr  bool
+	}{
+		{name: "single", values: []string{"posts=5/20"}, expected: map[string]store.RateLimit{"posts": {Rate: 5, Burst: 20}}},
+		{name: "fractional rate", values: []string{"users=0.5/1"}, expected: map[string]store.RateLimit{"users": {Rate: 0.5, Burst: 1}}},
+		{name: "missing group", values: []string{"=5/20"}, wantErr: true},
+		{name: "missing burst", values: []string{"posts=5"}, wantErr: true},
+		{name: "zero rate", values: []string{"posts=0/20"}, wantErr: true},
+		{name: "zero burst", values: []string{"posts=5/0"}, wantErr: true},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			limits, err := ParseRateLimits(test.values)
+			if test.wantErr {
+				if err == nil {
+					t.Fatalf("expected an error, got %v", limits)
+				}
+
+				return
+			}
+
+			if err != nil {
+				t.Fatalf("unexpected error: %s", err.Error())
+			}
+
+			if len(limits) != len(test.expected) {
+				t.Fatalf("expected %v, got %v", test.expected, limits)
+			}
+
+			for group, limit := range test.expected {
+				if limits[group] != limit {
+					t.Errorf("expected %s to be %v, got %v", group, limit, limits[group])
+				}
+			}
+		})
+	}
+}
@@ -0,0 +1,73 @@
+package middleware
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestValidRequestID(t *testing.T) {
+	tests := []struct {
+		name      string
+		requestID string
+		valid     bool
+	}{
+		{name: "uuid", requestID: "3f1c2a4e-8b7d-4c1e-9a2b-6d5e4f3a2b1c", valid: true},
+		{name: "printable punctuation", requestID: "req_42/a.b:c=d", valid: true},
+		{name: "longest", requestID: strings.Repeat("a", maxRequestIDLength), valid: true},
+		{name: "empty", requestID: "", valid: false},
+		{name: "too long", requestID: strings.Repeat("a", maxRequestIDLength+1), valid: false},
+		{name: "space", requestID: "req 42", valid: false},
+		{name: "newline", requestID: "req\n{\"level\":\"error\"}", valid: false},
+		{name: "delete", requestID: "req\x7f", valid: false},
+		{name: "non ascii", requestID: "réq", valid: false},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			if valid := validRequestID(test.requestID); valid != test.valid {
+				t.Errorf("expected %q to be valid: %t, got %t", test.requestID, test.valid, valid)
+			}
+		})
+	}
+}
+
+func TestRequestIDMiddleware(t *testing.T) {
+	tests := []struct {
+		name      string
+		requestID string
+		kept      bool
+	}{
+		{name: "valid id is echoed", requestID: "abc-123", kept: true},
+		{name: "invalid id is replaced", requestID: "abc 123", kept: false},
+		{name: "missing id is generated", requestID: "", kept: false},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			var seen string
+
+			handler := RequestIDMiddleware(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				seen = w.Header().Get(RequestIDHeader)
+			}))
+
+			r := httptest.NewRequest("GET", "/", nil)
+			if test.requestID != "" {
+				r.Header.Set(RequestIDHeader, test.requestID)
+			}
+
+			w := httptest.NewRecorder()
+			handler.ServeHTTP(w, r)
+
+			responseID := w.Header().Get(RequestIDHeader)
+			if !validRequestID(responseID) || responseID != seen {
+				t.Fatalf("expected a valid request id on the response, got %q", responseID)
+			}
+
+			if kept := responseID == test.requestID; kept != test.kept {
+				t.Errorf("expected the request id to be kept: %t, got %q", test.kept, responseID)
+			}
+		})
+	}
+}
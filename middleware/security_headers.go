@@ -0,0 +1,68 @@
+package middleware
+
+import (
+	"fmt"
+	"net/http"
+)
+
+type SecurityHeadersOptions struct {
+	// HSTSMaxAge is the Strict-Transport-Security max-age in seconds, zero
+	// disables the header. It is only sent on requests that arrived over TLS.
+	HSTSMaxAge            int
+	HSTSIncludeSubdomains bool
+	ContentTypeNosniff    bool
+	FrameOptions          string
+	ReferrerPolicy        string
+	ContentSecurityPolicy string
+}
+
+type SecurityHeadersMiddleware struct {
+	options SecurityHeadersOptions
+	hsts    string
+}
+
+func NewSecurityHeadersMiddleware(options SecurityHeadersOptions) *SecurityHeadersMiddleware {
+	middleware := &SecurityHeadersMiddleware{
+		options: options,
+	}
+
+	if options.HSTSMaxAge > 0 {
+		middleware.hsts = fmt.Sprintf("max-age=%d", options.HSTSMaxAge)
+
+		if options.HSTSIncludeSubdomains {
+			middleware.hsts += "; includeSubDomains"
+		}
+	}
+
+	return middleware
+}
+
+func (middleware *SecurityHeadersMiddleware) SecurityHeaders(next http.Handler) http.Handler {
+	fn := func(w http.ResponseWriter, r *http.Request) {
+		header := w.Header()
+
+		if middleware.hsts != "" && (r.TLS != nil || r.Header.Get("X-Forwarded-Proto") == "https") {
+			header.Set("Strict-Transport-Security", middleware.hsts)
+		}
+
+		if middleware.options.ContentTypeNosniff {
+			header.Set("X-Content-Type-Options", "nosniff")
+		}
+
+		if middleware.options.FrameOptions != "" {
+			header.Set("X-Frame-Options", middleware.options.FrameOptions)
+		}
+
+		if middleware.options.ReferrerPolicy != "" {
+			header.Set("Referrer-Policy", middleware.options.ReferrerPolicy)
+		}
+
+		if middleware.options.ContentSecurityPolicy != "" {
+			header.Set("Content-Security-Policy", middleware.options.ContentSecurityPolicy)
+		}
+
+		next.ServeHTTP(w, r)
+	}
+
+	return http.HandlerFunc(fn)
+}
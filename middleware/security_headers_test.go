@@ -0,0 +1,93 @@
+package middleware
+
+import (
+	"crypto/tls"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestSecurityHeaders(t *testing.T) {
+	options := SecurityHeadersOptions{
+		HSTSMaxAge:            31536000,
+		HSTSIncludeSubdomains: true,
+		ContentTypeNosniff:    true,
+		FrameOptions:          "DENY",
+		ReferrerPolicy:        "no-referrer",
+		ContentSecurityPolicy: "default-src 'none'",
+	}
+
+	tests := []struct {
+		name     string
+		options  SecurityHeadersOptions
+		tls      bool
+		headers  map[string]string
+		expected map[string]string
+	}{
+		{
+			name:    "plain http gets no hsts",
+			options: options,
+			expected: map[string]string{
+				"Strict-Transport-Security": "",
+				"X-Content-Type-Options":    "nosniff",
+				"X-Frame-Options":           "DENY",
+				"Referrer-Policy":           "no-referrer",
+				"Content-Security-Policy":   "default-src 'none'",
+			},
+		},
+		{
+			name:     "tls gets hsts",
+			options:  options,
+			tls:      true,
+			expected: map[string]string{"Strict-Transport-Security": "max-age=31536000; includeSubDomains"},
+		},
+		{
+			name:     "https behind a proxy gets hsts",
+			options:  options,
+			headers:  map[string]string{"X-Forwarded-Proto": "https"},
+			expected: map[string]string{"Strict-Transport-Security": "max-age=31536000; includeSubDomains"},
+		},
+		{
+			name:     "hsts without subdomains",
+			options:  SecurityHeadersOptions{HSTSMaxAge: 60},
+			tls:      true,
+			expected: map[string]string{"Strict-Transport-Security": "max-age=60"},
+		},
+		{
+			name:    "unset options send nothing",
+			options: SecurityHeadersOptions{},
+			tls:     true,
+			expected: map[string]string{
+				"Strict-Transport-Security": "",
+				"X-Content-Type-Options":    "",
+				"X-Frame-Options":           "",
+				"Referrer-Policy":           "",
+				"Content-Security-Policy":   "",
+			},
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			handler := NewSecurityHeadersMiddleware(test.options).SecurityHeaders(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+
+			r := httptest.NewRequest("GET", "/posts", nil)
+			if test.tls {
+				r.TLS = &tls.ConnectionState{}
+			}
+
+			for name, value := range test.headers {
+				r.Header.Set(name, value)
+			}
+
+			w := httptest.NewRecorder()
+			handler.ServeHTTP(w, r)
+
+			for name, value := range test.expected {
+				if got := w.Header().Get(name); got != value {
+					t.Errorf("expected %s %q, got %q", name, value, got)
+				}
+			}
+		})
+	}
+}
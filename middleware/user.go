@@ -8,6 +8,7 @@ import (
 
 	"github.com/go-chi/chi"
 	"go.uber.org/zap"
+	"redcellpartners.com/users-posts-api/logging"
 	"redcellpartners.com/users-posts-api/store"
 )
 
@@ -29,19 +30,19 @@ func (middleware *UserExistsMiddleware) UserExists(next http.Handler) http.Handl
 
 		userIDInt, err := strconv.Atoi(userID)
 		if err != nil {
-			middleware.logger.Error("unable to convert userid provied", zap.Error(err))
+			logging.FromContext(r.Context(), middleware.logger).Error("unable to convert userid provied", zap.Error(err))
 			w.WriteHeader(http.StatusBadRequest)
 			w.Write([]byte("Invalid user_id provided"))
 			return
 		}
 
-		_, err = middleware.userStore.GetUser(userIDInt)
+		_, err = middleware.userStore.GetUser(r.Context(), userIDInt)
 		if err != nil && err == sql.ErrNoRows {
 			w.WriteHeader(http.StatusNotFound)
 			w.Write([]byte(fmt.Sprintf("user with user_id: %d does not exist", userIDInt)))
 			return
 		} else if err != nil && err != sql.ErrNoRows {
-			middleware.logger.Error("unable to get user for existance check", zap.Int("user_id", userIDInt), zap.Error(err))
+			logging.FromContext(r.Context(), middleware.logger).Error("unable to get user for existance check", zap.Int("user_id", userIDInt), zap.Error(err))
 			w.WriteHeader(http.StatusInternalServerError)
 			return
 		}
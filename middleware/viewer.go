@@ -0,0 +1,33 @@
+package middleware
+
+import (
+	"net/http"
+	"strconv"
+
+	"redcellpartners.com/users-posts-api/store"
+)
+
+// RequestUserID returns the user id from the X-User-ID header, if it holds a
+// valid one.
+func RequestUserID(r *http.Request) (int, bool) {
+	userID, err := strconv.Atoi(r.Header.Get(UserIDHeader))
+	if err != nil || userID <= 0 {
+		return 0, false
+	}
+
+	return userID, true
+}
+
+// ViewerMiddleware stores the requesting user on the request context so that
+// reads can be personalised, e.g. with the viewer's own reactions.
+func ViewerMiddleware(next http.Handler) http.Handler {
+	fn := func(w http.ResponseWriter, r *http.Request) {
+		if userID, ok := RequestUserID(r); ok {
+			r = r.WithContext(store.WithViewer(r.Context(), userID))
+		}
+
+		next.ServeHTTP(w, r)
+	}
+
+	return http.HandlerFunc(fn)
+}
@@ -0,0 +1,17 @@
+package model
+
+import (
+	"encoding/json"
+	"time"
+)
+
+type AuditEvent struct {
+	ID          int             `json:"id"`
+	Actor       string          `json:"actor"`
+	RequestID   string          `json:"request_id,omitempty"`
+	Entity      string          `json:"entity"`
+	EntityID    int             `json:"entity_id"`
+	Action      string          `json:"action"`
+	Diff        json.RawMessage `json:"diff"`
+	CreatedTime time.Time       `json:"created_at"`
+}
@@ -0,0 +1,18 @@
+package model
+
+import (
+	"time"
+)
+
+// Comment is a comment on a post. Replies set ParentID to the comment they
+// answer, Depth is 0 for top level comments and grows by one per reply.
+type Comment struct {
+	ID          int       `json:"id,omitempty"`
+	PostID      int       `json:"post_id"`
+	UserID      int       `json:"user_id"`
+	ParentID    *int      `json:"parent_id,omitempty"`
+	Depth       int       `json:"depth"`
+	Content     string    `json:"content"`
+	CreatedTime time.Time `json:"created_at"`
+	UpdatedTime time.Time `json:"updated_at,omitempty"`
+}
@@ -0,0 +1,22 @@
+package model
+
+import (
+	"time"
+)
+
+// Follow is one side of a follow, the user followed or following and when
+// the follow started.
+type Follow struct {
+	UserID       int       `json:"user_id"`
+	FollowedTime time.Time `json:"followed_at"`
+}
+
+type FollowPage struct {
+	Follows    []*Follow `json:"follows"`
+	NextCursor string    `json:"next_cursor,omitempty"`
+}
+
+type PostPage struct {
+	Posts      []*Post `json:"posts"`
+	NextCursor string  `json:"next_cursor,omitempty"`
+}
@@ -5,10 +5,20 @@ import (
 )
 
 type Post struct {
-	ID            int       `json:"id,omitempty"`
-	Title         string    `json:"title"`
-	Content       string    `json:"content"`
-	CreatedByUser int       `json:"user_id"`
-	CreatedTime   time.Time `json:"created_at"`
-	UpdatedTime   time.Time `json:"udpated_at"`
+	ID              int            `json:"id,omitempty"`
+	Title           string         `json:"title"`
+	Slug            string         `json:"slug"`
+	Content         string         `json:"content"`
+	Revision        int            `json:"revision"`
+	Excerpt         string         `json:"excerpt"`
+	ReadingMinutes  int            `json:"reading_time_minutes"`
+	Status          string         `json:"status"`
+	PublishedTime   *time.Time     `json:"published_at,omitempty"`
+	Tags            []string       `json:"tags"`
+	Reactions       map[string]int `json:"reactions"`
+	ViewerReactions []string       `json:"viewer_reactions,omitempty"`
+	CreatedByUser   int            `json:"user_id"`
+	CreatedTime     time.Time      `json:"created_at"`
+	UpdatedTime     time.Time      `json:"udpated_at"`
+	DeletedTime     *time.Time     `json:"deleted_at,omitempty"`
 }
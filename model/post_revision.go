@@ -0,0 +1,16 @@
+package model
+
+import (
+	"time"
+)
+
+// PostRevision is the title and content of a post as written by one create,
+// update or restore, numbered from 1 per post.
+type PostRevision struct {
+	PostID      int       `json:"post_id"`
+	Revision    int       `json:"revision"`
+	Title       string    `json:"title"`
+	Content     string    `json:"content"`
+	Actor       string    `json:"actor"`
+	CreatedTime time.Time `json:"created_at"`
+}
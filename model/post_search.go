@@ -0,0 +1,14 @@
+package model
+
+// PostSearchResult is a post matching a search, with how well it matched
+// and an excerpt of its content with the matching words highlighted.
+type PostSearchResult struct {
+	Post    *Post   `json:"post"`
+	Rank    float32 `json:"rank"`
+	Snippet string  `json:"snippet"`
+}
+
+type PostSearchPage struct {
+	Results    []*PostSearchResult `json:"results"`
+	NextCursor string              `json:"next_cursor,omitempty"`
+}
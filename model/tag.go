@@ -0,0 +1,6 @@
+package model
+
+type Tag struct {
+	Name      string `json:"name"`
+	PostCount int    `json:"post_count"`
+}
@@ -5,10 +5,12 @@ import (
 )
 
 type User struct {
-	ID          int       `json:"id,omitempty"`
-	FirstName   string    `json:"first_name"`
-	LastName    string    `json:"last_name"`
-	Email       string    `json:"email"`
-	TimeCreated time.Time `json:"created_at,omitempty"`
-	TimeUpdated time.Time `json:"updated_at,omitempty"`
+	ID          int        `json:"id,omitempty"`
+	FirstName   string     `json:"first_name"`
+	LastName    string     `json:"last_name"`
+	Handle      string     `json:"handle,omitempty"`
+	Email       string     `json:"email"`
+	TimeCreated time.Time  `json:"created_at,omitempty"`
+	TimeUpdated time.Time  `json:"updated_at,omitempty"`
+	TimeDeleted *time.Time `json:"deleted_at,omitempty"`
 }
@@ -0,0 +1,103 @@
+package routes
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/pprof"
+	"runtime"
+	"runtime/debug"
+
+	"github.com/go-chi/chi"
+	"go.uber.org/zap"
+)
+
+// AdminResource serves operator endpoints: profiling, build info and runtime
+// log level control. It must only be mounted on the internal admin listener,
+// never on the public router.
+type AdminResource struct {
+	level  zap.AtomicLevel
+	logger *zap.Logger
+}
+
+type BuildInfo struct {
+	GoVersion    string `json:"go_version"`
+	Module       string `json:"module"`
+	Version      string `json:"version"`
+	Revision     string `json:"revision,omitempty"`
+	RevisionAt   string `json:"revision_time,omitempty"`
+	Modified     bool   `json:"modified"`
+	NumCPU       int    `json:"num_cpu"`
+	NumGoroutine int    `json:"num_goroutine"`
+}
+
+func NewAdminResource(level zap.AtomicLevel, logger *zap.Logger) *AdminResource {
+	return &AdminResource{
+		level:  level,
+		logger: logger,
+	}
+}
+
+func (resource *AdminResource) Routes() chi.Router {
+	r := chi.NewRouter()
+
+	r.Get("/buildinfo", resource.BuildInfo)
+	r.Get("/log-level", resource.level.ServeHTTP)
+	r.Put("/log-level", resource.SetLogLevel)
+
+	r.HandleFunc("/debug/pprof/", pprof.Index)
+	r.HandleFunc("/debug/pprof/cmdline", pprof.Cmdline)
+	r.HandleFunc("/debug/pprof/profile", pprof.Profile)
+	r.HandleFunc("/debug/pprof/symbol", pprof.Symbol)
+	r.HandleFunc("/debug/pprof/trace", pprof.Trace)
+	r.Handle("/debug/pprof/{profile}", http.HandlerFunc(pprof.Index))
+
+	return r
+}
+
+// BuildInfo reports the module version and VCS revision the binary was built
+// from.
+func (resource *AdminResource) BuildInfo(w http.ResponseWriter, r *http.Request) {
+	info := BuildInfo{
+		GoVersion:    runtime.Version(),
+		NumCPU:       runtime.NumCPU(),
+		NumGoroutine: runtime.NumGoroutine(),
+	}
+
+	if buildInfo, ok := debug.ReadBuildInfo(); ok {
+		info.Module = buildInfo.Main.Path
+		info.Version = buildInfo.Main.Version
+
+		for _, setting := range buildInfo.Settings {
+			switch setting.Key {
+			case "vcs.revision":
+				info.Revision = setting.Value
+			case "vcs.time":
+				info.RevisionAt = setting.Value
+			case "vcs.modified":
+				info.Modified = setting.Value == "true"
+			}
+		}
+	}
+
+	responseBody, err := json.Marshal(info)
+	if err != nil {
+		resource.logger.Error("unable to marshal build info", zap.Error(err))
+		w.WriteHeader(http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.Write(responseBody)
+}
+
+// SetLogLevel changes the level of every logger in the process, e.g.
+// PUT /log-level with {"level":"debug"}, and takes effect immediately.
+func (resource *AdminResource) SetLogLevel(w http.ResponseWriter, r *http.Request) {
+	previous := resource.level.Level()
+
+	resource.level.ServeHTTP(w, r)
+
+	if current := resource.level.Level(); current != previous {
+		resource.logger.Info("changed log level", zap.Stringer("from", previous), zap.Stringer("to", current))
+	}
+}
@@ -0,0 +1,95 @@
+package routes
+
+import (
+	"encoding/json"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/go-chi/chi"
+	"go.uber.org/zap"
+	"redcellpartners.com/users-posts-api/logging"
+	"redcellpartners.com/users-posts-api/store"
+)
+
+type AuditResource struct {
+	auditStore store.AuditStore
+	logger     *zap.Logger
+}
+
+func NewAuditResource(auditStore store.AuditStore, logger *zap.Logger) *AuditResource {
+	return &AuditResource{
+		auditStore: auditStore,
+		logger:     logger,
+	}
+}
+
+func (resource *AuditResource) Routes() chi.Router {
+	r := chi.NewRouter()
+
+	r.Get("/", resource.ListAuditEvents)
+
+	return r
+}
+
+// ListAuditEvents lists audit events newest first, optionally filtered by the
+// entity, entity_id, actor, since and until (RFC 3339) query parameters.
+func (resource *AuditResource) ListAuditEvents(w http.ResponseWriter, r *http.Request) {
+	var (
+		query  = r.URL.Query()
+		filter = store.AuditFilter{
+			Entity: query.Get("entity"),
+			Actor:  query.Get("actor"),
+		}
+		err error
+	)
+
+	if entityID := query.Get("entity_id"); entityID != "" {
+		if filter.EntityID, err = strconv.Atoi(entityID); err != nil {
+			w.WriteHeader(http.StatusBadRequest)
+			w.Write([]byte("invalid entity_id provided"))
+			return
+		}
+	}
+
+	if since := query.Get("since"); since != "" {
+		if filter.Since, err = time.Parse(time.RFC3339, since); err != nil {
+			w.WriteHeader(http.StatusBadRequest)
+			w.Write([]byte("invalid since provided, expected an RFC 3339 timestamp"))
+			return
+		}
+	}
+
+	if until := query.Get("until"); until != "" {
+		if filter.Until, err = time.Parse(time.RFC3339, until); err != nil {
+			w.WriteHeader(http.StatusBadRequest)
+			w.Write([]byte("invalid until provided, expected an RFC 3339 timestamp"))
+			return
+		}
+	}
+
+	if limit := query.Get("limit"); limit != "" {
+		if filter.Limit, err = strconv.Atoi(limit); err != nil {
+			w.WriteHeader(http.StatusBadRequest)
+			w.Write([]byte("invalid limit provided"))
+			return
+		}
+	}
+
+	events, err := resource.auditStore.ListAuditEvents(r.Context(), filter)
+	if err != nil {
+		logging.FromContext(r.Context(), resource.logger).Error("unable to list audit events", zap.Error(err))
+		w.WriteHeader(http.StatusInternalServerError)
+		w.Write([]byte("unable to list audit events at this time"))
+		return
+	}
+
+	responseBytes, err := json.Marshal(events)
+	if err != nil {
+		logging.FromContext(r.Context(), resource.logger).Error("unable to marshal audit events", zap.Error(err))
+		w.WriteHeader(http.StatusInternalServerError)
+		return
+	}
+
+	w.Write(responseBytes)
+}
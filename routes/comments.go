@@ -0,0 +1,257 @@
+package routes
+
+import (
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"strconv"
+	"strings"
+	"unicode/utf8"
+
+	"github.com/go-chi/chi"
+	"go.uber.org/zap"
+	"redcellpartners.com/users-posts-api/logging"
+	"redcellpartners.com/users-posts-api/middleware"
+	"redcellpartners.com/users-posts-api/model"
+	"redcellpartners.com/users-posts-api/store"
+)
+
+const MAX_COMMENT_LENGTH = 10000
+
+type CommentsResource struct {
+	commentStore store.CommentStore
+	logger       *zap.Logger
+}
+
+func NewCommentsResource(commentStore store.CommentStore, logger *zap.Logger) *CommentsResource {
+	return &CommentsResource{
+		commentStore: commentStore,
+		logger:       logger,
+	}
+}
+
+// Routes serves a single comment at /comments/{id}.
+func (resource *CommentsResource) Routes() chi.Router {
+	r := chi.NewRouter()
+
+	r.Route("/{id}", func(r chi.Router) {
+		commentExistsMiddleware := middleware.NewCommentExistsMiddleware(resource.commentStore, resource.logger.Named("comment_middleware"))
+
+		r.Use(commentExistsMiddleware.CommentExists)
+		r.Get("/", resource.GetComment)
+		r.Put("/", resource.UpdateComment)
+		r.Delete("/", resource.DeleteComment)
+	})
+
+	return r
+}
+
+// PostRoutes serves the comments of a post. It is mounted below /posts/{id}
+// behind the post exists check.
+func (resource *CommentsResource) PostRoutes() chi.Router {
+	r := chi.NewRouter()
+
+	r.Get("/", resource.ListPostComments)
+	r.Post("/", resource.CreatePostComment)
+
+	return r
+}
+
+func (resource *CommentsResource) ListPostComments(w http.ResponseWriter, r *http.Request) {
+	postID, err := strconv.Atoi(chi.URLParam(r, "id"))
+	if err != nil {
+		w.WriteHeader(http.StatusBadRequest)
+		w.Write([]byte("bad post id sent in request"))
+		return
+	}
+
+	comments, err := resource.commentStore.ListComments(r.Context(), postID)
+	if err != nil {
+		logging.FromContext(r.Context(), resource.logger).Error("unable to list comments", zap.Int("post_id", postID), zap.Error(err))
+		w.WriteHeader(http.StatusInternalServerError)
+		w.Write([]byte("unable to list comments at this time"))
+		return
+	}
+
+	responseBody, err := json.Marshal(comments)
+	if err != nil {
+		logging.FromContext(r.Context(), resource.logger).Error("unable to marshal comments", zap.Error(err))
+		w.WriteHeader(http.StatusInternalServerError)
+		return
+	}
+
+	w.Write(responseBody)
+}
+
+func (resource *CommentsResource) CreatePostComment(w http.ResponseWriter, r *http.Request) {
+	postID, err := strconv.Atoi(chi.URLParam(r, "id"))
+	if err != nil {
+		w.WriteHeader(http.StatusBadRequest)
+		w.Write([]byte("bad post id sent in request"))
+		return
+	}
+
+	comment, ok := resource.readComment(w, r)
+	if !ok {
+		return
+	}
+
+	if comment.UserID <= 0 {
+		w.WriteHeader(http.StatusBadRequest)
+		w.Write([]byte("user_id is required"))
+		return
+	}
+
+	comment.PostID = postID
+
+	created, err := resource.commentStore.CreateComment(r.Context(), comment)
+	if err == sql.ErrNoRows {
+		w.WriteHeader(http.StatusNotFound)
+		w.Write([]byte(fmt.Sprintf("post with post_id: %d does not exist", postID)))
+		return
+	} else if errors.Is(err, store.ErrCommentParentNotFound) || errors.Is(err, store.ErrCommentTooDeep) || errors.Is(err, store.ErrUnknownUser) {
+		w.WriteHeader(http.StatusUnprocessableEntity)
+		w.Write([]byte(err.Error()))
+		return
+	} else if err != nil {
+		logging.FromContext(r.Context(), resource.logger).Error("unable to create comment", zap.Int("post_id", postID), zap.Error(err))
+		w.WriteHeader(http.StatusInternalServerError)
+		w.Write([]byte("unable to create comment at this time"))
+		return
+	}
+
+	responseBody, err := json.Marshal(created)
+	if err != nil {
+		logging.FromContext(r.Context(), resource.logger).Error("unable to marshal created comment", zap.Error(err))
+		w.WriteHeader(http.StatusInternalServerError)
+		return
+	}
+
+	w.WriteHeader(http.StatusCreated)
+	w.Write(responseBody)
+}
+
+func (resource *CommentsResource) GetComment(w http.ResponseWriter, r *http.Request) {
+	commentID, err := strconv.Atoi(chi.URLParam(r, "id"))
+	if err != nil {
+		w.WriteHeader(http.StatusBadRequest)
+		w.Write([]byte("bad comment id sent in request"))
+		return
+	}
+
+	comment, err := resource.commentStore.GetComment(r.Context(), commentID)
+	if err != nil {
+		logging.FromContext(r.Context(), resource.logger).Error("unable to get comment", zap.Int("comment_id", commentID), zap.Error(err))
+		w.WriteHeader(http.StatusInternalServerError)
+		w.Write([]byte("unable to get comment at this time"))
+		return
+	}
+
+	responseBody, err := json.Marshal(comment)
+	if err != nil {
+		logging.FromContext(r.Context(), resource.logger).Error("unable to marshal comment", zap.Error(err))
+		w.WriteHeader(http.StatusInternalServerError)
+		return
+	}
+
+	w.Write(responseBody)
+}
+
+// UpdateComment replaces the content of a comment. Any other field in the
+// body is ignored.
+func (resource *CommentsResource) UpdateComment(w http.ResponseWriter, r *http.Request) {
+	commentID, err := strconv.Atoi(chi.URLParam(r, "id"))
+	if err != nil {
+		w.WriteHeader(http.StatusBadRequest)
+		w.Write([]byte("bad comment id sent in request"))
+		return
+	}
+
+	comment, ok := resource.readComment(w, r)
+	if !ok {
+		return
+	}
+
+	comment.ID = commentID
+
+	updated, err := resource.commentStore.UpdateComment(r.Context(), comment)
+	if err == sql.ErrNoRows {
+		w.WriteHeader(http.StatusNotFound)
+		w.Write([]byte(fmt.Sprintf("comment with comment_id: %d does not exist", commentID)))
+		return
+	} else if err != nil {
+		logging.FromContext(r.Context(), resource.logger).Error("unable to update comment", zap.Int("comment_id", commentID), zap.Error(err))
+		w.WriteHeader(http.StatusInternalServerError)
+		w.Write([]byte("unable to update comment at this time"))
+		return
+	}
+
+	responseBody, err := json.Marshal(updated)
+	if err != nil {
+		logging.FromContext(r.Context(), resource.logger).Error("unable to marshal updated comment", zap.Error(err))
+		w.WriteHeader(http.StatusInternalServerError)
+		return
+	}
+
+	w.Write(responseBody)
+}
+
+func (resource *CommentsResource) DeleteComment(w http.ResponseWriter, r *http.Request) {
+	commentID, err := strconv.Atoi(chi.URLParam(r, "id"))
+	if err != nil {
+		w.WriteHeader(http.StatusBadRequest)
+		w.Write([]byte("bad comment id sent in request"))
+		return
+	}
+
+	if err = resource.commentStore.DeleteComment(r.Context(), commentID); err == sql.ErrNoRows {
+		w.WriteHeader(http.StatusNotFound)
+		w.Write([]byte(fmt.Sprintf("comment with comment_id: %d does not exist", commentID)))
+		return
+	} else if err != nil {
+		logging.FromContext(r.Context(), resource.logger).Error("unable to delete comment", zap.Int("comment_id", commentID), zap.Error(err))
+		w.WriteHeader(http.StatusInternalServerError)
+		w.Write([]byte("unable to delete comment at this time"))
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// readComment decodes a comment from the request body and checks its
+// content, writing the error response itself when it returns false.
+func (resource *CommentsResource) readComment(w http.ResponseWriter, r *http.Request) (*model.Comment, bool) {
+	body, err := io.ReadAll(r.Body)
+	if err != nil {
+		logging.FromContext(r.Context(), resource.logger).Error("unable to read request body", zap.Error(err))
+		w.WriteHeader(http.StatusInternalServerError)
+		return nil, false
+	}
+
+	var comment *model.Comment
+
+	if err = json.Unmarshal(body, &comment); err != nil || comment == nil {
+		w.WriteHeader(http.StatusBadRequest)
+		w.Write([]byte("invalid comment sent in request"))
+		return nil, false
+	}
+
+	comment.Content = strings.TrimSpace(comment.Content)
+
+	if comment.Content == "" {
+		w.WriteHeader(http.StatusBadRequest)
+		w.Write([]byte("content is required"))
+		return nil, false
+	}
+
+	if utf8.RuneCountInString(comment.Content) > MAX_COMMENT_LENGTH {
+		w.WriteHeader(http.StatusBadRequest)
+		w.Write([]byte("content must be at most " + strconv.Itoa(MAX_COMMENT_LENGTH) + " characters"))
+		return nil, false
+	}
+
+	return comment, true
+}
@@ -0,0 +1,168 @@
+package routes
+
+import (
+	"context"
+	"database/sql"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"go.uber.org/zap"
+	"redcellpartners.com/users-posts-api/model"
+	"redcellpartners.com/users-posts-api/store"
+)
+
+// fakeCommentStore keeps comments in a map and checks replies the way the
+// postgres store does, with a maximum depth of 1.
+type fakeCommentStore struct {
+	comments map[int]*model.Comment
+	users    map[int]bool
+	created  *model.Comment
+}
+
+func newFakeCommentStore() *fakeCommentStore {
+	return &fakeCommentStore{
+		comments: map[int]*model.Comment{
+			1: {ID: 1, PostID: 5, UserID: 1, Content: "top"},
+			2: {ID: 2, PostID: 5, UserID: 1, ParentID: intPointer(1), Depth: 1, Content: "reply"},
+			3: {ID: 3, PostID: 6, UserID: 1, Content: "other post"},
+		},
+		users: map[int]bool{1: true},
+	}
+}
+
+func (client *fakeCommentStore) ListComments(ctx context.Context, postID int) ([]*model.Comment, error) {
+	comments := make([]*model.Comment, 0)
+
+	for id := 1; id <= len(client.comments); id++ {
+		if comment := client.comments[id]; comment.PostID == postID {
+			comments = append(comments, comment)
+		}
+	}
+
+	return comments, nil
+}
+
+func (client *fakeCommentStore) CreateComment(ctx context.Context, comment *model.Comment) (*model.Comment, error) {
+	if !client.users[comment.UserID] {
+		return nil, store.ErrUnknownUser
+	}
+
+	if comment.ParentID != nil {
+		parent, ok := client.comments[*comment.ParentID]
+		if !ok || parent.PostID != comment.PostID {
+			return nil, store.ErrCommentParentNotFound
+		}
+
+		if comment.Depth = parent.Depth + 1; comment.Depth > 1 {
+			return nil, store.ErrCommentTooDeep
+		}
+	}
+
+	comment.ID = len(client.comments) + 1
+	client.created = comment
+
+	return comment, nil
+}
+
+func (client *fakeCommentStore) GetComment(ctx context.Context, id int) (*model.Comment, error) {
+	comment, ok := client.comments[id]
+	if !ok {
+		return nil, sql.ErrNoRows
+	}
+
+	return comment, nil
+}
+
+func (client *fakeCommentStore) UpdateComment(ctx context.Context, comment *model.Comment) (*model.Comment, error) {
+	if _, ok := client.comments[comment.ID]; !ok {
+		return nil, sql.ErrNoRows
+	}
+
+	return comment, nil
+}
+
+func (client *fakeCommentStore) DeleteComment(ctx context.Context, id int) error {
+	if _, ok := client.comments[id]; !ok {
+		return sql.ErrNoRows
+	}
+
+	return nil
+}
+
+func intPointer(i int) *int {
+	return &i
+}
+
+func TestPostCommentRoutes(t *testing.T) {
+	tests := []struct {
+		name    string
+		method  string
+		path    string
+		body    string
+		status  int
+		created bool
+	}{
+		{name: "list", method: "GET", path: "/5/comments", status: http.StatusOK},
+		{name: "comment", method: "POST", path: "/5/comments", body: `{"user_id": 1, "content": "hello"}`, status: http.StatusCreated, created: true},
+		{name: "reply", method: "POST", path: "/5/comments", body: `{"user_id": 1, "parent_id": 1, "content": "hello"}`, status: http.StatusCreated, created: true},
+		{name: "reply to a missing parent", method: "POST", path: "/5/comments", body: `{"user_id": 1, "parent_id": 99, "content": "hello"}`, status: http.StatusUnprocessableEntity},
+		{name: "reply to a parent on another post", method: "POST", path: "/5/comments", body: `{"user_id": 1, "parent_id": 3, "content": "hello"}`, status: http.StatusUnprocessableEntity},
+		{name: "reply too deep", method: "POST", path: "/5/comments", body: `{"user_id": 1, "parent_id": 2, "content": "hello"}`, status: http.StatusUnprocessableEntity},
+		{name: "unknown user", method: "POST", path: "/5/comments", body: `{"user_id": 7, "content": "hello"}`, status: http.StatusUnprocessableEntity},
+		{name: "missing user", method: "POST", path: "/5/comments", body: `{"content": "hello"}`, status: http.StatusBadRequest},
+		{name: "blank content", method: "POST", path: "/5/comments", body: `{"user_id": 1, "content": "  "}`, status: http.StatusBadRequest},
+		{name: "invalid body", method: "POST", path: "/5/comments", body: `[`, status: http.StatusBadRequest},
+		{name: "missing post", method: "POST", path: "/9/comments", body: `{"user_id": 1, "content": "hello"}`, status: http.StatusNotFound},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			postStore := &fakePostStore{post: &model.Post{ID: 5, Title: "Hello", Content: "Hello", Status: store.POST_STATUS_PUBLISHED}}
+			commentStore := newFakeCommentStore()
+
+			w := httptest.NewRecorder()
+			newPostsRouter(postStore, commentStore).ServeHTTP(w, httptest.NewRequest(test.method, test.path, strings.NewReader(test.body)))
+
+			if w.Code != test.status {
+				t.Errorf("expected status %d, got %d: %s", test.status, w.Code, w.Body.String())
+			}
+
+			if created := commentStore.created != nil; created != test.created {
+				t.Errorf("expected a comment to be created: %t, got %t", test.created, created)
+			}
+
+			if test.created && commentStore.created.PostID != 5 {
+				t.Errorf("expected the comment on post 5, got post %d", commentStore.created.PostID)
+			}
+		})
+	}
+}
+
+func TestCommentRoutes(t *testing.T) {
+	tests := []struct {
+		name   string
+		method string
+		path   string
+		body   string
+		status int
+	}{
+		{name: "get", method: "GET", path: "/1", status: http.StatusOK},
+		{name: "update", method: "PUT", path: "/1", body: `{"content": "edited"}`, status: http.StatusOK},
+		{name: "delete", method: "DELETE", path: "/1", status: http.StatusNoContent},
+		{name: "missing comment", method: "GET", path: "/99", status: http.StatusNotFound},
+		{name: "invalid id", method: "GET", path: "/abc", status: http.StatusBadRequest},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			w := httptest.NewRecorder()
+			NewCommentsResource(newFakeCommentStore(), zap.NewNop()).Routes().ServeHTTP(w, httptest.NewRequest(test.method, test.path, strings.NewReader(test.body)))
+
+			if w.Code != test.status {
+				t.Errorf("expected status %d, got %d: %s", test.status, w.Code, w.Body.String())
+			}
+		})
+	}
+}
@@ -0,0 +1,226 @@
+package routes
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+
+	"github.com/go-chi/chi"
+	"go.uber.org/zap"
+	"redcellpartners.com/users-posts-api/logging"
+	"redcellpartners.com/users-posts-api/model"
+	"redcellpartners.com/users-posts-api/store"
+)
+
+type FollowsResource struct {
+	followStore store.FollowStore
+	postStore   store.PostStore
+	logger      *zap.Logger
+}
+
+func NewFollowsResource(followStore store.FollowStore, postStore store.PostStore, logger *zap.Logger) *FollowsResource {
+	return &FollowsResource{
+		followStore: followStore,
+		postStore:   postStore,
+		logger:      logger,
+	}
+}
+
+// UserRoutes serves the follows and feed of a user. They are mounted below
+// /users/{id} behind the user exists check.
+func (resource *FollowsResource) UserRoutes(r chi.Router) {
+	r.Get("/following", resource.ListFollowing)
+	r.Put("/following/{target}", resource.Follow)
+	r.Delete("/following/{target}", resource.Unfollow)
+	r.Get("/followers", resource.ListFollowers)
+	r.Get("/feed", resource.Feed)
+}
+
+func (resource *FollowsResource) Follow(w http.ResponseWriter, r *http.Request) {
+	userID, targetID, ok := followIDs(w, r)
+	if !ok {
+		return
+	}
+
+	if userID == targetID {
+		w.WriteHeader(http.StatusBadRequest)
+		w.Write([]byte("a user can not follow themselves"))
+		return
+	}
+
+	err := resource.followStore.Follow(r.Context(), userID, targetID)
+	if errors.Is(err, store.ErrUnknownUser) {
+		w.WriteHeader(http.StatusNotFound)
+		w.Write([]byte(fmt.Sprintf("user with user_id: %d does not exist", targetID)))
+		return
+	} else if err != nil {
+		logging.FromContext(r.Context(), resource.logger).Error("unable to follow user", zap.Int("user_id", userID), zap.Int("target_id", targetID), zap.Error(err))
+		w.WriteHeader(http.StatusInternalServerError)
+		w.Write([]byte("unable to follow user at this time"))
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+func (resource *FollowsResource) Unfollow(w http.ResponseWriter, r *http.Request) {
+	userID, targetID, ok := followIDs(w, r)
+	if !ok {
+		return
+	}
+
+	if err := resource.followStore.Unfollow(r.Context(), userID, targetID); err != nil {
+		logging.FromContext(r.Context(), resource.logger).Error("unable to unfollow user", zap.Int("user_id", userID), zap.Int("target_id", targetID), zap.Error(err))
+		w.WriteHeader(http.StatusInternalServerError)
+		w.Write([]byte("unable to unfollow user at this time"))
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+func (resource *FollowsResource) ListFollowers(w http.ResponseWriter, r *http.Request) {
+	resource.listFollows(w, r, resource.followStore.ListFollowers)
+}
+
+func (resource *FollowsResource) ListFollowing(w http.ResponseWriter, r *http.Request) {
+	resource.listFollows(w, r, resource.followStore.ListFollowing)
+}
+
+func (resource *FollowsResource) listFollows(w http.ResponseWriter, r *http.Request, list func(ctx context.Context, userID int, page store.Page) ([]*model.Follow, error)) {
+	userID, err := strconv.Atoi(chi.URLParam(r, "id"))
+	if err != nil {
+		w.WriteHeader(http.StatusBadRequest)
+		w.Write([]byte("bad user id sent in request"))
+		return
+	}
+
+	page, ok := readPage(w, r)
+	if !ok {
+		return
+	}
+
+	follows, err := list(r.Context(), userID, page)
+	if err != nil {
+		logging.FromContext(r.Context(), resource.logger).Error("unable to list follows", zap.Int("user_id", userID), zap.Error(err))
+		w.WriteHeader(http.StatusInternalServerError)
+		w.Write([]byte("unable to list follows at this time"))
+		return
+	}
+
+	response := model.FollowPage{Follows: follows}
+	if len(follows) > 0 && len(follows) == page.Limit {
+		last := follows[len(follows)-1]
+		response.NextCursor = store.Cursor{Time: last.FollowedTime, ID: last.UserID}.String()
+	}
+
+	responseBody, err := json.Marshal(response)
+	if err != nil {
+		logging.FromContext(r.Context(), resource.logger).Error("unable to marshal follows", zap.Error(err))
+		w.WriteHeader(http.StatusInternalServerError)
+		return
+	}
+
+	w.Write(responseBody)
+}
+
+// Feed lists the posts of the users the user follows, newest first.
+func (resource *FollowsResource) Feed(w http.ResponseWriter, r *http.Request) {
+	userID, err := strconv.Atoi(chi.URLParam(r, "id"))
+	if err != nil {
+		w.WriteHeader(http.StatusBadRequest)
+		w.Write([]byte("bad user id sent in request"))
+		return
+	}
+
+	page, ok := readPage(w, r)
+	if !ok {
+		return
+	}
+
+	posts, err := resource.postStore.ListFeed(r.Context(), userID, page)
+	if err != nil {
+		logging.FromContext(r.Context(), resource.logger).Error("unable to list feed", zap.Int("user_id", userID), zap.Error(err))
+		w.WriteHeader(http.StatusInternalServerError)
+		w.Write([]byte("unable to list feed at this time"))
+		return
+	}
+
+	response := model.PostPage{Posts: posts}
+	if len(posts) > 0 && len(posts) == page.Limit {
+		last := posts[len(posts)-1]
+		response.NextCursor = store.Cursor{Time: *last.PublishedTime, ID: last.ID}.String()
+	}
+
+	responseBody, err := json.Marshal(response)
+	if err != nil {
+		logging.FromContext(r.Context(), resource.logger).Error("unable to marshal feed", zap.Error(err))
+		w.WriteHeader(http.StatusInternalServerError)
+		return
+	}
+
+	w.Write(responseBody)
+}
+
+func followIDs(w http.ResponseWriter, r *http.Request) (int, int, bool) {
+	userID, err := strconv.Atoi(chi.URLParam(r, "id"))
+	if err != nil {
+		w.WriteHeader(http.StatusBadRequest)
+		w.Write([]byte("bad user id sent in request"))
+		return 0, 0, false
+	}
+
+	targetID, err := strconv.Atoi(chi.URLParam(r, "target"))
+	if err != nil {
+		w.WriteHeader(http.StatusBadRequest)
+		w.Write([]byte("bad target user id sent in request"))
+		return 0, 0, false
+	}
+
+	return userID, targetID, true
+}
+
+// readPage reads the limit and cursor query parameters of a paginated
+// listing, the cursor being the next_cursor of the previous page.
+func readPage(w http.ResponseWriter, r *http.Request) (store.Page, bool) {
+	var (
+		page = store.Page{}
+		ok   bool
+		err  error
+	)
+
+	if page.Limit, ok = readLimit(w, r); !ok {
+		return page, false
+	}
+
+	if cursor := r.URL.Query().Get("cursor"); cursor != "" {
+		if page.After, err = store.ParseCursor(cursor); err != nil {
+			w.WriteHeader(http.StatusBadRequest)
+			w.Write([]byte("invalid cursor provided"))
+			return page, false
+		}
+	}
+
+	return page, true
+}
+
+// readLimit reads the limit query parameter of a paginated listing,
+// defaulting to DEFAULT_PAGE_LIMIT.
+func readLimit(w http.ResponseWriter, r *http.Request) (int, bool) {
+	limit := r.URL.Query().Get("limit")
+	if limit == "" {
+		return store.DEFAULT_PAGE_LIMIT, true
+	}
+
+	parsed, err := strconv.Atoi(limit)
+	if err != nil || parsed <= 0 || parsed > store.MAX_PAGE_LIMIT {
+		w.WriteHeader(http.StatusBadRequest)
+		w.Write([]byte(fmt.Sprintf("limit must be between 1 and %d", store.MAX_PAGE_LIMIT)))
+		return 0, false
+	}
+
+	return parsed, true
+}
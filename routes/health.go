@@ -0,0 +1,80 @@
+package routes
+
+import (
+	"encoding/json"
+	"net/http"
+
+	"go.uber.org/zap"
+	"redcellpartners.com/users-posts-api/health"
+)
+
+type HealthResource struct {
+	checker *health.Checker
+	logger  *zap.Logger
+}
+
+func NewHealthResource(checker *health.Checker, logger *zap.Logger) *HealthResource {
+	return &HealthResource{
+		checker: checker,
+		logger:  logger,
+	}
+}
+
+// Livez reports that the process is up and serving HTTP. It deliberately
+// checks no dependencies so a database outage does not restart every pod.
+func (resource *HealthResource) Livez(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Cache-Control", "no-store")
+	w.Write([]byte("ok"))
+}
+
+// Readyz reports whether the process should receive traffic: the database
+// answers within the check timeout, the schema is migrated and the server is
+// not shutting down.
+func (resource *HealthResource) Readyz(w http.ResponseWriter, r *http.Request) {
+	report := resource.checker.Check(r.Context())
+
+	w.Header().Set("Cache-Control", "no-store")
+
+	if report.Status != health.StatusOK {
+		for name, result := range report.Checks {
+			if result.Status != health.StatusOK {
+				resource.logger.Warn("readiness check failed", zap.String("check", name), zap.String("error", result.Error))
+			}
+		}
+
+		w.WriteHeader(http.StatusServiceUnavailable)
+		w.Write([]byte(report.Status))
+		return
+	}
+
+	w.Write([]byte(report.Status))
+}
+
+// Healthz returns a JSON report with the status of every dependency. Errors
+// can name database hosts and roles, so they are logged rather than returned.
+func (resource *HealthResource) Healthz(w http.ResponseWriter, r *http.Request) {
+	report := resource.checker.Check(r.Context())
+
+	for name, result := range report.Checks {
+		if result.Error != "" {
+			resource.logger.Warn("health check failed", zap.String("check", name), zap.String("error", result.Error))
+			result.Error = ""
+		}
+	}
+
+	responseBody, err := json.Marshal(report)
+	if err != nil {
+		resource.logger.Error("unable to marshal health report", zap.Error(err))
+		w.WriteHeader(http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Cache-Control", "no-store")
+	w.Header().Set("Content-Type", "application/json")
+
+	if report.Status != health.StatusOK {
+		w.WriteHeader(http.StatusServiceUnavailable)
+	}
+
+	w.Write(responseBody)
+}
@@ -0,0 +1,40 @@
+package routes
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"go.uber.org/zap"
+	"redcellpartners.com/users-posts-api/health"
+)
+
+func TestHealthzHidesCheckErrors(t *testing.T) {
+	checker := health.NewChecker(time.Second)
+	checker.AddCheck("postgres", func(ctx context.Context) error {
+		return errors.New(`pq: password authentication failed for user "api" at db.internal:5432`)
+	})
+
+	resource := NewHealthResource(checker, zap.NewNop())
+
+	w := httptest.NewRecorder()
+	resource.Healthz(w, httptest.NewRequest("GET", "/healthz", nil))
+
+	if w.Code != http.StatusServiceUnavailable {
+		t.Errorf("expected status 503, got %d", w.Code)
+	}
+
+	body := w.Body.String()
+
+	if !strings.Contains(body, `"postgres":{"status":"fail"`) {
+		t.Errorf("expected the postgres check to fail, got %s", body)
+	}
+
+	if strings.Contains(body, "db.internal") || strings.Contains(body, "error") {
+		t.Errorf("expected no check error in the response, got %s", body)
+	}
+}
@@ -1,27 +1,45 @@
 package routes
 
 import (
+	"database/sql"
 	"encoding/json"
+	"errors"
+	"fmt"
 	"io"
 	"net/http"
+	"path"
 	"strconv"
+	"strings"
+	"time"
+	"unicode/utf8"
 
 	"github.com/go-chi/chi"
+	"github.com/munnerz/goautoneg"
 	"go.uber.org/zap"
+	"redcellpartners.com/users-posts-api/logging"
+	"redcellpartners.com/users-posts-api/markdown"
 	"redcellpartners.com/users-posts-api/middleware"
 	"redcellpartners.com/users-posts-api/model"
 	"redcellpartners.com/users-posts-api/store"
 )
 
 type PostsResource struct {
-	postStore store.PostStore
-	logger    *zap.Logger
+	postStore         store.PostStore
+	renderer          *markdown.Renderer
+	commentsResource  *CommentsResource
+	reactionsResource *ReactionsResource
+	revisionsResource *RevisionsResource
+	logger            *zap.Logger
 }
 
-func NewPostsResource(postStore store.PostStore, logger *zap.Logger) *PostsResource {
+func NewPostsResource(postStore store.PostStore, renderer *markdown.Renderer, commentsResource *CommentsResource, reactionsResource *ReactionsResource, revisionsResource *RevisionsResource, logger *zap.Logger) *PostsResource {
 	return &PostsResource{
-		postStore: postStore,
-		logger:    logger,
+		postStore:         postStore,
+		renderer:          renderer,
+		commentsResource:  commentsResource,
+		reactionsResource: reactionsResource,
+		revisionsResource: revisionsResource,
+		logger:            logger,
 	}
 }
 
@@ -30,23 +48,93 @@ func (resource *PostsResource) Routes() chi.Router {
 
 	r.Get("/", resource.ListPosts)
 	r.Post("/", resource.CreatePost)
+	r.Get("/search", resource.SearchPosts)
+	r.Get("/by-slug/{slug}", resource.GetPostBySlug)
 
-	r.Route("/{id}", func(r chi.Router) {
-		postExistsMiddleware := middleware.NewPostExistsMiddleware(resource.postStore, resource.logger.Named("post_middleware"))
+	postExistsMiddleware := middleware.NewPostExistsMiddleware(resource.postStore, resource.logger.Named("post_middleware"))
+
+	r.With(postExistsMiddleware.PostExists).Post("/{id}:publish", resource.PublishPost)
+	r.With(postExistsMiddleware.PostExists).Post("/{id}:archive", resource.ArchivePost)
 
+	r.Route("/{id}", func(r chi.Router) {
 		r.Use(postExistsMiddleware.PostExists)
 		r.Get("/", resource.GetPost)
 		r.Put("/", resource.UpdatePost)
 		r.Delete("/", resource.DeletePost)
+		r.Mount("/comments", resource.commentsResource.PostRoutes())
+		r.Mount("/reactions", resource.reactionsResource.PostRoutes())
+		r.Mount("/revisions", resource.revisionsResource.PostRoutes())
 	})
 
 	return r
 }
 
+// AdminRoutes serves deleted posts to operators: listing them with
+// include_deleted=true and restoring them. It must only be mounted on the
+// internal admin listener, never on the public router.
+func (resource *PostsResource) AdminRoutes() chi.Router {
+	r := chi.NewRouter()
+
+	r.Get("/", resource.AdminListPosts)
+	r.Post("/{id}:restore", resource.RestorePost)
+
+	return r
+}
+
+// ListPosts lists published posts, or the posts in any of the status query
+// parameters, optionally only those tagged with any of the tag query
+// parameters, or all of them with match=all.
 func (resource *PostsResource) ListPosts(w http.ResponseWriter, r *http.Request) {
-	users, err := resource.postStore.ListPosts()
+	resource.listPosts(w, r, false)
+}
+
+// AdminListPosts lists posts like ListPosts, and also the deleted ones with
+// include_deleted=true.
+func (resource *PostsResource) AdminListPosts(w http.ResponseWriter, r *http.Request) {
+	resource.listPosts(w, r, true)
+}
+
+func (resource *PostsResource) listPosts(w http.ResponseWriter, r *http.Request, admin bool) {
+	query := r.URL.Query()
+
+	tags, err := store.NormalizeTags(query["tag"])
 	if err != nil {
-		resource.logger.Error("unable to list users", zap.Error(err))
+		w.WriteHeader(http.StatusBadRequest)
+		w.Write([]byte(err.Error()))
+		return
+	}
+
+	filter := store.PostFilter{Tags: tags}
+
+	if filter.IncludeDeleted, err = readIncludeDeleted(r, admin); err != nil {
+		w.WriteHeader(http.StatusBadRequest)
+		w.Write([]byte(err.Error()))
+		return
+	}
+
+	for _, status := range query["status"] {
+		if !store.ValidPostStatus(status) {
+			w.WriteHeader(http.StatusBadRequest)
+			w.Write([]byte("invalid status provided, expected draft, scheduled, published or archived"))
+			return
+		}
+
+		filter.Statuses = append(filter.Statuses, status)
+	}
+
+	switch query.Get("match") {
+	case "", "any":
+	case "all":
+		filter.MatchAllTags = true
+	default:
+		w.WriteHeader(http.StatusBadRequest)
+		w.Write([]byte("invalid match provided, expected any or all"))
+		return
+	}
+
+	users, err := resource.postStore.ListPosts(r.Context(), filter)
+	if err != nil {
+		logging.FromContext(r.Context(), resource.logger).Error("unable to list users", zap.Error(err))
 		w.WriteHeader(http.StatusInternalServerError)
 		w.Write([]byte("unable to list users at this time"))
 		return
@@ -54,7 +142,7 @@ func (resource *PostsResource) ListPosts(w http.ResponseWriter, r *http.Request)
 
 	responseBytes, err := json.Marshal(users)
 	if err != nil {
-		resource.logger.Error("unable to marshal users", zap.Error(err))
+		logging.FromContext(r.Context(), resource.logger).Error("unable to marshal users", zap.Error(err))
 		w.Write([]byte("unable to list users at this time"))
 		return
 	}
@@ -62,10 +150,69 @@ func (resource *PostsResource) ListPosts(w http.ResponseWriter, r *http.Request)
 	w.Write(responseBytes)
 }
 
+// SearchPosts lists the published posts matching the q query parameter,
+// best match first, with the matching words of each highlighted in a
+// snippet of its content.
+func (resource *PostsResource) SearchPosts(w http.ResponseWriter, r *http.Request) {
+	query := strings.TrimSpace(r.URL.Query().Get("q"))
+	if query == "" {
+		w.WriteHeader(http.StatusBadRequest)
+		w.Write([]byte("a search query must be provided in q"))
+		return
+	}
+
+	if utf8.RuneCountInString(query) > store.MAX_SEARCH_QUERY_LENGTH {
+		w.WriteHeader(http.StatusBadRequest)
+		w.Write([]byte(fmt.Sprintf("search query must be at most %d characters", store.MAX_SEARCH_QUERY_LENGTH)))
+		return
+	}
+
+	var (
+		page = store.SearchPage{}
+		ok   bool
+		err  error
+	)
+
+	if page.Limit, ok = readLimit(w, r); !ok {
+		return
+	}
+
+	if cursor := r.URL.Query().Get("cursor"); cursor != "" {
+		if page.After, err = store.ParseSearchCursor(cursor); err != nil {
+			w.WriteHeader(http.StatusBadRequest)
+			w.Write([]byte("invalid cursor provided"))
+			return
+		}
+	}
+
+	results, err := resource.postStore.SearchPosts(r.Context(), query, page)
+	if err != nil {
+		logging.FromContext(r.Context(), resource.logger).Error("unable to search posts", zap.Error(err))
+		w.WriteHeader(http.StatusInternalServerError)
+		w.Write([]byte("unable to search posts at this time"))
+		return
+	}
+
+	response := model.PostSearchPage{Results: results}
+	if len(results) > 0 && len(results) == page.Limit {
+		last := results[len(results)-1]
+		response.NextCursor = store.SearchCursor{Rank: last.Rank, ID: last.Post.ID}.String()
+	}
+
+	responseBody, err := json.Marshal(response)
+	if err != nil {
+		logging.FromContext(r.Context(), resource.logger).Error("unable to marshal search results", zap.Error(err))
+		w.WriteHeader(http.StatusInternalServerError)
+		return
+	}
+
+	w.Write(responseBody)
+}
+
 func (resource *PostsResource) CreatePost(w http.ResponseWriter, r *http.Request) {
 	body, err := io.ReadAll(r.Body)
 	if err != nil {
-		resource.logger.Error("unable to read request body", zap.Error(err))
+		logging.FromContext(r.Context(), resource.logger).Error("unable to read request body", zap.Error(err))
 		w.WriteHeader(http.StatusInternalServerError)
 		return
 	}
@@ -73,21 +220,45 @@ func (resource *PostsResource) CreatePost(w http.ResponseWriter, r *http.Request
 	var post *model.Post
 
 	if err = json.Unmarshal(body, &post); err != nil {
-		resource.logger.Error("unable to unmarshal body into post", zap.Error(err))
+		logging.FromContext(r.Context(), resource.logger).Error("unable to unmarshal body into post", zap.Error(err))
 		w.WriteHeader(http.StatusBadRequest)
 		return
 	}
 
-	created, err := resource.postStore.CreatePost(post)
-	if err != nil {
-		resource.logger.Error("unable to create post", zap.Error(err))
+	if post.Tags, err = store.NormalizeTags(post.Tags); err != nil {
+		w.WriteHeader(http.StatusBadRequest)
+		w.Write([]byte(err.Error()))
+		return
+	}
+
+	switch post.Status {
+	case "", store.POST_STATUS_DRAFT, store.POST_STATUS_PUBLISHED:
+	case store.POST_STATUS_SCHEDULED:
+		if post.PublishedTime == nil || !post.PublishedTime.After(time.Now()) {
+			w.WriteHeader(http.StatusBadRequest)
+			w.Write([]byte("a scheduled post needs a published_at in the future"))
+			return
+		}
+	default:
+		w.WriteHeader(http.StatusBadRequest)
+		w.Write([]byte("invalid status provided, a post is created as draft, scheduled or published"))
+		return
+	}
+
+	created, err := resource.postStore.CreatePost(r.Context(), post)
+	if errors.Is(err, store.ErrUnknownUser) {
+		w.WriteHeader(http.StatusUnprocessableEntity)
+		w.Write([]byte(err.Error()))
+		return
+	} else if err != nil {
+		logging.FromContext(r.Context(), resource.logger).Error("unable to create post", zap.Error(err))
 		w.WriteHeader(http.StatusInternalServerError)
 		return
 	}
 
 	responseBody, err := json.Marshal(created)
 	if err != nil {
-		resource.logger.Error("unable to marshal created post", zap.Error(err))
+		logging.FromContext(r.Context(), resource.logger).Error("unable to marshal created post", zap.Error(err))
 		w.WriteHeader(http.StatusInternalServerError)
 		return
 	}
@@ -106,16 +277,82 @@ func (resource *PostsResource) GetPost(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	post, err := resource.postStore.GetPost(postIDInt)
+	post, err := resource.postStore.GetPost(r.Context(), postIDInt)
 	if err != nil {
 		w.WriteHeader(http.StatusInternalServerError)
 		w.Write([]byte("unable to get user at this time"))
 		return
 	}
 
+	resource.writePost(w, r, post)
+}
+
+// GetPostBySlug gets a post by slug rather than id. A slug the post had
+// before its title changed redirects to its current slug.
+func (resource *PostsResource) GetPostBySlug(w http.ResponseWriter, r *http.Request) {
+	slug := chi.URLParam(r, "slug")
+
+	post, err := resource.postStore.GetPostBySlug(r.Context(), slug)
+	if err != nil && err == sql.ErrNoRows {
+		w.WriteHeader(http.StatusNotFound)
+		w.Write([]byte(fmt.Sprintf("post with slug: %s does not exist", slug)))
+		return
+	} else if err != nil {
+		logging.FromContext(r.Context(), resource.logger).Error("unable to get post by slug", zap.String("slug", slug), zap.Error(err))
+		w.WriteHeader(http.StatusInternalServerError)
+		w.Write([]byte("unable to get post at this time"))
+		return
+	}
+
+	if post.Slug != slug {
+		redirect := path.Join(path.Dir(r.URL.Path), post.Slug)
+		if r.URL.RawQuery != "" {
+			redirect += "?" + r.URL.RawQuery
+		}
+
+		http.Redirect(w, r, redirect, http.StatusMovedPermanently)
+		return
+	}
+
+	resource.writePost(w, r, post)
+}
+
+// writePost writes a post as JSON, or its content rendered to sanitized HTML
+// when asked for with render=html or an Accept header preferring text/html.
+func (resource *PostsResource) writePost(w http.ResponseWriter, r *http.Request, post *model.Post) {
+	format := r.URL.Query().Get("render")
+
+	switch format {
+	case "":
+		if goautoneg.Negotiate(r.Header.Get("Accept"), []string{"application/json", "text/html"}) == "text/html" {
+			format = "html"
+		}
+	case "html", "json":
+	default:
+		w.WriteHeader(http.StatusBadRequest)
+		w.Write([]byte("invalid render provided, expected html or json"))
+		return
+	}
+
+	w.Header().Add("Vary", "Accept")
+
+	if format == "html" {
+		rendered, err := resource.renderer.Render(post.ID, post.Revision, post.Content)
+		if err != nil {
+			logging.FromContext(r.Context(), resource.logger).Error("unable to render post", zap.Int("post_id", post.ID), zap.Error(err))
+			w.WriteHeader(http.StatusInternalServerError)
+			w.Write([]byte("unable to render post at this time"))
+			return
+		}
+
+		w.Header().Set("Content-Type", "text/html; charset=utf-8")
+		w.Write([]byte(rendered.HTML))
+		return
+	}
+
 	responseBody, err := json.Marshal(post)
 	if err != nil {
-		resource.logger.Error("unable to marshal post", zap.Error(err))
+		logging.FromContext(r.Context(), resource.logger).Error("unable to marshal post", zap.Error(err))
 		w.WriteHeader(http.StatusInternalServerError)
 		return
 	}
@@ -135,7 +372,7 @@ func (resource *PostsResource) UpdatePost(w http.ResponseWriter, r *http.Request
 
 	body, err := io.ReadAll(r.Body)
 	if err != nil {
-		resource.logger.Error("unable to read request body", zap.Error(err))
+		logging.FromContext(r.Context(), resource.logger).Error("unable to read request body", zap.Error(err))
 		w.WriteHeader(http.StatusInternalServerError)
 		return
 	}
@@ -143,16 +380,26 @@ func (resource *PostsResource) UpdatePost(w http.ResponseWriter, r *http.Request
 	var post *model.Post
 
 	if err = json.Unmarshal(body, &post); err != nil {
-		resource.logger.Error("unable to unmarshal body into user", zap.Error(err))
+		logging.FromContext(r.Context(), resource.logger).Error("unable to unmarshal body into user", zap.Error(err))
 		w.WriteHeader(http.StatusBadRequest)
 		return
 	}
 
+	if post.Tags, err = store.NormalizeTags(post.Tags); err != nil {
+		w.WriteHeader(http.StatusBadRequest)
+		w.Write([]byte(err.Error()))
+		return
+	}
+
 	post.ID = postIDInt
 
-	updatedUser, err := resource.postStore.UpdatePost(post)
-	if err != nil {
-		resource.logger.Error("unable to update post", zap.Int("post_id", post.ID), zap.Error(err))
+	updatedUser, err := resource.postStore.UpdatePost(r.Context(), post)
+	if err != nil && err == sql.ErrNoRows {
+		w.WriteHeader(http.StatusNotFound)
+		w.Write([]byte(fmt.Sprintf("post with post_id: %d does not exist", postIDInt)))
+		return
+	} else if err != nil {
+		logging.FromContext(r.Context(), resource.logger).Error("unable to update post", zap.Int("post_id", post.ID), zap.Error(err))
 		w.WriteHeader(http.StatusInternalServerError)
 		w.Write([]byte("unable to get updated post at this time"))
 		return
@@ -160,7 +407,7 @@ func (resource *PostsResource) UpdatePost(w http.ResponseWriter, r *http.Request
 
 	responseBody, err := json.Marshal(updatedUser)
 	if err != nil {
-		resource.logger.Error("unable to marshal updated user", zap.Error(err))
+		logging.FromContext(r.Context(), resource.logger).Error("unable to marshal updated user", zap.Error(err))
 		w.WriteHeader(http.StatusInternalServerError)
 		return
 	}
@@ -178,8 +425,12 @@ func (resource *PostsResource) DeletePost(w http.ResponseWriter, r *http.Request
 		return
 	}
 
-	err = resource.postStore.DeletePost(postIDInt)
-	if err != nil {
+	err = resource.postStore.DeletePost(r.Context(), postIDInt)
+	if err != nil && err == sql.ErrNoRows {
+		w.WriteHeader(http.StatusNotFound)
+		w.Write([]byte(fmt.Sprintf("post with post_id: %d does not exist", postIDInt)))
+		return
+	} else if err != nil {
 		w.WriteHeader(http.StatusInternalServerError)
 		w.Write([]byte("unable to delete user at this time"))
 		return
@@ -187,3 +438,118 @@ func (resource *PostsResource) DeletePost(w http.ResponseWriter, r *http.Request
 
 	w.WriteHeader(http.StatusNoContent)
 }
+
+// RestorePost brings back a deleted post, unless its user is deleted too.
+func (resource *PostsResource) RestorePost(w http.ResponseWriter, r *http.Request) {
+	postID, err := strconv.Atoi(chi.URLParam(r, "id"))
+	if err != nil {
+		w.WriteHeader(http.StatusBadRequest)
+		w.Write([]byte("bad post id sent in request"))
+		return
+	}
+
+	post, err := resource.postStore.RestorePost(r.Context(), postID)
+	if err != nil && err == sql.ErrNoRows {
+		w.WriteHeader(http.StatusNotFound)
+		w.Write([]byte(fmt.Sprintf("deleted post with post_id: %d does not exist", postID)))
+		return
+	} else if errors.Is(err, store.ErrPostOwnerDeleted) {
+		w.WriteHeader(http.StatusConflict)
+		w.Write([]byte(err.Error()))
+		return
+	} else if err != nil {
+		logging.FromContext(r.Context(), resource.logger).Error("unable to restore post", zap.Int("post_id", postID), zap.Error(err))
+		w.WriteHeader(http.StatusInternalServerError)
+		w.Write([]byte("unable to restore post at this time"))
+		return
+	}
+
+	responseBody, err := json.Marshal(post)
+	if err != nil {
+		logging.FromContext(r.Context(), resource.logger).Error("unable to marshal restored post", zap.Error(err))
+		w.WriteHeader(http.StatusInternalServerError)
+		return
+	}
+
+	w.Write(responseBody)
+}
+
+type publishRequest struct {
+	PublishAt *time.Time `json:"publish_at"`
+}
+
+// PublishPost publishes a post now, or schedules it when the body holds a
+// publish_at in the future.
+func (resource *PostsResource) PublishPost(w http.ResponseWriter, r *http.Request) {
+	postID, err := strconv.Atoi(chi.URLParam(r, "id"))
+	if err != nil {
+		w.WriteHeader(http.StatusBadRequest)
+		w.Write([]byte("bad post id sent in request"))
+		return
+	}
+
+	body, err := io.ReadAll(r.Body)
+	if err != nil {
+		logging.FromContext(r.Context(), resource.logger).Error("unable to read request body", zap.Error(err))
+		w.WriteHeader(http.StatusInternalServerError)
+		return
+	}
+
+	publishAt := time.Now()
+
+	if len(body) > 0 {
+		var request publishRequest
+
+		if err = json.Unmarshal(body, &request); err != nil {
+			w.WriteHeader(http.StatusBadRequest)
+			w.Write([]byte("invalid publish request, expected {\"publish_at\": \"<RFC 3339 timestamp>\"}"))
+			return
+		}
+
+		if request.PublishAt != nil {
+			publishAt = *request.PublishAt
+		}
+	}
+
+	post, err := resource.postStore.PublishPost(r.Context(), postID, publishAt)
+	resource.writeStatusChange(w, r, postID, post, err)
+}
+
+func (resource *PostsResource) ArchivePost(w http.ResponseWriter, r *http.Request) {
+	postID, err := strconv.Atoi(chi.URLParam(r, "id"))
+	if err != nil {
+		w.WriteHeader(http.StatusBadRequest)
+		w.Write([]byte("bad post id sent in request"))
+		return
+	}
+
+	post, err := resource.postStore.ArchivePost(r.Context(), postID)
+	resource.writeStatusChange(w, r, postID, post, err)
+}
+
+func (resource *PostsResource) writeStatusChange(w http.ResponseWriter, r *http.Request, postID int, post *model.Post, err error) {
+	// the post can be deleted between the exists check and the status change
+	if err != nil && err == sql.ErrNoRows {
+		w.WriteHeader(http.StatusNotFound)
+		w.Write([]byte(fmt.Sprintf("post with post_id: %d does not exist", postID)))
+		return
+	} else if errors.Is(err, store.ErrInvalidPostTransition) {
+		w.WriteHeader(http.StatusConflict)
+		w.Write([]byte(err.Error()))
+		return
+	} else if err != nil {
+		logging.FromContext(r.Context(), resource.logger).Error("unable to change post status", zap.Int("post_id", postID), zap.Error(err))
+		w.WriteHeader(http.StatusInternalServerError)
+		w.Write([]byte("unable to change post status at this time"))
+		return
+	}
+
+	responseBody, err := json.Marshal(post)
+	if err != nil {
+		logging.FromContext(r.Context(), resource.logger).Error("unable to marshal post", zap.Error(err))
+		w.WriteHeader(http.StatusInternalServerError)
+		return
+	}
+
+	w.Write(responseBody)
+}
@@ -0,0 +1,214 @@
+package routes
+
+import (
+	"context"
+	"database/sql"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"go.uber.org/zap"
+	"redcellpartners.com/users-posts-api/markdown"
+	"redcellpartners.com/users-posts-api/model"
+	"redcellpartners.com/users-posts-api/store"
+)
+
+// fakePostStore holds a single post and records which of its methods the
+// routes call. Methods the tests do not reach are left to the embedded nil
+// interface.
+type fakePostStore struct {
+	store.PostStore
+	post *model.Post
+	// changeErr is returned by the status changes, as when the post is
+	// deleted or its lifecycle does not allow the change.
+	changeErr error
+	filter    store.PostFilter
+	calls     []string
+}
+
+func (client *fakePostStore) GetPost(ctx context.Context, id int) (*model.Post, error) {
+	client.calls = append(client.calls, "GetPost")
+
+	if client.post == nil || client.post.ID != id {
+		return nil, sql.ErrNoRows
+	}
+
+	return client.post, nil
+}
+
+func (client *fakePostStore) CreatePost(ctx context.Context, post *model.Post) (*model.Post, error) {
+	client.calls = append(client.calls, "CreatePost")
+
+	// user 1 is the only live user
+	if post.CreatedByUser != 1 {
+		return nil, store.ErrUnknownUser
+	}
+
+	return post, nil
+}
+
+func (client *fakePostStore) ListPosts(ctx context.Context, filter store.PostFilter) ([]*model.Post, error) {
+	client.calls = append(client.calls, "ListPosts")
+	client.filter = filter
+
+	return []*model.Post{}, nil
+}
+
+func (client *fakePostStore) RestorePost(ctx context.Context, id int) (*model.Post, error) {
+	client.calls = append(client.calls, "RestorePost")
+
+	return client.post, nil
+}
+
+func (client *fakePostStore) PublishPost(ctx context.Context, id int, publishAt time.Time) (*model.Post, error) {
+	client.calls = append(client.calls, "PublishPost")
+
+	return client.changeStatus(store.POST_STATUS_PUBLISHED)
+}
+
+func (client *fakePostStore) ArchivePost(ctx context.Context, id int) (*model.Post, error) {
+	client.calls = append(client.calls, "ArchivePost")
+
+	return client.changeStatus(store.POST_STATUS_ARCHIVED)
+}
+
+func (client *fakePostStore) changeStatus(status string) (*model.Post, error) {
+	if client.changeErr != nil {
+		return nil, client.changeErr
+	}
+
+	post := *client.post
+	post.Status = status
+
+	return &post, nil
+}
+
+func newPostsRouter(postStore store.PostStore, commentStore store.CommentStore) http.Handler {
+	return NewPostsResource(
+		postStore,
+		markdown.NewRenderer(0),
+		NewCommentsResource(commentStore, zap.NewNop()),
+		NewReactionsResource(nil, zap.NewNop()),
+		NewRevisionsResource(postStore, zap.NewNop()),
+		zap.NewNop(),
+	).Routes()
+}
+
+func TestPostStatusRoutes(t *testing.T) {
+	tests := []struct {
+		name      string
+		method    string
+		path      string
+		missing   bool
+		changeErr error
+		status    int
+		calls     []string
+		body      string
+	}{
+		{name: "publish", method: "POST", path: "/5:publish", status: http.StatusOK, calls: []string{"GetPost", "PublishPost"}, body: `"status":"published"`},
+		{name: "archive", method: "POST", path: "/5:archive", status: http.StatusOK, calls: []string{"GetPost", "ArchivePost"}, body: `"status":"archived"`},
+		{name: "get is not a status change", method: "GET", path: "/5", status: http.StatusOK, calls: []string{"GetPost", "GetPost"}, body: `"status":"draft"`},
+		// any other method falls through to /{id}, with 5:publish as the id
+		{name: "get of an action is not a post id", method: "GET", path: "/5:publish", status: http.StatusBadRequest},
+		{name: "unknown action", method: "POST", path: "/5:pin", status: http.StatusBadRequest},
+		{name: "invalid id", method: "POST", path: "/abc:publish", status: http.StatusBadRequest},
+		{name: "missing post", method: "POST", path: "/5:publish", missing: true, status: http.StatusNotFound, calls: []string{"GetPost"}},
+		{name: "post deleted before the change", method: "POST", path: "/5:archive", changeErr: sql.ErrNoRows, status: http.StatusNotFound, calls: []string{"GetPost", "ArchivePost"}},
+		{name: "transition not allowed", method: "POST", path: "/5:archive", changeErr: store.ErrInvalidPostTransition, status: http.StatusConflict, calls: []string{"GetPost", "ArchivePost"}},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			postStore := &fakePostStore{changeErr: test.changeErr}
+			if !test.missing {
+				postStore.post = &model.Post{ID: 5, CreatedByUser: 1, Title: "Hello", Content: "Hello", Status: store.POST_STATUS_DRAFT}
+			}
+
+			w := httptest.NewRecorder()
+			newPostsRouter(postStore, nil).ServeHTTP(w, httptest.NewRequest(test.method, test.path, nil))
+
+			if w.Code != test.status {
+				t.Errorf("expected status %d, got %d: %s", test.status, w.Code, w.Body.String())
+			}
+
+			if strings.Join(postStore.calls, ",") != strings.Join(test.calls, ",") {
+				t.Errorf("expected store calls %v, got %v", test.calls, postStore.calls)
+			}
+
+			if !strings.Contains(w.Body.String(), test.body) {
+				t.Errorf("expected the body to contain %s, got %s", test.body, w.Body.String())
+			}
+		})
+	}
+}
+
+func TestPostAdminRoutes(t *testing.T) {
+	tests := []struct {
+		name           string
+		admin          bool
+		method         string
+		path           string
+		status         int
+		calls          []string
+		includeDeleted bool
+	}{
+		{name: "public list", method: "GET", path: "/", status: http.StatusOK, calls: []string{"ListPosts"}},
+		{name: "public list without deleted posts", method: "GET", path: "/?include_deleted=false", status: http.StatusOK, calls: []string{"ListPosts"}},
+		{name: "public list of deleted posts", method: "GET", path: "/?include_deleted=true", status: http.StatusBadRequest},
+		{name: "public restore", method: "POST", path: "/5:restore", status: http.StatusBadRequest},
+		{name: "admin list of deleted posts", admin: true, method: "GET", path: "/?include_deleted=true", status: http.StatusOK, calls: []string{"ListPosts"}, includeDeleted: true},
+		{name: "admin restore", admin: true, method: "POST", path: "/5:restore", status: http.StatusOK, calls: []string{"RestorePost"}},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			postStore := &fakePostStore{post: &model.Post{ID: 5, Title: "Hello", Content: "Hello", Status: store.POST_STATUS_PUBLISHED}}
+
+			resource := NewPostsResource(postStore, markdown.NewRenderer(0), NewCommentsResource(nil, zap.NewNop()), NewReactionsResource(nil, zap.NewNop()), NewRevisionsResource(postStore, zap.NewNop()), zap.NewNop())
+
+			router := resource.Routes()
+			if test.admin {
+				router = resource.AdminRoutes()
+			}
+
+			w := httptest.NewRecorder()
+			router.ServeHTTP(w, httptest.NewRequest(test.method, test.path, nil))
+
+			if w.Code != test.status {
+				t.Errorf("expected status %d, got %d: %s", test.status, w.Code, w.Body.String())
+			}
+
+			if strings.Join(postStore.calls, ",") != strings.Join(test.calls, ",") {
+				t.Errorf("expected store calls %v, got %v", test.calls, postStore.calls)
+			}
+
+			if postStore.filter.IncludeDeleted != test.includeDeleted {
+				t.Errorf("expected include deleted %t, got %t", test.includeDeleted, postStore.filter.IncludeDeleted)
+			}
+		})
+	}
+}
+
+func TestCreatePostRoute(t *testing.T) {
+	tests := []struct {
+		name   string
+		body   string
+		status int
+	}{
+		{name: "create", body: `{"user_id": 1, "title": "Hello", "content": "Hello"}`, status: http.StatusCreated},
+		{name: "deleted or unknown user", body: `{"user_id": 2, "title": "Hello", "content": "Hello"}`, status: http.StatusUnprocessableEntity},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			w := httptest.NewRecorder()
+			newPostsRouter(&fakePostStore{}, nil).ServeHTTP(w, httptest.NewRequest("POST", "/", strings.NewReader(test.body)))
+
+			if w.Code != test.status {
+				t.Errorf("expected status %d, got %d: %s", test.status, w.Code, w.Body.String())
+			}
+		})
+	}
+}
@@ -0,0 +1,88 @@
+package routes
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"strconv"
+
+	"github.com/go-chi/chi"
+	"go.uber.org/zap"
+	"redcellpartners.com/users-posts-api/logging"
+	"redcellpartners.com/users-posts-api/middleware"
+	"redcellpartners.com/users-posts-api/store"
+)
+
+type ReactionsResource struct {
+	reactionStore store.ReactionStore
+	logger        *zap.Logger
+}
+
+func NewReactionsResource(reactionStore store.ReactionStore, logger *zap.Logger) *ReactionsResource {
+	return &ReactionsResource{
+		reactionStore: reactionStore,
+		logger:        logger,
+	}
+}
+
+// PostRoutes serves the caller's reactions on a post. It is mounted below
+// /posts/{id} behind the post exists check.
+func (resource *ReactionsResource) PostRoutes() chi.Router {
+	r := chi.NewRouter()
+
+	r.Put("/{kind}", resource.AddReaction)
+	r.Delete("/{kind}", resource.RemoveReaction)
+
+	return r
+}
+
+// AddReaction leaves the caller's reaction of the given kind on the post,
+// reacting again with the same kind changes nothing.
+func (resource *ReactionsResource) AddReaction(w http.ResponseWriter, r *http.Request) {
+	resource.changeReaction(w, r, resource.reactionStore.AddReaction)
+}
+
+// RemoveReaction takes back the caller's reaction of the given kind, removing
+// a reaction that was never left changes nothing.
+func (resource *ReactionsResource) RemoveReaction(w http.ResponseWriter, r *http.Request) {
+	resource.changeReaction(w, r, resource.reactionStore.RemoveReaction)
+}
+
+func (resource *ReactionsResource) changeReaction(w http.ResponseWriter, r *http.Request, change func(ctx context.Context, postID, userID int, kind string) error) {
+	postID, err := strconv.Atoi(chi.URLParam(r, "id"))
+	if err != nil {
+		w.WriteHeader(http.StatusBadRequest)
+		w.Write([]byte("bad post id sent in request"))
+		return
+	}
+
+	kind := chi.URLParam(r, "kind")
+	if !store.ValidReactionKind(kind) {
+		w.WriteHeader(http.StatusBadRequest)
+		w.Write([]byte("unknown reaction kind"))
+		return
+	}
+
+	// the X-User-ID header is not verified, reacting with it would let any
+	// caller react as any user
+	userID, ok := middleware.VerifiedUserID(r.Context())
+	if !ok {
+		w.WriteHeader(http.StatusUnauthorized)
+		w.Write([]byte("a verified client certificate with the user id as its common name is required to react to a post"))
+		return
+	}
+
+	err = change(r.Context(), postID, userID, kind)
+	if errors.Is(err, store.ErrUnknownUser) {
+		w.WriteHeader(http.StatusUnprocessableEntity)
+		w.Write([]byte(err.Error()))
+		return
+	} else if err != nil {
+		logging.FromContext(r.Context(), resource.logger).Error("unable to change reaction", zap.Int("post_id", postID), zap.String("kind", kind), zap.Error(err))
+		w.WriteHeader(http.StatusInternalServerError)
+		w.Write([]byte("unable to change reaction at this time"))
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
@@ -0,0 +1,83 @@
+package routes
+
+import (
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/go-chi/chi"
+	"go.uber.org/zap"
+	"redcellpartners.com/users-posts-api/middleware"
+)
+
+// fakeReactionStore records the user of the last reaction change.
+type fakeReactionStore struct {
+	userID int
+}
+
+func (client *fakeReactionStore) AddReaction(ctx context.Context, postID, userID int, kind string) error {
+	client.userID = userID
+	return nil
+}
+
+func (client *fakeReactionStore) RemoveReaction(ctx context.Context, postID, userID int, kind string) error {
+	client.userID = userID
+	return nil
+}
+
+func TestReactionRoutes(t *testing.T) {
+	tests := []struct {
+		name       string
+		method     string
+		path       string
+		commonName string
+		header     string
+		status     int
+		userID     int
+	}{
+		{name: "add", method: "PUT", path: "/5/reactions/like", commonName: "7", status: http.StatusNoContent, userID: 7},
+		{name: "remove", method: "DELETE", path: "/5/reactions/like", commonName: "7", status: http.StatusNoContent, userID: 7},
+		{name: "certificate wins over the header", method: "PUT", path: "/5/reactions/like", commonName: "7", header: "8", status: http.StatusNoContent, userID: 7},
+		{name: "header alone is not trusted", method: "PUT", path: "/5/reactions/like", header: "8", status: http.StatusUnauthorized},
+		{name: "certificate not naming a user", method: "PUT", path: "/5/reactions/like", commonName: "billing", status: http.StatusUnauthorized},
+		{name: "unknown kind", method: "PUT", path: "/5/reactions/meh", commonName: "7", status: http.StatusBadRequest},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			reactionStore := &fakeReactionStore{}
+
+			router := chi.NewRouter()
+			router.Use(middleware.ClientIdentityMiddleware)
+			router.Mount("/{id}/reactions", NewReactionsResource(reactionStore, zap.NewNop()).PostRoutes())
+
+			r := httptest.NewRequest(test.method, test.path, nil)
+			if test.commonName != "" {
+				r.TLS = &tls.ConnectionState{VerifiedChains: [][]*x509.Certificate{{{
+					Subject:      pkix.Name{CommonName: test.commonName},
+					SerialNumber: big.NewInt(1),
+				}}}}
+			}
+
+			if test.header != "" {
+				r.Header.Set(middleware.UserIDHeader, test.header)
+			}
+
+			w := httptest.NewRecorder()
+			router.ServeHTTP(w, r)
+
+			if w.Code != test.status {
+				t.Errorf("expected status %d, got %d: %s", test.status, w.Code, w.Body.String())
+			}
+
+			if reactionStore.userID != test.userID {
+				t.Errorf("expected a reaction by user %d, got user %d", test.userID, reactionStore.userID)
+			}
+		})
+	}
+}
@@ -0,0 +1,205 @@
+package routes
+
+import (
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strconv"
+
+	"github.com/go-chi/chi"
+	"github.com/pmezard/go-difflib/difflib"
+	"go.uber.org/zap"
+	"redcellpartners.com/users-posts-api/logging"
+	"redcellpartners.com/users-posts-api/model"
+	"redcellpartners.com/users-posts-api/store"
+)
+
+type RevisionsResource struct {
+	postStore store.PostStore
+	logger    *zap.Logger
+}
+
+func NewRevisionsResource(postStore store.PostStore, logger *zap.Logger) *RevisionsResource {
+	return &RevisionsResource{
+		postStore: postStore,
+		logger:    logger,
+	}
+}
+
+// PostRoutes serves the revisions of a post. It is mounted below /posts/{id}
+// behind the post exists check.
+func (resource *RevisionsResource) PostRoutes() chi.Router {
+	r := chi.NewRouter()
+
+	r.Get("/", resource.ListRevisions)
+	r.Get("/{rev}", resource.GetRevision)
+	r.Get("/{rev}/diff", resource.DiffRevision)
+	r.Post("/{rev}:restore", resource.RestoreRevision)
+
+	return r
+}
+
+func (resource *RevisionsResource) ListRevisions(w http.ResponseWriter, r *http.Request) {
+	postID, err := strconv.Atoi(chi.URLParam(r, "id"))
+	if err != nil {
+		w.WriteHeader(http.StatusBadRequest)
+		w.Write([]byte("bad post id sent in request"))
+		return
+	}
+
+	revisions, err := resource.postStore.ListPostRevisions(r.Context(), postID)
+	if err != nil {
+		logging.FromContext(r.Context(), resource.logger).Error("unable to list revisions", zap.Int("post_id", postID), zap.Error(err))
+		w.WriteHeader(http.StatusInternalServerError)
+		w.Write([]byte("unable to list revisions at this time"))
+		return
+	}
+
+	responseBody, err := json.Marshal(revisions)
+	if err != nil {
+		logging.FromContext(r.Context(), resource.logger).Error("unable to marshal revisions", zap.Error(err))
+		w.WriteHeader(http.StatusInternalServerError)
+		return
+	}
+
+	w.Write(responseBody)
+}
+
+func (resource *RevisionsResource) GetRevision(w http.ResponseWriter, r *http.Request) {
+	postID, revision, ok := revisionIDs(w, r)
+	if !ok {
+		return
+	}
+
+	postRevision, ok := resource.getRevision(w, r, postID, revision)
+	if !ok {
+		return
+	}
+
+	responseBody, err := json.Marshal(postRevision)
+	if err != nil {
+		logging.FromContext(r.Context(), resource.logger).Error("unable to marshal revision", zap.Error(err))
+		w.WriteHeader(http.StatusInternalServerError)
+		return
+	}
+
+	w.Write(responseBody)
+}
+
+// DiffRevision returns a unified diff from the revision given by the from
+// query parameter, the previous revision by default, to {rev}.
+func (resource *RevisionsResource) DiffRevision(w http.ResponseWriter, r *http.Request) {
+	postID, revision, ok := revisionIDs(w, r)
+	if !ok {
+		return
+	}
+
+	fromRevision := revision - 1
+
+	if from := r.URL.Query().Get("from"); from != "" {
+		var err error
+		if fromRevision, err = strconv.Atoi(from); err != nil {
+			w.WriteHeader(http.StatusBadRequest)
+			w.Write([]byte("bad from revision sent in request"))
+			return
+		}
+	}
+
+	from, ok := resource.getRevision(w, r, postID, fromRevision)
+	if !ok {
+		return
+	}
+
+	to, ok := resource.getRevision(w, r, postID, revision)
+	if !ok {
+		return
+	}
+
+	diff, err := difflib.GetUnifiedDiffString(difflib.UnifiedDiff{
+		A:        difflib.SplitLines(revisionText(from)),
+		B:        difflib.SplitLines(revisionText(to)),
+		FromFile: fmt.Sprintf("post/%d@%d", postID, from.Revision),
+		ToFile:   fmt.Sprintf("post/%d@%d", postID, to.Revision),
+		Context:  3,
+	})
+	if err != nil {
+		logging.FromContext(r.Context(), resource.logger).Error("unable to diff revisions", zap.Int("post_id", postID), zap.Error(err))
+		w.WriteHeader(http.StatusInternalServerError)
+		w.Write([]byte("unable to diff revisions at this time"))
+		return
+	}
+
+	w.Header().Set("Content-Type", "text/x-diff; charset=utf-8")
+	w.Write([]byte(diff))
+}
+
+// RestoreRevision makes the title and content of {rev} the post's newest
+// revision.
+func (resource *RevisionsResource) RestoreRevision(w http.ResponseWriter, r *http.Request) {
+	postID, revision, ok := revisionIDs(w, r)
+	if !ok {
+		return
+	}
+
+	post, err := resource.postStore.RestorePostRevision(r.Context(), postID, revision)
+	if err != nil && err == sql.ErrNoRows {
+		w.WriteHeader(http.StatusNotFound)
+		w.Write([]byte(fmt.Sprintf("revision %d of post %d does not exist", revision, postID)))
+		return
+	} else if err != nil {
+		logging.FromContext(r.Context(), resource.logger).Error("unable to restore revision", zap.Int("post_id", postID), zap.Int("revision", revision), zap.Error(err))
+		w.WriteHeader(http.StatusInternalServerError)
+		w.Write([]byte("unable to restore revision at this time"))
+		return
+	}
+
+	responseBody, err := json.Marshal(post)
+	if err != nil {
+		logging.FromContext(r.Context(), resource.logger).Error("unable to marshal restored post", zap.Error(err))
+		w.WriteHeader(http.StatusInternalServerError)
+		return
+	}
+
+	w.Write(responseBody)
+}
+
+func (resource *RevisionsResource) getRevision(w http.ResponseWriter, r *http.Request, postID, revision int) (*model.PostRevision, bool) {
+	postRevision, err := resource.postStore.GetPostRevision(r.Context(), postID, revision)
+	if err != nil && err == sql.ErrNoRows {
+		w.WriteHeader(http.StatusNotFound)
+		w.Write([]byte(fmt.Sprintf("revision %d of post %d does not exist", revision, postID)))
+		return nil, false
+	} else if err != nil {
+		logging.FromContext(r.Context(), resource.logger).Error("unable to get revision", zap.Int("post_id", postID), zap.Int("revision", revision), zap.Error(err))
+		w.WriteHeader(http.StatusInternalServerError)
+		w.Write([]byte("unable to get revision at this time"))
+		return nil, false
+	}
+
+	return postRevision, true
+}
+
+func revisionIDs(w http.ResponseWriter, r *http.Request) (int, int, bool) {
+	postID, err := strconv.Atoi(chi.URLParam(r, "id"))
+	if err != nil {
+		w.WriteHeader(http.StatusBadRequest)
+		w.Write([]byte("bad post id sent in request"))
+		return 0, 0, false
+	}
+
+	revision, err := strconv.Atoi(chi.URLParam(r, "rev"))
+	if err != nil {
+		w.WriteHeader(http.StatusBadRequest)
+		w.Write([]byte("bad revision sent in request"))
+		return 0, 0, false
+	}
+
+	return postID, revision, true
+}
+
+// revisionText is what a diff compares, the title as the first line followed
+// by a blank line and the content.
+func revisionText(revision *model.PostRevision) string {
+	return revision.Title + "\n\n" + revision.Content
+}
@@ -0,0 +1,51 @@
+package routes
+
+import (
+	"encoding/json"
+	"net/http"
+
+	"github.com/go-chi/chi"
+	"go.uber.org/zap"
+	"redcellpartners.com/users-posts-api/logging"
+	"redcellpartners.com/users-posts-api/store"
+)
+
+type TagsResource struct {
+	postStore store.PostStore
+	logger    *zap.Logger
+}
+
+func NewTagsResource(postStore store.PostStore, logger *zap.Logger) *TagsResource {
+	return &TagsResource{
+		postStore: postStore,
+		logger:    logger,
+	}
+}
+
+func (resource *TagsResource) Routes() chi.Router {
+	r := chi.NewRouter()
+
+	r.Get("/", resource.ListTags)
+
+	return r
+}
+
+// ListTags lists every tag in use with its post count, most used first.
+func (resource *TagsResource) ListTags(w http.ResponseWriter, r *http.Request) {
+	tags, err := resource.postStore.ListTags(r.Context())
+	if err != nil {
+		logging.FromContext(r.Context(), resource.logger).Error("unable to list tags", zap.Error(err))
+		w.WriteHeader(http.StatusInternalServerError)
+		w.Write([]byte("unable to list tags at this time"))
+		return
+	}
+
+	responseBody, err := json.Marshal(tags)
+	if err != nil {
+		logging.FromContext(r.Context(), resource.logger).Error("unable to marshal tags", zap.Error(err))
+		w.WriteHeader(http.StatusInternalServerError)
+		return
+	}
+
+	w.Write(responseBody)
+}
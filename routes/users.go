@@ -1,27 +1,33 @@
 package routes
 
 import (
+	"database/sql"
 	"encoding/json"
+	"errors"
+	"fmt"
 	"io"
 	"net/http"
 	"strconv"
 
 	"github.com/go-chi/chi"
 	"go.uber.org/zap"
+	"redcellpartners.com/users-posts-api/logging"
 	"redcellpartners.com/users-posts-api/middleware"
 	"redcellpartners.com/users-posts-api/model"
 	"redcellpartners.com/users-posts-api/store"
 )
 
 type UsersResource struct {
-	userStore store.UserStore
-	logger    *zap.Logger
+	userStore       store.UserStore
+	followsResource *FollowsResource
+	logger          *zap.Logger
 }
 
-func NewUsersResource(userStore store.UserStore, logger *zap.Logger) *UsersResource {
+func NewUsersResource(userStore store.UserStore, followsResource *FollowsResource, logger *zap.Logger) *UsersResource {
 	return &UsersResource{
-		userStore: userStore,
-		logger:    logger,
+		userStore:       userStore,
+		followsResource: followsResource,
+		logger:          logger,
 	}
 }
 
@@ -30,6 +36,7 @@ func (resource *UsersResource) Routes() chi.Router {
 
 	r.Get("/", resource.ListUsers)
 	r.Post("/", resource.CreateUser)
+	r.Get("/by-handle/{handle}", resource.GetUserByHandle)
 
 	r.Route("/{id}", func(r chi.Router) {
 		userExistMiddleware := middleware.NewUserExitsMiddleware(resource.userStore, resource.logger.Named("user_middleware"))
@@ -38,16 +45,50 @@ func (resource *UsersResource) Routes() chi.Router {
 		r.Get("/", resource.GetUser)
 		r.Put("/", resource.UpdateUser)
 		r.Delete("/", resource.DeleteUser)
+		resource.followsResource.UserRoutes(r)
 	})
 
 	return r
 }
 
+// AdminRoutes serves deleted users to operators: listing them with
+// include_deleted=true and restoring them. It must only be mounted on the
+// internal admin listener, never on the public router.
+func (resource *UsersResource) AdminRoutes() chi.Router {
+	r := chi.NewRouter()
+
+	r.Get("/", resource.AdminListUsers)
+	r.Post("/{id}:restore", resource.RestoreUser)
+
+	return r
+}
+
 func (resource *UsersResource) ListUsers(w http.ResponseWriter, r *http.Request) {
-	// TODO: implement
-	users, err := resource.userStore.ListUsers()
+	resource.listUsers(w, r, false)
+}
+
+// AdminListUsers lists users like ListUsers, and also the deleted ones with
+// include_deleted=true.
+func (resource *UsersResource) AdminListUsers(w http.ResponseWriter, r *http.Request) {
+	resource.listUsers(w, r, true)
+}
+
+func (resource *UsersResource) listUsers(w http.ResponseWriter, r *http.Request, admin bool) {
+	if email := r.URL.Query().Get("email"); email != "" {
+		resource.listUsersByEmail(w, r, email)
+		return
+	}
+
+	includeDeleted, err := readIncludeDeleted(r, admin)
+	if err != nil {
+		w.WriteHeader(http.StatusBadRequest)
+		w.Write([]byte(err.Error()))
+		return
+	}
+
+	users, err := resource.userStore.ListUsers(r.Context(), store.UserFilter{IncludeDeleted: includeDeleted})
 	if err != nil {
-		resource.logger.Error("unable to list users", zap.Error(err))
+		logging.FromContext(r.Context(), resource.logger).Error("unable to list users", zap.Error(err))
 		w.WriteHeader(http.StatusInternalServerError)
 		w.Write([]byte("unable to list users at this time"))
 		return
@@ -55,9 +96,32 @@ func (resource *UsersResource) ListUsers(w http.ResponseWriter, r *http.Request)
 
 	responseBytes, err := json.Marshal(users)
 	if err != nil {
-		resource.logger.Error("unable to marshal users", zap.Error(err))
+		logging.FromContext(r.Context(), resource.logger).Error("unable to marshal users", zap.Error(err))
+		w.Write([]byte("unable to list users at this time"))
+		return
+	}
+
+	w.Write(responseBytes)
+}
+
+func (resource *UsersResource) listUsersByEmail(w http.ResponseWriter, r *http.Request, email string) {
+	users := make([]*model.User, 0, 1)
+
+	user, err := resource.userStore.GetUserByEmail(r.Context(), email)
+	if err != nil && err != sql.ErrNoRows {
+		logging.FromContext(r.Context(), resource.logger).Error("unable to get user by email", zap.Error(err))
+		w.WriteHeader(http.StatusInternalServerError)
 		w.Write([]byte("unable to list users at this time"))
 		return
+	} else if err == nil {
+		users = append(users, user)
+	}
+
+	responseBytes, err := json.Marshal(users)
+	if err != nil {
+		logging.FromContext(r.Context(), resource.logger).Error("unable to marshal users", zap.Error(err))
+		w.WriteHeader(http.StatusInternalServerError)
+		return
 	}
 
 	w.Write(responseBytes)
@@ -66,7 +130,7 @@ func (resource *UsersResource) ListUsers(w http.ResponseWriter, r *http.Request)
 func (resource *UsersResource) CreateUser(w http.ResponseWriter, r *http.Request) {
 	body, err := io.ReadAll(r.Body)
 	if err != nil {
-		resource.logger.Error("unable to read request body", zap.Error(err))
+		logging.FromContext(r.Context(), resource.logger).Error("unable to read request body", zap.Error(err))
 		w.WriteHeader(http.StatusInternalServerError)
 		return
 	}
@@ -74,21 +138,35 @@ func (resource *UsersResource) CreateUser(w http.ResponseWriter, r *http.Request
 	var user *model.User
 
 	if err = json.Unmarshal(body, &user); err != nil {
-		resource.logger.Error("unable to unmarshal body into user", zap.Error(err))
+		logging.FromContext(r.Context(), resource.logger).Error("unable to unmarshal body into user", zap.Error(err))
 		w.WriteHeader(http.StatusBadRequest)
 		return
 	}
 
-	created, err := resource.userStore.CreateUser(user)
-	if err != nil {
-		resource.logger.Error("unable to create user", zap.Error(err))
+	if user.Handle, err = store.NormalizeHandle(user.Handle); err != nil {
+		w.WriteHeader(http.StatusBadRequest)
+		w.Write([]byte(err.Error()))
+		return
+	}
+
+	created, err := resource.userStore.CreateUser(r.Context(), user)
+	if errors.Is(err, store.ErrHandleTaken) {
+		w.WriteHeader(http.StatusConflict)
+		w.Write([]byte(fmt.Sprintf("handle %q is already taken", user.Handle)))
+		return
+	} else if errors.Is(err, store.ErrEmailTaken) {
+		w.WriteHeader(http.StatusConflict)
+		w.Write([]byte("email is already taken"))
+		return
+	} else if err != nil {
+		logging.FromContext(r.Context(), resource.logger).Error("unable to create user", zap.Error(err))
 		w.WriteHeader(http.StatusInternalServerError)
 		return
 	}
 
 	responseBody, err := json.Marshal(created)
 	if err != nil {
-		resource.logger.Error("unable to marshal created user", zap.Error(err))
+		logging.FromContext(r.Context(), resource.logger).Error("unable to marshal created user", zap.Error(err))
 		w.WriteHeader(http.StatusInternalServerError)
 		return
 	}
@@ -107,8 +185,34 @@ func (resource *UsersResource) GetUser(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	user, err := resource.userStore.GetUser(userIDInt)
+	user, err := resource.userStore.GetUser(r.Context(), userIDInt)
+	if err != nil {
+		w.WriteHeader(http.StatusInternalServerError)
+		w.Write([]byte("unable to get user at this time"))
+		return
+	}
+
+	responseBody, err := json.Marshal(user)
 	if err != nil {
+		logging.FromContext(r.Context(), resource.logger).Error("unable to marshal user", zap.Error(err))
+		w.WriteHeader(http.StatusInternalServerError)
+		return
+	}
+
+	w.Write(responseBody)
+}
+
+// GetUserByHandle gets a user by handle rather than id.
+func (resource *UsersResource) GetUserByHandle(w http.ResponseWriter, r *http.Request) {
+	handle := chi.URLParam(r, "handle")
+
+	user, err := resource.userStore.GetUserByHandle(r.Context(), handle)
+	if err != nil && err == sql.ErrNoRows {
+		w.WriteHeader(http.StatusNotFound)
+		w.Write([]byte(fmt.Sprintf("user with handle: %s does not exist", handle)))
+		return
+	} else if err != nil {
+		logging.FromContext(r.Context(), resource.logger).Error("unable to get user by handle", zap.String("handle", handle), zap.Error(err))
 		w.WriteHeader(http.StatusInternalServerError)
 		w.Write([]byte("unable to get user at this time"))
 		return
@@ -116,7 +220,7 @@ func (resource *UsersResource) GetUser(w http.ResponseWriter, r *http.Request) {
 
 	responseBody, err := json.Marshal(user)
 	if err != nil {
-		resource.logger.Error("unable to marshal user", zap.Error(err))
+		logging.FromContext(r.Context(), resource.logger).Error("unable to marshal user", zap.Error(err))
 		w.WriteHeader(http.StatusInternalServerError)
 		return
 	}
@@ -136,7 +240,7 @@ func (resource *UsersResource) UpdateUser(w http.ResponseWriter, r *http.Request
 
 	body, err := io.ReadAll(r.Body)
 	if err != nil {
-		resource.logger.Error("unable to read request body", zap.Error(err))
+		logging.FromContext(r.Context(), resource.logger).Error("unable to read request body", zap.Error(err))
 		w.WriteHeader(http.StatusInternalServerError)
 		return
 	}
@@ -144,16 +248,30 @@ func (resource *UsersResource) UpdateUser(w http.ResponseWriter, r *http.Request
 	var user *model.User
 
 	if err = json.Unmarshal(body, &user); err != nil {
-		resource.logger.Error("unable to unmarshal body into user", zap.Error(err))
+		logging.FromContext(r.Context(), resource.logger).Error("unable to unmarshal body into user", zap.Error(err))
 		w.WriteHeader(http.StatusBadRequest)
 		return
 	}
 
+	if user.Handle, err = store.NormalizeHandle(user.Handle); err != nil {
+		w.WriteHeader(http.StatusBadRequest)
+		w.Write([]byte(err.Error()))
+		return
+	}
+
 	user.ID = userIDInt
 
-	updatedUser, err := resource.userStore.UpdateUser(user)
-	if err != nil {
-		resource.logger.Error("unable to update user", zap.Int("user_id", user.ID), zap.Error(err))
+	updatedUser, err := resource.userStore.UpdateUser(r.Context(), user)
+	if errors.Is(err, store.ErrHandleTaken) {
+		w.WriteHeader(http.StatusConflict)
+		w.Write([]byte(fmt.Sprintf("handle %q is already taken", user.Handle)))
+		return
+	} else if errors.Is(err, store.ErrEmailTaken) {
+		w.WriteHeader(http.StatusConflict)
+		w.Write([]byte("email is already taken"))
+		return
+	} else if err != nil {
+		logging.FromContext(r.Context(), resource.logger).Error("unable to update user", zap.Int("user_id", user.ID), zap.Error(err))
 		w.WriteHeader(http.StatusInternalServerError)
 		w.Write([]byte("unable to get updated user at this time"))
 		return
@@ -161,7 +279,39 @@ func (resource *UsersResource) UpdateUser(w http.ResponseWriter, r *http.Request
 
 	responseBody, err := json.Marshal(updatedUser)
 	if err != nil {
-		resource.logger.Error("unable to marshal updated user", zap.Error(err))
+		logging.FromContext(r.Context(), resource.logger).Error("unable to marshal updated user", zap.Error(err))
+		w.WriteHeader(http.StatusInternalServerError)
+		return
+	}
+
+	w.Write(responseBody)
+}
+
+// RestoreUser brings back a deleted user along with the posts that were
+// deleted with it.
+func (resource *UsersResource) RestoreUser(w http.ResponseWriter, r *http.Request) {
+	userID, err := strconv.Atoi(chi.URLParam(r, "id"))
+	if err != nil {
+		w.WriteHeader(http.StatusBadRequest)
+		w.Write([]byte("bad user id sent in request"))
+		return
+	}
+
+	user, err := resource.userStore.RestoreUser(r.Context(), userID)
+	if err != nil && err == sql.ErrNoRows {
+		w.WriteHeader(http.StatusNotFound)
+		w.Write([]byte(fmt.Sprintf("deleted user with user_id: %d does not exist", userID)))
+		return
+	} else if err != nil {
+		logging.FromContext(r.Context(), resource.logger).Error("unable to restore user", zap.Int("user_id", userID), zap.Error(err))
+		w.WriteHeader(http.StatusInternalServerError)
+		w.Write([]byte("unable to restore user at this time"))
+		return
+	}
+
+	responseBody, err := json.Marshal(user)
+	if err != nil {
+		logging.FromContext(r.Context(), resource.logger).Error("unable to marshal restored user", zap.Error(err))
 		w.WriteHeader(http.StatusInternalServerError)
 		return
 	}
@@ -179,7 +329,7 @@ func (resource *UsersResource) DeleteUser(w http.ResponseWriter, r *http.Request
 		return
 	}
 
-	err = resource.userStore.DeleteUser(userIDInt)
+	err = resource.userStore.DeleteUser(r.Context(), userIDInt)
 	if err != nil {
 		w.WriteHeader(http.StatusInternalServerError)
 		w.Write([]byte("unable to delete user at this time"))
@@ -188,3 +338,24 @@ func (resource *UsersResource) DeleteUser(w http.ResponseWriter, r *http.Request
 
 	w.WriteHeader(http.StatusNoContent)
 }
+
+// readIncludeDeleted reads the include_deleted query parameter, which lists
+// deleted rows along with the others. Only the admin listener may set it,
+// deleted rows are not public.
+func readIncludeDeleted(r *http.Request, admin bool) (bool, error) {
+	value := r.URL.Query().Get("include_deleted")
+	if value == "" {
+		return false, nil
+	}
+
+	includeDeleted, err := strconv.ParseBool(value)
+	if err != nil {
+		return false, fmt.Errorf("invalid include_deleted provided, expected true or false")
+	}
+
+	if includeDeleted && !admin {
+		return false, fmt.Errorf("include_deleted is only available on the admin listener")
+	}
+
+	return includeDeleted, nil
+}
@@ -0,0 +1,80 @@
+package routes
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"go.uber.org/zap"
+	"redcellpartners.com/users-posts-api/model"
+	"redcellpartners.com/users-posts-api/store"
+)
+
+// fakeUserStore records which of its methods the routes call. Methods the
+// tests do not reach are left to the embedded nil interface.
+type fakeUserStore struct {
+	store.UserStore
+	filter store.UserFilter
+	calls  []string
+}
+
+func (client *fakeUserStore) ListUsers(ctx context.Context, filter store.UserFilter) ([]*model.User, error) {
+	client.calls = append(client.calls, "ListUsers")
+	client.filter = filter
+
+	return []*model.User{}, nil
+}
+
+func (client *fakeUserStore) RestoreUser(ctx context.Context, id int) (*model.User, error) {
+	client.calls = append(client.calls, "RestoreUser")
+
+	return &model.User{ID: id}, nil
+}
+
+func TestUserAdminRoutes(t *testing.T) {
+	tests := []struct {
+		name           string
+		admin          bool
+		method         string
+		path           string
+		status         int
+		calls          []string
+		includeDeleted bool
+	}{
+		{name: "public list", method: "GET", path: "/", status: http.StatusOK, calls: []string{"ListUsers"}},
+		{name: "public list of deleted users", method: "GET", path: "/?include_deleted=true", status: http.StatusBadRequest},
+		{name: "invalid include deleted", method: "GET", path: "/?include_deleted=maybe", status: http.StatusBadRequest},
+		{name: "admin list of deleted users", admin: true, method: "GET", path: "/?include_deleted=true", status: http.StatusOK, calls: []string{"ListUsers"}, includeDeleted: true},
+		{name: "admin restore", admin: true, method: "POST", path: "/5:restore", status: http.StatusOK, calls: []string{"RestoreUser"}},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			userStore := &fakeUserStore{}
+
+			resource := NewUsersResource(userStore, NewFollowsResource(nil, nil, zap.NewNop()), zap.NewNop())
+
+			router := resource.Routes()
+			if test.admin {
+				router = resource.AdminRoutes()
+			}
+
+			w := httptest.NewRecorder()
+			router.ServeHTTP(w, httptest.NewRequest(test.method, test.path, nil))
+
+			if w.Code != test.status {
+				t.Errorf("expected status %d, got %d: %s", test.status, w.Code, w.Body.String())
+			}
+
+			if strings.Join(userStore.calls, ",") != strings.Join(test.calls, ",") {
+				t.Errorf("expected store calls %v, got %v", test.calls, userStore.calls)
+			}
+
+			if userStore.filter.IncludeDeleted != test.includeDeleted {
+				t.Errorf("expected include deleted %t, got %t", test.includeDeleted, userStore.filter.IncludeDeleted)
+			}
+		})
+	}
+}
@@ -0,0 +1,65 @@
+package store
+
+import (
+	"context"
+	"time"
+
+	"redcellpartners.com/users-posts-api/model"
+)
+
+const (
+	AuditActionCreate  = "create"
+	AuditActionUpdate  = "update"
+	AuditActionDelete  = "delete"
+	AuditActionRestore = "restore"
+	AuditActionPurge   = "purge"
+
+	AuditEntityUser    = "user"
+	AuditEntityPost    = "post"
+	AuditEntityComment = "comment"
+
+	AnonymousActor = "anonymous"
+	SchedulerActor = "scheduler"
+	PurgeActor     = "purge"
+	AdminActor     = "admin"
+)
+
+type AuditFilter struct {
+	Entity   string
+	EntityID int
+	Actor    string
+	Since    time.Time
+	Until    time.Time
+	Limit    int
+}
+
+type AuditStore interface {
+	ListAuditEvents(ctx context.Context, filter AuditFilter) ([]*model.AuditEvent, error)
+}
+
+type actorContextKey struct{}
+
+type requestIDContextKey struct{}
+
+// WithActor records who is making the changes done with the returned context.
+func WithActor(ctx context.Context, actor string) context.Context {
+	return context.WithValue(ctx, actorContextKey{}, actor)
+}
+
+func ActorFromContext(ctx context.Context) string {
+	if actor, ok := ctx.Value(actorContextKey{}).(string); ok && actor != "" {
+		return actor
+	}
+
+	return AnonymousActor
+}
+
+// WithRequestID records the id of the request the context belongs to.
+func WithRequestID(ctx context.Context, requestID string) context.Context {
+	return context.WithValue(ctx, requestIDContextKey{}, requestID)
+}
+
+func RequestIDFromContext(ctx context.Context) string {
+	requestID, _ := ctx.Value(requestIDContextKey{}).(string)
+	return requestID
+}
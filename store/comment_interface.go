@@ -0,0 +1,34 @@
+package store
+
+import (
+	"context"
+	"errors"
+
+	"redcellpartners.com/users-posts-api/model"
+)
+
+var (
+	// ErrCommentParentNotFound is returned when a reply's parent does not
+	// exist or belongs to another post.
+	ErrCommentParentNotFound = errors.New("parent comment does not exist on this post")
+
+	// ErrCommentTooDeep is returned when a reply would exceed the maximum
+	// thread depth.
+	ErrCommentTooDeep = errors.New("reply exceeds the maximum comment depth")
+)
+
+// CommentStore reads and writes comments. Comments of a deleted post are left
+// out, reading or writing them returns sql.ErrNoRows, as does commenting on a
+// deleted post.
+type CommentStore interface {
+	// ListComments returns the comments of a post in thread order: every
+	// comment is followed by its replies, oldest first.
+	ListComments(ctx context.Context, postID int) ([]*model.Comment, error)
+	// CreateComment returns ErrUnknownUser when the author does not exist
+	// or is deleted.
+	CreateComment(ctx context.Context, comment *model.Comment) (*model.Comment, error)
+	GetComment(ctx context.Context, id int) (*model.Comment, error)
+	UpdateComment(ctx context.Context, comment *model.Comment) (*model.Comment, error)
+	// DeleteComment deletes a comment along with every reply below it.
+	DeleteComment(ctx context.Context, id int) error
+}
@@ -0,0 +1,18 @@
+package store
+
+import (
+	"context"
+
+	"redcellpartners.com/users-posts-api/model"
+)
+
+// FollowStore records which users follow which. Following or unfollowing
+// twice is a no-op, and following an unknown user returns ErrUnknownUser.
+type FollowStore interface {
+	Follow(ctx context.Context, userID, targetID int) error
+	Unfollow(ctx context.Context, userID, targetID int) error
+	// ListFollowers and ListFollowing return a page of follows, most recent
+	// first.
+	ListFollowers(ctx context.Context, userID int, page Page) ([]*model.Follow, error)
+	ListFollowing(ctx context.Context, userID int, page Page) ([]*model.Follow, error)
+}
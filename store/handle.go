@@ -0,0 +1,36 @@
+package store
+
+import (
+	"fmt"
+	"strings"
+)
+
+const (
+	MIN_HANDLE_LENGTH = 3
+	MAX_HANDLE_LENGTH = 30
+)
+
+// NormalizeHandle lower cases and trims a user handle and checks it is made
+// of letters, digits and underscores, starting with a letter so a handle can
+// never be mistaken for an id. An empty handle means the user has none.
+func NormalizeHandle(handle string) (string, error) {
+	handle = strings.ToLower(strings.TrimSpace(handle))
+	if handle == "" {
+		return "", nil
+	}
+
+	if len(handle) < MIN_HANDLE_LENGTH || len(handle) > MAX_HANDLE_LENGTH {
+		return "", fmt.Errorf("handle must be between %d and %d characters", MIN_HANDLE_LENGTH, MAX_HANDLE_LENGTH)
+	}
+
+	for i, r := range handle {
+		switch {
+		case r >= 'a' && r <= 'z':
+		case i > 0 && ((r >= '0' && r <= '9') || r == '_'):
+		default:
+			return "", fmt.Errorf("handle must start with a letter and contain only letters, digits and underscores")
+		}
+	}
+
+	return handle, nil
+}
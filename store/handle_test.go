@@ -0,0 +1,50 @@
+package store
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestNormalizeHandle(t *testing.T) {
+	tests := []struct {
+		name     string
+		handle   string
+		expected string
+		wantErr  bool
+	}{
+		{name: "empty means none", handle: "", expected: ""},
+		{name: "blank means none", handle: "   ", expected: ""},
+		{name: "lower cased and trimmed", handle: " Ada_Lovelace ", expected: "ada_lovelace"},
+		{name: "digits after the first letter", handle: "r2d2", expected: "r2d2"},
+		{name: "shortest", handle: strings.Repeat("a", MIN_HANDLE_LENGTH), expected: strings.Repeat("a", MIN_HANDLE_LENGTH)},
+		{name: "longest", handle: strings.Repeat("a", MAX_HANDLE_LENGTH), expected: strings.Repeat("a", MAX_HANDLE_LENGTH)},
+		{name: "too short", handle: "ab", wantErr: true},
+		{name: "too long", handle: strings.Repeat("a", MAX_HANDLE_LENGTH+1), wantErr: true},
+		{name: "starts with a digit", handle: "42abc", wantErr: true},
+		{name: "starts with an underscore", handle: "_ada", wantErr: true},
+		{name: "dash", handle: "ada-l", wantErr: true},
+		{name: "inner space", handle: "ada l", wantErr: true},
+		{name: "non ascii", handle: "adé", wantErr: true},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			handle, err := NormalizeHandle(test.handle)
+			if test.wantErr {
+				if err == nil {
+					t.Fatalf("expected an error, got %q", handle)
+				}
+
+				return
+			}
+
+			if err != nil {
+				t.Fatalf("unexpected error: %s", err.Error())
+			}
+
+			if handle != test.expected {
+				t.Errorf("expected %q, got %q", test.expected, handle)
+			}
+		})
+	}
+}
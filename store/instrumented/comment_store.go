@@ -0,0 +1,59 @@
+package instrumented
+
+import (
+	"context"
+
+	"redcellpartners.com/users-posts-api/metrics"
+	"redcellpartners.com/users-posts-api/model"
+	"redcellpartners.com/users-posts-api/store"
+)
+
+var _ store.CommentStore = &CommentStore{}
+
+// CommentStore times and traces every call to the wrapped comment store.
+type CommentStore struct {
+	next    store.CommentStore
+	metrics *metrics.Metrics
+}
+
+func NewCommentStore(next store.CommentStore, m *metrics.Metrics) *CommentStore {
+	return &CommentStore{
+		next:    next,
+		metrics: m,
+	}
+}
+
+func (decorator *CommentStore) ListComments(ctx context.Context, postID int) (comments []*model.Comment, err error) {
+	ctx, end := begin(ctx, decorator.metrics, "comment", "ListComments")
+	defer func() { end(err) }()
+
+	return decorator.next.ListComments(ctx, postID)
+}
+
+func (decorator *CommentStore) CreateComment(ctx context.Context, comment *model.Comment) (created *model.Comment, err error) {
+	ctx, end := begin(ctx, decorator.metrics, "comment", "CreateComment")
+	defer func() { end(err) }()
+
+	return decorator.next.CreateComment(ctx, comment)
+}
+
+func (decorator *CommentStore) GetComment(ctx context.Context, id int) (comment *model.Comment, err error) {
+	ctx, end := begin(ctx, decorator.metrics, "comment", "GetComment")
+	defer func() { end(err) }()
+
+	return decorator.next.GetComment(ctx, id)
+}
+
+func (decorator *CommentStore) UpdateComment(ctx context.Context, comment *model.Comment) (updated *model.Comment, err error) {
+	ctx, end := begin(ctx, decorator.metrics, "comment", "UpdateComment")
+	defer func() { end(err) }()
+
+	return decorator.next.UpdateComment(ctx, comment)
+}
+
+func (decorator *CommentStore) DeleteComment(ctx context.Context, id int) (err error) {
+	ctx, end := begin(ctx, decorator.metrics, "comment", "DeleteComment")
+	defer func() { end(err) }()
+
+	return decorator.next.DeleteComment(ctx, id)
+}
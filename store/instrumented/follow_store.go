@@ -0,0 +1,52 @@
+package instrumented
+
+import (
+	"context"
+
+	"redcellpartners.com/users-posts-api/metrics"
+	"redcellpartners.com/users-posts-api/model"
+	"redcellpartners.com/users-posts-api/store"
+)
+
+var _ store.FollowStore = &FollowStore{}
+
+// FollowStore times and traces every call to the wrapped follow store.
+type FollowStore struct {
+	next    store.FollowStore
+	metrics *metrics.Metrics
+}
+
+func NewFollowStore(next store.FollowStore, m *metrics.Metrics) *FollowStore {
+	return &FollowStore{
+		next:    next,
+		metrics: m,
+	}
+}
+
+func (decorator *FollowStore) Follow(ctx context.Context, userID, targetID int) (err error) {
+	ctx, end := begin(ctx, decorator.metrics, "follow", "Follow")
+	defer func() { end(err) }()
+
+	return decorator.next.Follow(ctx, userID, targetID)
+}
+
+func (decorator *FollowStore) Unfollow(ctx context.Context, userID, targetID int) (err error) {
+	ctx, end := begin(ctx, decorator.metrics, "follow", "Unfollow")
+	defer func() { end(err) }()
+
+	return decorator.next.Unfollow(ctx, userID, targetID)
+}
+
+func (decorator *FollowStore) ListFollowers(ctx context.Context, userID int, page store.Page) (follows []*model.Follow, err error) {
+	ctx, end := begin(ctx, decorator.metrics, "follow", "ListFollowers")
+	defer func() { end(err) }()
+
+	return decorator.next.ListFollowers(ctx, userID, page)
+}
+
+func (decorator *FollowStore) ListFollowing(ctx context.Context, userID int, page store.Page) (follows []*model.Follow, err error) {
+	ctx, end := begin(ctx, decorator.metrics, "follow", "ListFollowing")
+	defer func() { end(err) }()
+
+	return decorator.next.ListFollowing(ctx, userID, page)
+}
@@ -0,0 +1,39 @@
+package instrumented
+
+import (
+	"context"
+	"database/sql"
+	"time"
+
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/codes"
+	"redcellpartners.com/users-posts-api/metrics"
+)
+
+const tracerName = "redcellpartners.com/users-posts-api/store/instrumented"
+
+// begin starts a span for the store call and returns the function that ends
+// it and records the call's metrics once its error is known.
+func begin(ctx context.Context, m *metrics.Metrics, storeName, method string) (context.Context, func(error)) {
+	start := time.Now()
+
+	ctx, span := otel.Tracer(tracerName).Start(ctx, storeName+"."+method)
+
+	return ctx, func(err error) {
+		result := "ok"
+
+		if err == sql.ErrNoRows {
+			result = "not_found"
+		} else if err != nil {
+			result = "error"
+
+			span.RecordError(err)
+			span.SetStatus(codes.Error, err.Error())
+		}
+
+		span.End()
+
+		m.StoreCalls.WithLabelValues(storeName, method, result).Inc()
+		m.StoreCallDuration.WithLabelValues(storeName, method, result).Observe(time.Since(start).Seconds())
+	}
+}
@@ -0,0 +1,144 @@
+package instrumented
+
+import (
+	"context"
+	"time"
+
+	"redcellpartners.com/users-posts-api/metrics"
+	"redcellpartners.com/users-posts-api/model"
+	"redcellpartners.com/users-posts-api/store"
+)
+
+var _ store.PostStore = &PostStore{}
+
+// PostStore times and traces every call to the wrapped post store.
+type PostStore struct {
+	next    store.PostStore
+	metrics *metrics.Metrics
+}
+
+func NewPostStore(next store.PostStore, m *metrics.Metrics) *PostStore {
+	return &PostStore{
+		next:    next,
+		metrics: m,
+	}
+}
+
+func (decorator *PostStore) ListPosts(ctx context.Context, filter store.PostFilter) (posts []*model.Post, err error) {
+	ctx, end := begin(ctx, decorator.metrics, "post", "ListPosts")
+	defer func() { end(err) }()
+
+	return decorator.next.ListPosts(ctx, filter)
+}
+
+func (decorator *PostStore) CreatePost(ctx context.Context, post *model.Post) (created *model.Post, err error) {
+	ctx, end := begin(ctx, decorator.metrics, "post", "CreatePost")
+	defer func() { end(err) }()
+
+	return decorator.next.CreatePost(ctx, post)
+}
+
+func (decorator *PostStore) GetPost(ctx context.Context, id int) (post *model.Post, err error) {
+	ctx, end := begin(ctx, decorator.metrics, "post", "GetPost")
+	defer func() { end(err) }()
+
+	return decorator.next.GetPost(ctx, id)
+}
+
+func (decorator *PostStore) GetPostBySlug(ctx context.Context, slug string) (post *model.Post, err error) {
+	ctx, end := begin(ctx, decorator.metrics, "post", "GetPostBySlug")
+	defer func() { end(err) }()
+
+	return decorator.next.GetPostBySlug(ctx, slug)
+}
+
+func (decorator *PostStore) UpdatePost(ctx context.Context, post *model.Post) (updated *model.Post, err error) {
+	ctx, end := begin(ctx, decorator.metrics, "post", "UpdatePost")
+	defer func() { end(err) }()
+
+	return decorator.next.UpdatePost(ctx, post)
+}
+
+func (decorator *PostStore) DeletePost(ctx context.Context, id int) (err error) {
+	ctx, end := begin(ctx, decorator.metrics, "post", "DeletePost")
+	defer func() { end(err) }()
+
+	return decorator.next.DeletePost(ctx, id)
+}
+
+func (decorator *PostStore) ListTags(ctx context.Context) (tags []*model.Tag, err error) {
+	ctx, end := begin(ctx, decorator.metrics, "post", "ListTags")
+	defer func() { end(err) }()
+
+	return decorator.next.ListTags(ctx)
+}
+
+func (decorator *PostStore) ListFeed(ctx context.Context, userID int, page store.Page) (posts []*model.Post, err error) {
+	ctx, end := begin(ctx, decorator.metrics, "post", "ListFeed")
+	defer func() { end(err) }()
+
+	return decorator.next.ListFeed(ctx, userID, page)
+}
+
+func (decorator *PostStore) SearchPosts(ctx context.Context, query string, page store.SearchPage) (results []*model.PostSearchResult, err error) {
+	ctx, end := begin(ctx, decorator.metrics, "post", "SearchPosts")
+	defer func() { end(err) }()
+
+	return decorator.next.SearchPosts(ctx, query, page)
+}
+
+func (decorator *PostStore) PublishPost(ctx context.Context, id int, publishAt time.Time) (post *model.Post, err error) {
+	ctx, end := begin(ctx, decorator.metrics, "post", "PublishPost")
+	defer func() { end(err) }()
+
+	return decorator.next.PublishPost(ctx, id, publishAt)
+}
+
+func (decorator *PostStore) ArchivePost(ctx context.Context, id int) (post *model.Post, err error) {
+	ctx, end := begin(ctx, decorator.metrics, "post", "ArchivePost")
+	defer func() { end(err) }()
+
+	return decorator.next.ArchivePost(ctx, id)
+}
+
+func (decorator *PostStore) PublishScheduledPosts(ctx context.Context, now time.Time, limit int) (published int, err error) {
+	ctx, end := begin(ctx, decorator.metrics, "post", "PublishScheduledPosts")
+	defer func() { end(err) }()
+
+	return decorator.next.PublishScheduledPosts(ctx, now, limit)
+}
+
+func (decorator *PostStore) ListPostRevisions(ctx context.Context, postID int) (revisions []*model.PostRevision, err error) {
+	ctx, end := begin(ctx, decorator.metrics, "post", "ListPostRevisions")
+	defer func() { end(err) }()
+
+	return decorator.next.ListPostRevisions(ctx, postID)
+}
+
+func (decorator *PostStore) GetPostRevision(ctx context.Context, postID, revision int) (postRevision *model.PostRevision, err error) {
+	ctx, end := begin(ctx, decorator.metrics, "post", "GetPostRevision")
+	defer func() { end(err) }()
+
+	return decorator.next.GetPostRevision(ctx, postID, revision)
+}
+
+func (decorator *PostStore) RestorePostRevision(ctx context.Context, postID, revision int) (post *model.Post, err error) {
+	ctx, end := begin(ctx, decorator.metrics, "post", "RestorePostRevision")
+	defer func() { end(err) }()
+
+	return decorator.next.RestorePostRevision(ctx, postID, revision)
+}
+
+func (decorator *PostStore) RestorePost(ctx context.Context, id int) (post *model.Post, err error) {
+	ctx, end := begin(ctx, decorator.metrics, "post", "RestorePost")
+	defer func() { end(err) }()
+
+	return decorator.next.RestorePost(ctx, id)
+}
+
+func (decorator *PostStore) PurgeDeletedPosts(ctx context.Context, cutoff time.Time, limit int) (purged int, err error) {
+	ctx, end := begin(ctx, decorator.metrics, "post", "PurgeDeletedPosts")
+	defer func() { end(err) }()
+
+	return decorator.next.PurgeDeletedPosts(ctx, cutoff, limit)
+}
@@ -0,0 +1,37 @@
+package instrumented
+
+import (
+	"context"
+
+	"redcellpartners.com/users-posts-api/metrics"
+	"redcellpartners.com/users-posts-api/store"
+)
+
+var _ store.ReactionStore = &ReactionStore{}
+
+// ReactionStore times and traces every call to the wrapped reaction store.
+type ReactionStore struct {
+	next    store.ReactionStore
+	metrics *metrics.Metrics
+}
+
+func NewReactionStore(next store.ReactionStore, m *metrics.Metrics) *ReactionStore {
+	return &ReactionStore{
+		next:    next,
+		metrics: m,
+	}
+}
+
+func (decorator *ReactionStore) AddReaction(ctx context.Context, postID, userID int, kind string) (err error) {
+	ctx, end := begin(ctx, decorator.metrics, "reaction", "AddReaction")
+	defer func() { end(err) }()
+
+	return decorator.next.AddReaction(ctx, postID, userID, kind)
+}
+
+func (decorator *ReactionStore) RemoveReaction(ctx context.Context, postID, userID int, kind string) (err error) {
+	ctx, end := begin(ctx, decorator.metrics, "reaction", "RemoveReaction")
+	defer func() { end(err) }()
+
+	return decorator.next.RemoveReaction(ctx, postID, userID, kind)
+}
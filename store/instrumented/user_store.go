@@ -0,0 +1,88 @@
+package instrumented
+
+import (
+	"context"
+	"time"
+
+	"redcellpartners.com/users-posts-api/metrics"
+	"redcellpartners.com/users-posts-api/model"
+	"redcellpartners.com/users-posts-api/store"
+)
+
+var _ store.UserStore = &UserStore{}
+
+// UserStore times and traces every call to the wrapped user store.
+type UserStore struct {
+	next    store.UserStore
+	metrics *metrics.Metrics
+}
+
+func NewUserStore(next store.UserStore, m *metrics.Metrics) *UserStore {
+	return &UserStore{
+		next:    next,
+		metrics: m,
+	}
+}
+
+func (decorator *UserStore) ListUsers(ctx context.Context, filter store.UserFilter) (users []*model.User, err error) {
+	ctx, end := begin(ctx, decorator.metrics, "user", "ListUsers")
+	defer func() { end(err) }()
+
+	return decorator.next.ListUsers(ctx, filter)
+}
+
+func (decorator *UserStore) CreateUser(ctx context.Context, user *model.User) (created *model.User, err error) {
+	ctx, end := begin(ctx, decorator.metrics, "user", "CreateUser")
+	defer func() { end(err) }()
+
+	return decorator.next.CreateUser(ctx, user)
+}
+
+func (decorator *UserStore) GetUser(ctx context.Context, id int) (user *model.User, err error) {
+	ctx, end := begin(ctx, decorator.metrics, "user", "GetUser")
+	defer func() { end(err) }()
+
+	return decorator.next.GetUser(ctx, id)
+}
+
+func (decorator *UserStore) GetUserByEmail(ctx context.Context, email string) (user *model.User, err error) {
+	ctx, end := begin(ctx, decorator.metrics, "user", "GetUserByEmail")
+	defer func() { end(err) }()
+
+	return decorator.next.GetUserByEmail(ctx, email)
+}
+
+func (decorator *UserStore) GetUserByHandle(ctx context.Context, handle string) (user *model.User, err error) {
+	ctx, end := begin(ctx, decorator.metrics, "user", "GetUserByHandle")
+	defer func() { end(err) }()
+
+	return decorator.next.GetUserByHandle(ctx, handle)
+}
+
+func (decorator *UserStore) UpdateUser(ctx context.Context, user *model.User) (updated *model.User, err error) {
+	ctx, end := begin(ctx, decorator.metrics, "user", "UpdateUser")
+	defer func() { end(err) }()
+
+	return decorator.next.UpdateUser(ctx, user)
+}
+
+func (decorator *UserStore) DeleteUser(ctx context.Context, id int) (err error) {
+	ctx, end := begin(ctx, decorator.metrics, "user", "DeleteUser")
+	defer func() { end(err) }()
+
+	return decorator.next.DeleteUser(ctx, id)
+}
+
+func (decorator *UserStore) RestoreUser(ctx context.Context, id int) (user *model.User, err error) {
+	ctx, end := begin(ctx, decorator.metrics, "user", "RestoreUser")
+	defer func() { end(err) }()
+
+	return decorator.next.RestoreUser(ctx, id)
+}
+
+func (decorator *UserStore) PurgeDeletedUsers(ctx context.Context, cutoff time.Time, limit int) (purged int, err error) {
+	ctx, end := begin(ctx, decorator.metrics, "user", "PurgeDeletedUsers")
+	defer func() { end(err) }()
+
+	return decorator.next.PurgeDeletedUsers(ctx, cutoff, limit)
+}
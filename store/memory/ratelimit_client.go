@@ -0,0 +1,75 @@
+package memory
+
+import (
+	"context"
+	"math"
+	"sync"
+	"time"
+
+	"redcellpartners.com/users-posts-api/store"
+)
+
+var _ store.RateLimitStore = &MemoryRateLimitClient{}
+
+type bucket struct {
+	tokens    float64
+	updatedAt time.Time
+}
+
+// MemoryRateLimitClient keeps token buckets in process memory. It is only
+// suitable when a single replica of the API is running.
+type MemoryRateLimitClient struct {
+	mu      sync.Mutex
+	buckets map[string]*bucket
+	now     func() time.Time
+}
+
+func NewMemoryRateLimitClient() *MemoryRateLimitClient {
+	return &MemoryRateLimitClient{
+		buckets: make(map[string]*bucket),
+		now:     time.Now,
+	}
+}
+
+func (client *MemoryRateLimitClient) Take(ctx context.Context, key string, limit store.RateLimit) (*store.RateLimitResult, error) {
+	client.mu.Lock()
+	defer client.mu.Unlock()
+
+	now := client.now()
+
+	b, ok := client.buckets[key]
+	if !ok {
+		b = &bucket{
+			tokens:    float64(limit.Burst),
+			updatedAt: now,
+		}
+		client.buckets[key] = b
+	}
+
+	elapsed := now.Sub(b.updatedAt).Seconds()
+	b.tokens = math.Min(float64(limit.Burst), b.tokens+elapsed*limit.Rate)
+	b.updatedAt = now
+
+	allowed := b.tokens >= 1
+	if allowed {
+		b.tokens--
+	}
+
+	return store.NewRateLimitResult(allowed, b.tokens, limit), nil
+}
+
+// Prune removes buckets that have not been touched since before the cutoff.
+// A bucket left idle that long has refilled completely so dropping it does
+// not change any limits.
+func (client *MemoryRateLimitClient) Prune(ctx context.Context, olderThan time.Duration) {
+	client.mu.Lock()
+	defer client.mu.Unlock()
+
+	cutoff := client.now().Add(-olderThan)
+
+	for key, b := range client.buckets {
+		if b.updatedAt.Before(cutoff) {
+			delete(client.buckets, key)
+		}
+	}
+}
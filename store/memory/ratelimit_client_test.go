@@ -0,0 +1,92 @@
+package memory
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"redcellpartners.com/users-posts-api/store"
+)
+
+func TestMemoryRateLimitClientTake(t *testing.T) {
+	var (
+		now    = time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+		limit  = store.RateLimit{Rate: 1, Burst: 3}
+		client = NewMemoryRateLimitClient()
+	)
+
+	client.now = func() time.Time { return now }
+
+	steps := []struct {
+		name      string
+		elapsed   time.Duration
+		allowed   bool
+		remaining int
+	}{
+		{name: "first take starts from a full bucket", allowed: true, remaining: 2},
+		{name: "second take", allowed: true, remaining: 1},
+		{name: "third take empties the bucket", allowed: true, remaining: 0},
+		{name: "empty bucket rejects", allowed: false, remaining: 0},
+		{name: "half a token is not enough", elapsed: time.Second / 2, allowed: false, remaining: 0},
+		{name: "refilled token is taken", elapsed: time.Second / 2, allowed: true, remaining: 0},
+		{name: "refill stops at burst", elapsed: time.Hour, allowed: true, remaining: 2},
+	}
+
+	for _, step := range steps {
+		now = now.Add(step.elapsed)
+
+		result, err := client.Take(context.Background(), "key", limit)
+		if err != nil {
+			t.Fatalf("%s: unexpected error: %s", step.name, err.Error())
+		}
+
+		if result.Allowed != step.allowed || result.Remaining != step.remaining {
+			t.Fatalf("%s: expected allowed %t with %d remaining, got allowed %t with %d remaining", step.name, step.allowed, step.remaining, result.Allowed, result.Remaining)
+		}
+	}
+}
+
+func TestMemoryRateLimitClientKeysAreIndependent(t *testing.T) {
+	client := NewMemoryRateLimitClient()
+	limit := store.RateLimit{Rate: 1, Burst: 1}
+
+	for _, key := range []string{"a", "b"} {
+		result, err := client.Take(context.Background(), key, limit)
+		if err != nil {
+			t.Fatalf("unexpected error: %s", err.Error())
+		}
+
+		if !result.Allowed {
+			t.Errorf("expected the first take of %q to be allowed", key)
+		}
+	}
+}
+
+func TestMemoryRateLimitClientPrune(t *testing.T) {
+	now := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+
+	client := NewMemoryRateLimitClient()
+	client.now = func() time.Time { return now }
+
+	limit := store.RateLimit{Rate: 1, Burst: 1}
+
+	if _, err := client.Take(context.Background(), "idle", limit); err != nil {
+		t.Fatalf("unexpected error: %s", err.Error())
+	}
+
+	now = now.Add(time.Hour)
+
+	if _, err := client.Take(context.Background(), "active", limit); err != nil {
+		t.Fatalf("unexpected error: %s", err.Error())
+	}
+
+	client.Prune(context.Background(), time.Minute)
+
+	if _, ok := client.buckets["idle"]; ok {
+		t.Error("expected the idle bucket to be pruned")
+	}
+
+	if _, ok := client.buckets["active"]; !ok {
+		t.Error("expected the active bucket to be kept")
+	}
+}
@@ -0,0 +1,61 @@
+package store
+
+import (
+	"encoding/base64"
+	"errors"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const (
+	DEFAULT_PAGE_LIMIT = 20
+	MAX_PAGE_LIMIT     = 100
+)
+
+var ErrInvalidCursor = errors.New("invalid cursor")
+
+// Cursor is the position of the last item of a page in a newest first
+// listing, the next page starts with the items strictly older than it. The
+// id breaks ties between items created at the same time.
+type Cursor struct {
+	Time time.Time
+	ID   int
+}
+
+// Page asks for at most Limit items following After, or the first page when
+// After is nil.
+type Page struct {
+	After *Cursor
+	Limit int
+}
+
+// String encodes the cursor as an opaque, URL safe token.
+func (cursor Cursor) String() string {
+	return base64.RawURLEncoding.EncodeToString([]byte(cursor.Time.UTC().Format(time.RFC3339Nano) + "," + strconv.Itoa(cursor.ID)))
+}
+
+// ParseCursor decodes a token returned by Cursor.String.
+func ParseCursor(token string) (*Cursor, error) {
+	decoded, err := base64.RawURLEncoding.DecodeString(token)
+	if err != nil {
+		return nil, ErrInvalidCursor
+	}
+
+	timestamp, id, found := strings.Cut(string(decoded), ",")
+	if !found {
+		return nil, ErrInvalidCursor
+	}
+
+	cursor := &Cursor{}
+
+	if cursor.Time, err = time.Parse(time.RFC3339Nano, timestamp); err != nil {
+		return nil, ErrInvalidCursor
+	}
+
+	if cursor.ID, err = strconv.Atoi(id); err != nil {
+		return nil, ErrInvalidCursor
+	}
+
+	return cursor, nil
+}
@@ -0,0 +1,57 @@
+package store
+
+import (
+	"encoding/base64"
+	"testing"
+	"time"
+)
+
+func TestCursorRoundTrip(t *testing.T) {
+	tests := []struct {
+		name   string
+		cursor Cursor
+	}{
+		{name: "utc", cursor: Cursor{Time: time.Date(2024, 6, 1, 12, 30, 0, 0, time.UTC), ID: 42}},
+		{name: "nanoseconds are kept", cursor: Cursor{Time: time.Date(2024, 6, 1, 12, 30, 0, 123456789, time.UTC), ID: 1}},
+		{name: "other zone", cursor: Cursor{Time: time.Date(2024, 6, 1, 8, 30, 0, 0, time.FixedZone("EDT", -4*60*60)), ID: 7}},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			parsed, err := ParseCursor(test.cursor.String())
+			if err != nil {
+				t.Fatalf("unexpected error: %s", err.Error())
+			}
+
+			if !parsed.Time.Equal(test.cursor.Time) || parsed.ID != test.cursor.ID {
+				t.Errorf("expected %v, got %v", test.cursor, *parsed)
+			}
+		})
+	}
+}
+
+func TestParseCursorRejectsInvalidTokens(t *testing.T) {
+	encode := func(value string) string {
+		return base64.RawURLEncoding.EncodeToString([]byte(value))
+	}
+
+	tests := []struct {
+		name  string
+		token string
+	}{
+		{name: "not base64", token: "not a cursor!"},
+		{name: "padded base64", token: base64.URLEncoding.EncodeToString([]byte("2024-06-01T12:30:00Z,1"))},
+		{name: "no separator", token: encode("2024-06-01T12:30:00Z")},
+		{name: "bad time", token: encode("yesterday,1")},
+		{name: "bad id", token: encode("2024-06-01T12:30:00Z,one")},
+		{name: "empty", token: encode("")},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			if cursor, err := ParseCursor(test.token); err != ErrInvalidCursor {
+				t.Errorf("expected ErrInvalidCursor, got %v, %v", cursor, err)
+			}
+		})
+	}
+}
@@ -1,13 +1,113 @@
 package store
 
 import (
+	"context"
+	"errors"
+	"time"
+
 	"redcellpartners.com/users-posts-api/model"
 )
 
+const (
+	POST_STATUS_DRAFT     = "draft"
+	POST_STATUS_SCHEDULED = "scheduled"
+	POST_STATUS_PUBLISHED = "published"
+	POST_STATUS_ARCHIVED  = "archived"
+)
+
+// POST_STATUSES are the states of a post's lifecycle. Only published posts
+// are listed by default.
+var POST_STATUSES = []string{POST_STATUS_DRAFT, POST_STATUS_SCHEDULED, POST_STATUS_PUBLISHED, POST_STATUS_ARCHIVED}
+
+// postTransitions lists the statuses a post can move to from each status.
+// Scheduling again moves the publish time of a scheduled post, and
+// publishing an archived post brings it back.
+var postTransitions = map[string][]string{
+	POST_STATUS_DRAFT:     {POST_STATUS_SCHEDULED, POST_STATUS_PUBLISHED, POST_STATUS_ARCHIVED},
+	POST_STATUS_SCHEDULED: {POST_STATUS_SCHEDULED, POST_STATUS_PUBLISHED, POST_STATUS_ARCHIVED},
+	POST_STATUS_PUBLISHED: {POST_STATUS_ARCHIVED},
+	POST_STATUS_ARCHIVED:  {POST_STATUS_PUBLISHED},
+}
+
+var ErrInvalidPostTransition = errors.New("post can not move to that status from its current status")
+
+// ErrPostOwnerDeleted is returned when restoring a post whose user is still
+// deleted, the user has to be restored instead.
+var ErrPostOwnerDeleted = errors.New("the post's user is deleted, restore the user to restore its posts")
+
+func ValidPostStatus(status string) bool {
+	_, ok := postTransitions[status]
+	return ok
+}
+
+func CanTransitionPost(from, to string) bool {
+	for _, allowed := range postTransitions[from] {
+		if allowed == to {
+			return true
+		}
+	}
+
+	return false
+}
+
+// PostFilter narrows ListPosts. Posts carrying any of Tags are listed, or
+// only posts carrying all of them when MatchAllTags is set. An empty Tags
+// lists every post. Only posts in one of Statuses are listed, published
+// posts when it is empty. Deleted posts are only listed with IncludeDeleted.
+type PostFilter struct {
+	Tags           []string
+	MatchAllTags   bool
+	Statuses       []string
+	IncludeDeleted bool
+}
+
+// PostStore reads and writes posts. Deleting a post soft deletes it, reads
+// other than ListPosts with IncludeDeleted leave deleted posts out and return
+// sql.ErrNoRows for them.
 type PostStore interface {
-	ListPosts() ([]*model.Post, error)
-	CreatePost(*model.Post) (*model.Post, error)
-	GetPost(id int) (*model.Post, error)
-	UpdatePost(*model.Post) (*model.Post, error)
-	DeletePost(id int) error
+	ListPosts(ctx context.Context, filter PostFilter) ([]*model.Post, error)
+	// CreatePost and UpdatePost set the post's tags in the same transaction
+	// as the post. UpdatePost keeps the current tags when Tags is nil and
+	// never changes the status, which only moves through PublishPost and
+	// ArchivePost. CreatePost publishes the post unless Status says
+	// otherwise, and returns ErrUnknownUser when the author does not exist
+	// or is deleted.
+	CreatePost(ctx context.Context, post *model.Post) (*model.Post, error)
+	GetPost(ctx context.Context, id int) (*model.Post, error)
+	// GetPostBySlug finds a post by its current slug or any slug it had
+	// before its title changed, the post's Slug being the current one.
+	GetPostBySlug(ctx context.Context, slug string) (*model.Post, error)
+	UpdatePost(ctx context.Context, post *model.Post) (*model.Post, error)
+	DeletePost(ctx context.Context, id int) error
+	// ListTags returns every tag in use with the number of posts carrying
+	// it, most used first.
+	ListTags(ctx context.Context) ([]*model.Tag, error)
+	// ListFeed returns a page of the posts of the users userID follows,
+	// newest first.
+	ListFeed(ctx context.Context, userID int, page Page) ([]*model.Post, error)
+	// SearchPosts returns a page of the published posts matching query, best
+	// match first. Quoted phrases must match as a whole and terms prefixed
+	// with a dash must not match.
+	SearchPosts(ctx context.Context, query string, page SearchPage) ([]*model.PostSearchResult, error)
+	// PublishPost publishes the post at publishAt, scheduling it when
+	// publishAt is in the future and publishing it right away otherwise.
+	PublishPost(ctx context.Context, id int, publishAt time.Time) (*model.Post, error)
+	ArchivePost(ctx context.Context, id int) (*model.Post, error)
+	// PublishScheduledPosts publishes up to limit scheduled posts that are
+	// due at now and returns how many it published. Posts being published
+	// by another replica are skipped, never published twice.
+	PublishScheduledPosts(ctx context.Context, now time.Time, limit int) (int, error)
+	// Every create, update and restore writes the post's title and content
+	// as its next revision. ListPostRevisions returns the retained ones,
+	// newest first, and GetPostRevision returns sql.ErrNoRows for a
+	// revision that does not exist or is no longer retained.
+	ListPostRevisions(ctx context.Context, postID int) ([]*model.PostRevision, error)
+	GetPostRevision(ctx context.Context, postID, revision int) (*model.PostRevision, error)
+	RestorePostRevision(ctx context.Context, postID, revision int) (*model.Post, error)
+	// RestorePost brings back a deleted post. It returns sql.ErrNoRows when
+	// there is no deleted post with the id.
+	RestorePost(ctx context.Context, id int) (*model.Post, error)
+	// PurgeDeletedPosts permanently deletes up to limit posts deleted before
+	// the cutoff and returns how many it purged.
+	PurgeDeletedPosts(ctx context.Context, cutoff time.Time, limit int) (int, error)
 }
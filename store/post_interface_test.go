@@ -0,0 +1,43 @@
+package store
+
+import "testing"
+
+func TestCanTransitionPost(t *testing.T) {
+	// every allowed transition, anything else must be refused
+	allowed := map[[2]string]bool{
+		{POST_STATUS_DRAFT, POST_STATUS_SCHEDULED}:     true,
+		{POST_STATUS_DRAFT, POST_STATUS_PUBLISHED}:     true,
+		{POST_STATUS_DRAFT, POST_STATUS_ARCHIVED}:      true,
+		{POST_STATUS_SCHEDULED, POST_STATUS_SCHEDULED}: true,
+		{POST_STATUS_SCHEDULED, POST_STATUS_PUBLISHED}: true,
+		{POST_STATUS_SCHEDULED, POST_STATUS_ARCHIVED}:  true,
+		{POST_STATUS_PUBLISHED, POST_STATUS_ARCHIVED}:  true,
+		{POST_STATUS_ARCHIVED, POST_STATUS_PUBLISHED}:  true,
+	}
+
+	statuses := append(POST_STATUSES, "deleted", "")
+
+	for _, from := range statuses {
+		for _, to := range statuses {
+			expected := allowed[[2]string{from, to}]
+
+			if got := CanTransitionPost(from, to); got != expected {
+				t.Errorf("expected %q -> %q allowed: %t, got %t", from, to, expected, got)
+			}
+		}
+	}
+}
+
+func TestValidPostStatus(t *testing.T) {
+	for _, status := range POST_STATUSES {
+		if !ValidPostStatus(status) {
+			t.Errorf("expected %q to be a valid status", status)
+		}
+	}
+
+	for _, status := range []string{"", "deleted", "Published", " draft"} {
+		if ValidPostStatus(status) {
+			t.Errorf("expected %q to be an invalid status", status)
+		}
+	}
+}
@@ -0,0 +1,201 @@
+package postgres
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"reflect"
+
+	"go.uber.org/zap"
+	"redcellpartners.com/users-posts-api/model"
+	"redcellpartners.com/users-posts-api/store"
+)
+
+const (
+	defaultAuditListLimit = 100
+	maxAuditListLimit     = 1000
+)
+
+var _ store.AuditStore = &PostgresAuditClient{}
+
+type PostgresAuditClient struct {
+	listAuditEventsStmt *statement
+
+	logger *zap.Logger
+}
+
+func NewPostgresAuditClient(db *sql.DB, logger *zap.Logger) (*PostgresAuditClient, error) {
+	client := &PostgresAuditClient{
+		logger: logger,
+	}
+
+	var err error
+
+	client.listAuditEventsStmt, err = prepare(db, "audit_events.list", `SELECT id, actor, COALESCE(request_id, ''), entity, entity_id, action, diff, created_at FROM audit_events
+WHERE ($1 = '' OR entity = $1)
+  AND ($2 = 0 OR entity_id = $2)
+  AND ($3 = '' OR actor = $3)
+  AND ($4::timestamptz IS NULL OR created_at >= $4)
+  AND ($5::timestamptz IS NULL OR created_at < $5)
+ORDER BY created_at DESC, id DESC
+LIMIT $6;`)
+	if err != nil {
+		return nil, err
+	}
+
+	return client, nil
+}
+
+// Close releases the client's prepared statements. The client must not be
+// used afterwards.
+func (client *PostgresAuditClient) Close() error {
+	return closeStatements(
+		client.listAuditEventsStmt,
+	)
+}
+
+func (client *PostgresAuditClient) ListAuditEvents(ctx context.Context, filter store.AuditFilter) ([]*model.AuditEvent, error) {
+	var since, until sql.NullTime
+
+	if !filter.Since.IsZero() {
+		since = sql.NullTime{Time: filter.Since, Valid: true}
+	}
+
+	if !filter.Until.IsZero() {
+		until = sql.NullTime{Time: filter.Until, Valid: true}
+	}
+
+	limit := filter.Limit
+	if limit <= 0 {
+		limit = defaultAuditListLimit
+	} else if limit > maxAuditListLimit {
+		limit = maxAuditListLimit
+	}
+
+	rows, err := client.listAuditEventsStmt.query(ctx, nil, filter.Entity, filter.EntityID, filter.Actor, since, until, limit)
+	if err != nil {
+		return nil, fmt.Errorf("unable to list audit events: %s", err.Error())
+	}
+
+	defer rows.Close()
+
+	events := make([]*model.AuditEvent, 0)
+
+	for rows.Next() {
+		var (
+			event = &model.AuditEvent{}
+			diff  []byte
+		)
+
+		if err := rows.Scan(
+			&event.ID,
+			&event.Actor,
+			&event.RequestID,
+			&event.Entity,
+			&event.EntityID,
+			&event.Action,
+			&diff,
+			&event.CreatedTime,
+		); err != nil {
+			return nil, fmt.Errorf("unable to scan audit event: %s", err.Error())
+		}
+
+		event.Diff = json.RawMessage(diff)
+
+		events = append(events, event)
+	}
+
+	if err = rows.Err(); err != nil {
+		return nil, fmt.Errorf("unable to iterate audit events: %s", err.Error())
+	}
+
+	return events, nil
+}
+
+func prepareInsertAuditEvent(db *sql.DB) (*statement, error) {
+	return prepare(db, "audit_events.insert", "INSERT INTO audit_events (actor, request_id, entity, entity_id, action, diff) VALUES ($1, NULLIF($2, ''), $3, $4, $5, $6);")
+}
+
+// recordAuditEvent writes an audit event for the change within the
+// transaction making it, so the change and its audit trail commit together.
+func recordAuditEvent(ctx context.Context, tx *sql.Tx, insertStmt *statement, entity string, entityID int, action string, before, after interface{}) error {
+	diff, err := auditDiff(before, after)
+	if err != nil {
+		return fmt.Errorf("unable to diff %s [%d]: %s", entity, entityID, err.Error())
+	}
+
+	if _, err = insertStmt.exec(ctx, tx,
+		store.ActorFromContext(ctx),
+		store.RequestIDFromContext(ctx),
+		entity,
+		entityID,
+		action,
+		string(diff),
+	); err != nil {
+		return fmt.Errorf("unable to record audit event for %s [%d]: %s", entity, entityID, err.Error())
+	}
+
+	return nil
+}
+
+type auditChange struct {
+	Before interface{} `json:"before"`
+	After  interface{} `json:"after"`
+}
+
+// auditDiff returns a JSON object keyed by every field whose JSON value
+// differs between before and after. A nil before or after (for creates and
+// deletes) is treated as every field being absent.
+func auditDiff(before, after interface{}) ([]byte, error) {
+	beforeFields, err := auditFields(before)
+	if err != nil {
+		return nil, err
+	}
+
+	afterFields, err := auditFields(after)
+	if err != nil {
+		return nil, err
+	}
+
+	changes := make(map[string]auditChange)
+
+	for field, beforeValue := range beforeFields {
+		afterValue := afterFields[field]
+
+		if !reflect.DeepEqual(beforeValue, afterValue) {
+			changes[field] = auditChange{Before: beforeValue, After: afterValue}
+		}
+	}
+
+	for field, afterValue := range afterFields {
+		if _, ok := beforeFields[field]; !ok {
+			changes[field] = auditChange{Before: nil, After: afterValue}
+		}
+	}
+
+	return json.Marshal(changes)
+}
+
+func auditFields(value interface{}) (map[string]interface{}, error) {
+	fields := make(map[string]interface{})
+
+	if value == nil {
+		return fields, nil
+	}
+
+	if reflected := reflect.ValueOf(value); reflected.Kind() == reflect.Ptr && reflected.IsNil() {
+		return fields, nil
+	}
+
+	valueBytes, err := json.Marshal(value)
+	if err != nil {
+		return nil, err
+	}
+
+	if err = json.Unmarshal(valueBytes, &fields); err != nil {
+		return nil, err
+	}
+
+	return fields, nil
+}
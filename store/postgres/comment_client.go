@@ -0,0 +1,341 @@
+package postgres
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/lib/pq"
+	"go.uber.org/zap"
+	"redcellpartners.com/users-posts-api/logging"
+	"redcellpartners.com/users-posts-api/model"
+	"redcellpartners.com/users-posts-api/store"
+)
+
+const (
+	commentColumns = "id, post_id, user_id, parent_id, depth, content, created_at, updated_at"
+
+	// commentOfLivePost limits a comment query to comments of posts that are
+	// not deleted.
+	commentOfLivePost = "EXISTS (SELECT 1 FROM posts WHERE posts.id = comments.post_id AND posts.deleted_at IS NULL)"
+
+	commentUserConstraint = "comments_user_id_fkey"
+)
+
+var _ store.CommentStore = &PostgresCommentClient{}
+
+type PostgresCommentClient struct {
+	db       *sql.DB
+	maxDepth int
+
+	listCommentsStmt     *statement
+	createCommentStmt    *statement
+	getCommentStmt       *statement
+	lockCommentStmt      *statement
+	lockLivePostStmt     *statement
+	updateCommentStmt    *statement
+	deleteCommentStmt    *statement
+	activeUserStmt       *statement
+	insertAuditEventStmt *statement
+
+	logger *zap.Logger
+}
+
+// NewPostgresCommentClient returns a comment store allowing replies down to
+// maxDepth, where top level comments have depth 0.
+func NewPostgresCommentClient(db *sql.DB, maxDepth int, logger *zap.Logger) (*PostgresCommentClient, error) {
+	client := &PostgresCommentClient{
+		db:       db,
+		maxDepth: maxDepth,
+		logger:   logger,
+	}
+
+	var err error
+
+	client.listCommentsStmt, err = prepare(db, "comments.list", `WITH RECURSIVE thread AS (
+    SELECT `+commentColumns+`, ARRAY[id] AS path FROM comments WHERE post_id = $1 AND parent_id IS NULL
+    UNION ALL
+    SELECT c.id, c.post_id, c.user_id, c.parent_id, c.depth, c.content, c.created_at, c.updated_at, thread.path || c.id
+    FROM comments c JOIN thread ON c.parent_id = thread.id
+)
+SELECT `+commentColumns+` FROM thread ORDER BY path LIMIT 1000;`)
+	if err != nil {
+		return nil, err
+	}
+
+	client.createCommentStmt, err = prepare(db, "comments.create", "INSERT INTO comments (post_id, user_id, parent_id, depth, content, created_at) VALUES ($1, $2, $3, $4, $5, $6) RETURNING "+commentColumns+";")
+	if err != nil {
+		return nil, err
+	}
+
+	client.getCommentStmt, err = prepare(db, "comments.get", "SELECT "+commentColumns+" FROM comments WHERE id = $1 AND "+commentOfLivePost+";")
+	if err != nil {
+		return nil, err
+	}
+
+	client.lockCommentStmt, err = prepare(db, "comments.lock", "SELECT "+commentColumns+" FROM comments WHERE id = $1 AND "+commentOfLivePost+" FOR UPDATE;")
+	if err != nil {
+		return nil, err
+	}
+
+	// shares the lock deleting a post takes, so a post can't be deleted while
+	// it is commented on
+	client.lockLivePostStmt, err = prepare(db, "posts.lock_live", "SELECT id FROM posts WHERE id = $1 AND deleted_at IS NULL FOR SHARE;")
+	if err != nil {
+		return nil, err
+	}
+
+	client.updateCommentStmt, err = prepare(db, "comments.update", "UPDATE comments SET content = $2, updated_at = $3 WHERE id = $1 RETURNING "+commentColumns+";")
+	if err != nil {
+		return nil, err
+	}
+
+	client.deleteCommentStmt, err = prepare(db, "comments.delete", "DELETE FROM comments WHERE id = $1;")
+	if err != nil {
+		return nil, err
+	}
+
+	client.activeUserStmt, err = prepare(db, "users.active", "SELECT EXISTS (SELECT 1 FROM users WHERE id = $1 AND deleted_at IS NULL);")
+	if err != nil {
+		return nil, err
+	}
+
+	client.insertAuditEventStmt, err = prepareInsertAuditEvent(db)
+	if err != nil {
+		return nil, err
+	}
+
+	return client, nil
+}
+
+// Close releases the client's prepared statements. The client must not be
+// used afterwards.
+func (client *PostgresCommentClient) Close() error {
+	return closeStatements(
+		client.listCommentsStmt,
+		client.createCommentStmt,
+		client.getCommentStmt,
+		client.lockCommentStmt,
+		client.lockLivePostStmt,
+		client.updateCommentStmt,
+		client.deleteCommentStmt,
+		client.activeUserStmt,
+		client.insertAuditEventStmt,
+	)
+}
+
+func (client *PostgresCommentClient) ListComments(ctx context.Context, postID int) ([]*model.Comment, error) {
+	rows, err := client.listCommentsStmt.query(ctx, nil, postID)
+	if err != nil {
+		logging.FromContext(ctx, client.logger).Error("unable to list comments", zap.Int("post_id", postID), zap.Error(err))
+		return nil, err
+	}
+
+	defer rows.Close()
+
+	comments := make([]*model.Comment, 0)
+
+	for rows.Next() {
+		comment, err := scanComment(rows)
+		if err != nil {
+			return nil, fmt.Errorf("unable to scan comment: %s", err.Error())
+		}
+
+		comments = append(comments, comment)
+	}
+
+	if err = rows.Err(); err != nil {
+		return nil, fmt.Errorf("unable to iterate comments of post [%d]: %s", postID, err.Error())
+	}
+
+	return comments, nil
+}
+
+// CreateComment inserts a comment. A reply takes its depth from its parent,
+// which is locked so it can not be deleted while the reply is inserted. It
+// returns sql.ErrNoRows when the post does not exist or is deleted.
+func (client *PostgresCommentClient) CreateComment(ctx context.Context, comment *model.Comment) (*model.Comment, error) {
+	var active bool
+
+	if err := client.activeUserStmt.queryRow(ctx, nil, comment.UserID).Scan(&active); err != nil {
+		return nil, fmt.Errorf("unable to check user [%d]: %s", comment.UserID, err.Error())
+	}
+
+	if !active {
+		return nil, store.ErrUnknownUser
+	}
+
+	tx, err := client.db.BeginTx(ctx, nil)
+	if err != nil {
+		return nil, fmt.Errorf("unable to begin create comment transaction: %s", err.Error())
+	}
+
+	defer tx.Rollback()
+
+	var postID int
+
+	if err = client.lockLivePostStmt.queryRow(ctx, tx, comment.PostID).Scan(&postID); err == sql.ErrNoRows {
+		return nil, err
+	} else if err != nil {
+		return nil, fmt.Errorf("unable to lock post [%d]: %s", comment.PostID, err.Error())
+	}
+
+	var (
+		depth    = 0
+		parentID sql.NullInt64
+	)
+
+	if comment.ParentID != nil {
+		parent, err := scanComment(client.lockCommentStmt.queryRow(ctx, tx, *comment.ParentID))
+		if err == sql.ErrNoRows || (err == nil && parent.PostID != comment.PostID) {
+			return nil, store.ErrCommentParentNotFound
+		} else if err != nil {
+			return nil, fmt.Errorf("unable to lock parent comment [%d]: %s", *comment.ParentID, err.Error())
+		}
+
+		depth = parent.Depth + 1
+		if depth > client.maxDepth {
+			return nil, store.ErrCommentTooDeep
+		}
+
+		parentID = sql.NullInt64{Int64: int64(parent.ID), Valid: true}
+	}
+
+	created, err := scanComment(client.createCommentStmt.queryRow(ctx, tx, comment.PostID, comment.UserID, parentID, depth, comment.Content, time.Now()))
+
+	// the user may have been purged since it was checked
+	var pqErr *pq.Error
+	if errors.As(err, &pqErr) && pqErr.Code == foreignKeyViolation && pqErr.Constraint == commentUserConstraint {
+		return nil, store.ErrUnknownUser
+	} else if err != nil {
+		return nil, fmt.Errorf("unable to create comment: %s", err.Error())
+	}
+
+	if err = recordAuditEvent(ctx, tx, client.insertAuditEventStmt, store.AuditEntityComment, created.ID, store.AuditActionCreate, nil, created); err != nil {
+		return nil, err
+	}
+
+	if err = tx.Commit(); err != nil {
+		return nil, fmt.Errorf("unable to commit created comment: %s", err.Error())
+	}
+
+	return created, nil
+}
+
+func (client *PostgresCommentClient) GetComment(ctx context.Context, id int) (*model.Comment, error) {
+	comment, err := scanComment(client.getCommentStmt.queryRow(ctx, nil, id))
+	if err != nil && err == sql.ErrNoRows {
+		return nil, err
+	} else if err != nil {
+		return nil, fmt.Errorf("unable to scan comment [%d]: %s", id, err.Error())
+	}
+
+	return comment, nil
+}
+
+// UpdateComment changes the content of a comment, its post, author and place
+// in the thread are fixed.
+func (client *PostgresCommentClient) UpdateComment(ctx context.Context, commentInput *model.Comment) (*model.Comment, error) {
+	tx, err := client.db.BeginTx(ctx, nil)
+	if err != nil {
+		return nil, fmt.Errorf("unable to begin update comment transaction: %s", err.Error())
+	}
+
+	defer tx.Rollback()
+
+	before, err := scanComment(client.lockCommentStmt.queryRow(ctx, tx, commentInput.ID))
+	if err != nil && err == sql.ErrNoRows {
+		return nil, err
+	} else if err != nil {
+		return nil, fmt.Errorf("unable to lock comment [%d]: %s", commentInput.ID, err.Error())
+	}
+
+	comment, err := scanComment(client.updateCommentStmt.queryRow(ctx, tx, commentInput.ID, commentInput.Content, time.Now()))
+	if err != nil {
+		return nil, fmt.Errorf("unable to scan comment [%d]: %s", commentInput.ID, err.Error())
+	}
+
+	if err = recordAuditEvent(ctx, tx, client.insertAuditEventStmt, store.AuditEntityComment, comment.ID, store.AuditActionUpdate, before, comment); err != nil {
+		return nil, err
+	}
+
+	if err = tx.Commit(); err != nil {
+		return nil, fmt.Errorf("unable to commit updated comment [%d]: %s", comment.ID, err.Error())
+	}
+
+	return comment, nil
+}
+
+func (client *PostgresCommentClient) DeleteComment(ctx context.Context, id int) error {
+	tx, err := client.db.BeginTx(ctx, nil)
+	if err != nil {
+		return fmt.Errorf("unable to begin delete comment transaction: %s", err.Error())
+	}
+
+	defer tx.Rollback()
+
+	before, err := scanComment(client.lockCommentStmt.queryRow(ctx, tx, id))
+	if err != nil && err == sql.ErrNoRows {
+		return err
+	} else if err != nil {
+		return fmt.Errorf("unable to lock comment [%d]: %s", id, err.Error())
+	}
+
+	result, err := client.deleteCommentStmt.exec(ctx, tx, id)
+	if err != nil {
+		return fmt.Errorf("unable to delete comment [%d]: %s", id, err.Error())
+	}
+
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		return fmt.Errorf("error getting rows affected for comment [%d]: %s", id, err.Error())
+	}
+
+	if rowsAffected != int64(1) {
+		return fmt.Errorf("deleted 0 or more than one comment requested")
+	}
+
+	if err = recordAuditEvent(ctx, tx, client.insertAuditEventStmt, store.AuditEntityComment, id, store.AuditActionDelete, before, nil); err != nil {
+		return err
+	}
+
+	if err = tx.Commit(); err != nil {
+		return fmt.Errorf("unable to commit deleted comment [%d]: %s", id, err.Error())
+	}
+
+	return nil
+}
+
+func scanComment(row rowScanner) (*model.Comment, error) {
+	var (
+		comment   = &model.Comment{}
+		parentID  sql.NullInt64
+		updatedAt sql.NullTime
+	)
+
+	if err := row.Scan(
+		&comment.ID,
+		&comment.PostID,
+		&comment.UserID,
+		&parentID,
+		&comment.Depth,
+		&comment.Content,
+		&comment.CreatedTime,
+		&updatedAt,
+	); err != nil {
+		return nil, err
+	}
+
+	if parentID.Valid {
+		id := int(parentID.Int64)
+		comment.ParentID = &id
+	}
+
+	if updatedAt.Valid {
+		comment.UpdatedTime = updatedAt.Time
+	}
+
+	return comment, nil
+}
@@ -0,0 +1,99 @@
+package postgres
+
+import (
+	"context"
+	"database/sql/driver"
+	"io"
+)
+
+// fakeConnector is a database that accepts every statement and answers
+// queries with the rows of respond, or with no rows when it is nil, so the
+// clients run their real statements without postgres.
+type fakeConnector struct {
+	respond func(query string, args []driver.Value) *fakeRows
+}
+
+func (connector fakeConnector) Connect(context.Context) (driver.Conn, error) {
+	return fakeConn{respond: connector.respond}, nil
+}
+
+func (connector fakeConnector) Driver() driver.Driver {
+	return nil
+}
+
+type fakeConn struct {
+	respond func(query string, args []driver.Value) *fakeRows
+}
+
+func (conn fakeConn) Prepare(query string) (driver.Stmt, error) {
+	return fakeStmt{query: query, respond: conn.respond}, nil
+}
+
+func (conn fakeConn) Close() error {
+	return nil
+}
+
+func (conn fakeConn) Begin() (driver.Tx, error) {
+	return fakeTx{}, nil
+}
+
+type fakeTx struct{}
+
+func (tx fakeTx) Commit() error {
+	return nil
+}
+
+func (tx fakeTx) Rollback() error {
+	return nil
+}
+
+type fakeStmt struct {
+	query   string
+	respond func(query string, args []driver.Value) *fakeRows
+}
+
+func (stmt fakeStmt) Close() error {
+	return nil
+}
+
+func (stmt fakeStmt) NumInput() int {
+	return -1
+}
+
+func (stmt fakeStmt) Exec(args []driver.Value) (driver.Result, error) {
+	return driver.RowsAffected(0), nil
+}
+
+func (stmt fakeStmt) Query(args []driver.Value) (driver.Rows, error) {
+	if stmt.respond != nil {
+		if rows := stmt.respond(stmt.query, args); rows != nil {
+			return rows, nil
+		}
+	}
+
+	return &fakeRows{}, nil
+}
+
+type fakeRows struct {
+	columns []string
+	values  [][]driver.Value
+}
+
+func (rows *fakeRows) Columns() []string {
+	return rows.columns
+}
+
+func (rows *fakeRows) Close() error {
+	return nil
+}
+
+func (rows *fakeRows) Next(dest []driver.Value) error {
+	if len(rows.values) == 0 {
+		return io.EOF
+	}
+
+	copy(dest, rows.values[0])
+	rows.values = rows.values[1:]
+
+	return nil
+}
@@ -0,0 +1,139 @@
+package postgres
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+
+	"go.uber.org/zap"
+	"redcellpartners.com/users-posts-api/model"
+	"redcellpartners.com/users-posts-api/store"
+)
+
+var _ store.FollowStore = &PostgresFollowClient{}
+
+type PostgresFollowClient struct {
+	db *sql.DB
+
+	followStmt        *statement
+	unfollowStmt      *statement
+	listFollowersStmt *statement
+	listFollowingStmt *statement
+
+	logger *zap.Logger
+}
+
+func NewPostgresFollowClient(db *sql.DB, logger *zap.Logger) (*PostgresFollowClient, error) {
+	client := &PostgresFollowClient{
+		db:     db,
+		logger: logger,
+	}
+
+	var err error
+
+	// returns whether the target is a user that can be followed, which tells
+	// a missing or deleted target apart from an existing follow
+	client.followStmt, err = prepare(db, "follows.insert", `WITH target AS (
+    SELECT id FROM users WHERE id = $2 AND deleted_at IS NULL
+), inserted AS (
+    INSERT INTO follows (follower_id, followee_id) SELECT $1, id FROM target ON CONFLICT DO NOTHING
+)
+SELECT count(*) FROM target;`)
+	if err != nil {
+		return nil, err
+	}
+
+	client.unfollowStmt, err = prepare(db, "follows.delete", "DELETE FROM follows WHERE follower_id = $1 AND followee_id = $2;")
+	if err != nil {
+		return nil, err
+	}
+
+	client.listFollowersStmt, err = prepare(db, "follows.followers", `SELECT follower_id, created_at FROM follows
+WHERE followee_id = $1 AND (created_at, follower_id) < ($2, $3)
+  AND NOT EXISTS (SELECT 1 FROM users WHERE users.id = follower_id AND users.deleted_at IS NOT NULL)
+ORDER BY created_at DESC, follower_id DESC
+LIMIT $4;`)
+	if err != nil {
+		return nil, err
+	}
+
+	client.listFollowingStmt, err = prepare(db, "follows.following", `SELECT followee_id, created_at FROM follows
+WHERE follower_id = $1 AND (created_at, followee_id) < ($2, $3)
+  AND NOT EXISTS (SELECT 1 FROM users WHERE users.id = followee_id AND users.deleted_at IS NOT NULL)
+ORDER BY created_at DESC, followee_id DESC
+LIMIT $4;`)
+	if err != nil {
+		return nil, err
+	}
+
+	return client, nil
+}
+
+// Close releases the client's prepared statements. The client must not be
+// used afterwards.
+func (client *PostgresFollowClient) Close() error {
+	return closeStatements(
+		client.followStmt,
+		client.unfollowStmt,
+		client.listFollowersStmt,
+		client.listFollowingStmt,
+	)
+}
+
+func (client *PostgresFollowClient) Follow(ctx context.Context, userID, targetID int) error {
+	var targets int
+
+	if err := client.followStmt.queryRow(ctx, nil, userID, targetID).Scan(&targets); err != nil {
+		return fmt.Errorf("unable to follow user [%d] as user [%d]: %s", targetID, userID, err.Error())
+	}
+
+	if targets == 0 {
+		return store.ErrUnknownUser
+	}
+
+	return nil
+}
+
+func (client *PostgresFollowClient) Unfollow(ctx context.Context, userID, targetID int) error {
+	if _, err := client.unfollowStmt.exec(ctx, nil, userID, targetID); err != nil {
+		return fmt.Errorf("unable to unfollow user [%d] as user [%d]: %s", targetID, userID, err.Error())
+	}
+
+	return nil
+}
+
+func (client *PostgresFollowClient) ListFollowers(ctx context.Context, userID int, page store.Page) ([]*model.Follow, error) {
+	return client.listFollows(ctx, client.listFollowersStmt, userID, page)
+}
+
+func (client *PostgresFollowClient) ListFollowing(ctx context.Context, userID int, page store.Page) ([]*model.Follow, error) {
+	return client.listFollows(ctx, client.listFollowingStmt, userID, page)
+}
+
+func (client *PostgresFollowClient) listFollows(ctx context.Context, stmt *statement, userID int, page store.Page) ([]*model.Follow, error) {
+	cursorTime, cursorID, limit := pageArgs(page)
+
+	rows, err := stmt.query(ctx, nil, userID, cursorTime, cursorID, limit)
+	if err != nil {
+		return nil, fmt.Errorf("unable to list follows of user [%d]: %s", userID, err.Error())
+	}
+
+	defer rows.Close()
+
+	follows := make([]*model.Follow, 0, limit)
+
+	for rows.Next() {
+		follow := &model.Follow{}
+		if err := rows.Scan(&follow.UserID, &follow.FollowedTime); err != nil {
+			return nil, fmt.Errorf("unable to scan follow: %s", err.Error())
+		}
+
+		follows = append(follows, follow)
+	}
+
+	if err = rows.Err(); err != nil {
+		return nil, fmt.Errorf("unable to iterate follows of user [%d]: %s", userID, err.Error())
+	}
+
+	return follows, nil
+}
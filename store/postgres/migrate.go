@@ -0,0 +1,179 @@
+package postgres
+
+import (
+	"context"
+	"database/sql"
+	"embed"
+	"fmt"
+	"io/fs"
+	"sort"
+	"strconv"
+	"strings"
+
+	"go.uber.org/zap"
+)
+
+// migrationsLockID is the postgres advisory lock key held while migrations are
+// applied so that multiple replicas starting at once do not race each other.
+const migrationsLockID = 727274001
+
+//go:embed migrations/*.sql
+var migrationFiles embed.FS
+
+type migration struct {
+	version int
+	name    string
+	sql     string
+}
+
+func loadMigrations() ([]migration, error) {
+	entries, err := fs.ReadDir(migrationFiles, "migrations")
+	if err != nil {
+		return nil, fmt.Errorf("unable to read embedded migrations: %s", err.Error())
+	}
+
+	migrations := make([]migration, 0, len(entries))
+
+	for _, entry := range entries {
+		name := entry.Name()
+
+		prefix, _, found := strings.Cut(name, "_")
+		if !found {
+			return nil, fmt.Errorf("migration file %s is missing a version prefix", name)
+		}
+
+		version, err := strconv.Atoi(prefix)
+		if err != nil {
+			return nil, fmt.Errorf("migration file %s has an invalid version prefix: %s", name, err.Error())
+		}
+
+		contents, err := migrationFiles.ReadFile("migrations/" + name)
+		if err != nil {
+			return nil, fmt.Errorf("unable to read migration file %s: %s", name, err.Error())
+		}
+
+		migrations = append(migrations, migration{
+			version: version,
+			name:    name,
+			sql:     string(contents),
+		})
+	}
+
+	sort.Slice(migrations, func(i, j int) bool {
+		return migrations[i].version < migrations[j].version
+	})
+
+	return migrations, nil
+}
+
+// Migrate applies every embedded migration that has not yet been recorded in
+// the schema_migrations table. Each migration runs in its own transaction.
+func Migrate(db *sql.DB, logger *zap.Logger) error {
+	migrations, err := loadMigrations()
+	if err != nil {
+		return err
+	}
+
+	conn, err := db.Conn(context.Background())
+	if err != nil {
+		return fmt.Errorf("unable to get connection for migrations: %s", err.Error())
+	}
+
+	defer conn.Close()
+
+	if _, err = conn.ExecContext(context.Background(), "SELECT pg_advisory_lock($1);", migrationsLockID); err != nil {
+		return fmt.Errorf("unable to acquire migrations lock: %s", err.Error())
+	}
+
+	defer func() {
+		if _, err := conn.ExecContext(context.Background(), "SELECT pg_advisory_unlock($1);", migrationsLockID); err != nil {
+			logger.Warn("unable to release migrations lock", zap.Error(err))
+		}
+	}()
+
+	if _, err = conn.ExecContext(context.Background(), `CREATE TABLE IF NOT EXISTS schema_migrations (
+    version INTEGER PRIMARY KEY,
+    name VARCHAR(200) NOT NULL,
+    applied_at TIMESTAMP WITH TIME ZONE DEFAULT CURRENT_TIMESTAMP
+);`); err != nil {
+		return fmt.Errorf("unable to create schema_migrations table: %s", err.Error())
+	}
+
+	for _, m := range migrations {
+		var applied bool
+
+		row := conn.QueryRowContext(context.Background(), "SELECT EXISTS (SELECT 1 FROM schema_migrations WHERE version = $1);", m.version)
+		if err = row.Scan(&applied); err != nil {
+			return fmt.Errorf("unable to check migration %s: %s", m.name, err.Error())
+		}
+
+		if applied {
+			continue
+		}
+
+		logger.Info("applying migration", zap.String("migration", m.name))
+
+		tx, err := conn.BeginTx(context.Background(), nil)
+		if err != nil {
+			return fmt.Errorf("unable to begin migration %s: %s", m.name, err.Error())
+		}
+
+		if _, err = tx.Exec(m.sql); err != nil {
+			tx.Rollback()
+			return fmt.Errorf("unable to apply migration %s: %s", m.name, err.Error())
+		}
+
+		if _, err = tx.Exec("INSERT INTO schema_migrations (version, name) VALUES ($1, $2);", m.version, m.name); err != nil {
+			tx.Rollback()
+			return fmt.Errorf("unable to record migration %s: %s", m.name, err.Error())
+		}
+
+		if err = tx.Commit(); err != nil {
+			return fmt.Errorf("unable to commit migration %s: %s", m.name, err.Error())
+		}
+	}
+
+	return nil
+}
+
+// PendingMigrations returns the names of the embedded migrations that have
+// not been applied to the database yet.
+func PendingMigrations(ctx context.Context, db *sql.DB) ([]string, error) {
+	migrations, err := loadMigrations()
+	if err != nil {
+		return nil, err
+	}
+
+	rows, err := db.QueryContext(ctx, "SELECT version FROM schema_migrations;")
+	if err != nil {
+		return nil, fmt.Errorf("unable to list applied migrations: %s", err.Error())
+	}
+
+	defer rows.Close()
+
+	applied := make(map[int]bool)
+
+	for rows.Next() {
+		var version int
+
+		if err = rows.Scan(&version); err != nil {
+			return nil, fmt.Errorf("unable to scan applied migration: %s", err.Error())
+		}
+
+		applied[version] = true
+	}
+
+	if err = rows.Err(); err != nil {
+		return nil, fmt.Errorf("unable to iterate applied migrations: %s", err.Error())
+	}
+
+	pending := make([]string, 0)
+
+	for _, m := range migrations {
+		if !applied[m.version] {
+			pending = append(pending, m.name)
+		}
+	}
+
+	return pending, nil
+}
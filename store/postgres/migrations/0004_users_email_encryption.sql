@@ -0,0 +1,10 @@
+ALTER TABLE users ALTER COLUMN email DROP NOT NULL;
+
+ALTER TABLE users ADD COLUMN IF NOT EXISTS email_ciphertext BYTEA;
+ALTER TABLE users ADD COLUMN IF NOT EXISTS email_key_id VARCHAR(100);
+ALTER TABLE users ADD COLUMN IF NOT EXISTS email_hash BYTEA;
+
+ALTER TABLE users ADD CONSTRAINT users_email_present CHECK (email IS NOT NULL OR (email_ciphertext IS NOT NULL AND email_key_id IS NOT NULL AND email_hash IS NOT NULL));
+
+CREATE UNIQUE INDEX IF NOT EXISTS idx_users_email_hash ON users(email_hash);
+CREATE INDEX IF NOT EXISTS idx_users_email_key_id ON users(email_key_id);
@@ -0,0 +1,15 @@
+CREATE TABLE IF NOT EXISTS follows (
+    follower_id INTEGER NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    followee_id INTEGER NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    created_at TIMESTAMP WITH TIME ZONE NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    PRIMARY KEY (follower_id, followee_id),
+    CHECK (follower_id <> followee_id)
+);
+
+CREATE INDEX IF NOT EXISTS idx_follows_follower_created_at ON follows(follower_id, created_at DESC, followee_id DESC);
+CREATE INDEX IF NOT EXISTS idx_follows_followee_created_at ON follows(followee_id, created_at DESC, follower_id DESC);
+
+-- the feed reads the newest posts of each followed user straight off this
+-- index, it covers every lookup idx_posts_user_id served
+CREATE INDEX IF NOT EXISTS idx_posts_user_id_created_at ON posts(user_id, created_at DESC, id DESC);
+DROP INDEX IF EXISTS idx_posts_user_id;
@@ -0,0 +1,12 @@
+ALTER TABLE posts
+    ADD COLUMN IF NOT EXISTS status VARCHAR(20) NOT NULL DEFAULT 'published' CHECK (status IN ('draft', 'scheduled', 'published', 'archived')),
+    ADD COLUMN IF NOT EXISTS published_at TIMESTAMP WITH TIME ZONE;
+
+-- every existing post went live when it was created
+UPDATE posts SET published_at = COALESCE(created_at, CURRENT_TIMESTAMP) WHERE status = 'published' AND published_at IS NULL;
+
+-- the scheduler only ever looks for due scheduled posts
+CREATE INDEX IF NOT EXISTS idx_posts_scheduled_published_at ON posts(published_at) WHERE status = 'scheduled';
+
+-- the feed lists published posts by publish time
+CREATE INDEX IF NOT EXISTS idx_posts_user_id_published_at ON posts(user_id, published_at DESC, id DESC) WHERE status = 'published';
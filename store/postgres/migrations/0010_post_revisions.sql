@@ -0,0 +1,14 @@
+CREATE TABLE IF NOT EXISTS post_revisions (
+    post_id INTEGER NOT NULL REFERENCES posts(id) ON DELETE CASCADE,
+    revision INTEGER NOT NULL,
+    title VARCHAR(200) NOT NULL,
+    content TEXT NOT NULL,
+    actor VARCHAR(200) NOT NULL,
+    created_at TIMESTAMP WITH TIME ZONE NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    PRIMARY KEY (post_id, revision)
+);
+
+-- the current version of every existing post becomes its first revision
+INSERT INTO post_revisions (post_id, revision, title, content, actor, created_at)
+SELECT id, 1, title, content, 'anonymous', COALESCE(updated_at, created_at, CURRENT_TIMESTAMP) FROM posts
+ON CONFLICT DO NOTHING;
@@ -0,0 +1,10 @@
+ALTER TABLE users ADD COLUMN IF NOT EXISTS deleted_at TIMESTAMP WITH TIME ZONE;
+ALTER TABLE posts ADD COLUMN IF NOT EXISTS deleted_at TIMESTAMP WITH TIME ZONE;
+
+-- the purge job only ever looks for rows deleted before its retention window
+CREATE INDEX IF NOT EXISTS idx_users_deleted_at ON users(deleted_at) WHERE deleted_at IS NOT NULL;
+CREATE INDEX IF NOT EXISTS idx_posts_deleted_at ON posts(deleted_at) WHERE deleted_at IS NOT NULL;
+
+-- deleted posts drop out of the feed index
+DROP INDEX IF EXISTS idx_posts_user_id_published_at;
+CREATE INDEX IF NOT EXISTS idx_posts_user_id_published_at ON posts(user_id, published_at DESC, id DESC) WHERE status = 'published' AND deleted_at IS NULL;
@@ -0,0 +1,29 @@
+ALTER TABLE posts ADD COLUMN IF NOT EXISTS slug VARCHAR(100);
+
+-- posts written before slugs get their id appended, which keeps their slugs
+-- unique without looking for collisions
+UPDATE posts SET slug = coalesce(nullif(trim(both '-' from regexp_replace(lower(left(title, 80)), '[^a-z0-9]+', '-', 'g')), ''), 'post') || '-' || id
+WHERE slug IS NULL;
+
+ALTER TABLE posts ALTER COLUMN slug SET NOT NULL;
+
+CREATE UNIQUE INDEX IF NOT EXISTS idx_posts_slug ON posts(slug);
+
+-- every slug a post has had, so links made before its title changed still
+-- find it. Slugs are never handed to another post until this one is purged.
+CREATE TABLE IF NOT EXISTS post_slugs (
+    slug VARCHAR(100) PRIMARY KEY,
+    post_id INTEGER NOT NULL REFERENCES posts(id) ON DELETE CASCADE,
+    created_at TIMESTAMP WITH TIME ZONE NOT NULL DEFAULT CURRENT_TIMESTAMP
+);
+
+CREATE INDEX IF NOT EXISTS idx_post_slugs_post_id ON post_slugs(post_id);
+
+-- finding the free suffix of a slug looks up every slug starting with it
+CREATE INDEX IF NOT EXISTS idx_post_slugs_slug_prefix ON post_slugs(slug text_pattern_ops);
+
+INSERT INTO post_slugs (slug, post_id) SELECT slug, id FROM posts ON CONFLICT (slug) DO NOTHING;
+
+ALTER TABLE users ADD COLUMN IF NOT EXISTS handle VARCHAR(30);
+
+CREATE UNIQUE INDEX IF NOT EXISTS idx_users_handle ON users(handle);
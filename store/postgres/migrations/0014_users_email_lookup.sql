@@ -0,0 +1,5 @@
+-- Plaintext emails are matched case insensitively, like the hashes of
+-- encrypted ones. The keyed hash of plaintext rows cannot be computed here, the
+-- server backfills it on startup when a keyring is configured so that
+-- idx_users_email_hash covers every row.
+CREATE INDEX IF NOT EXISTS idx_users_email_lower ON users(lower(email));
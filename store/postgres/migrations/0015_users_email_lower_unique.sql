@@ -0,0 +1,6 @@
+-- Plaintext emails that differ only in case belong to the same address, so the
+-- lookup index also keeps them unique. Users already sharing an address in
+-- different cases have to be merged or changed before this migration runs.
+DROP INDEX IF EXISTS idx_users_email_lower;
+
+CREATE UNIQUE INDEX IF NOT EXISTS idx_users_email_lower ON users(lower(email));
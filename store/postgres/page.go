@@ -0,0 +1,31 @@
+package postgres
+
+import (
+	"math"
+	"time"
+
+	"redcellpartners.com/users-posts-api/store"
+)
+
+// firstPageCursor sorts after every row, so the first page can use the same
+// (created_at, id) < cursor range condition as every later page and stay an
+// index range scan.
+var firstPageCursor = store.Cursor{Time: time.Date(9999, 12, 31, 0, 0, 0, 0, time.UTC), ID: math.MaxInt32}
+
+// pageArgs returns the cursor time, cursor id and limit of a keyset page
+// query, clamping the limit to the allowed range.
+func pageArgs(page store.Page) (time.Time, int, int) {
+	cursor := firstPageCursor
+	if page.After != nil {
+		cursor = *page.After
+	}
+
+	limit := page.Limit
+	if limit <= 0 {
+		limit = store.DEFAULT_PAGE_LIMIT
+	} else if limit > store.MAX_PAGE_LIMIT {
+		limit = store.MAX_PAGE_LIMIT
+	}
+
+	return cursor.Time, cursor.ID, limit
+}
@@ -0,0 +1,35 @@
+package postgres
+
+import (
+	"testing"
+	"time"
+
+	"redcellpartners.com/users-posts-api/store"
+)
+
+func TestPageArgs(t *testing.T) {
+	after := &store.Cursor{Time: time.Date(2024, 6, 1, 12, 30, 0, 0, time.UTC), ID: 42}
+
+	tests := []struct {
+		name  string
+		page  store.Page
+		time  time.Time
+		id    int
+		limit int
+	}{
+		{name: "first page starts after every row", page: store.Page{Limit: 10}, time: firstPageCursor.Time, id: firstPageCursor.ID, limit: 10},
+		{name: "later page starts after the cursor", page: store.Page{After: after, Limit: 10}, time: after.Time, id: after.ID, limit: 10},
+		{name: "missing limit defaults", page: store.Page{}, time: firstPageCursor.Time, id: firstPageCursor.ID, limit: store.DEFAULT_PAGE_LIMIT},
+		{name: "limit is clamped", page: store.Page{Limit: store.MAX_PAGE_LIMIT + 1}, time: firstPageCursor.Time, id: firstPageCursor.ID, limit: store.MAX_PAGE_LIMIT},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			cursorTime, cursorID, limit := pageArgs(test.page)
+
+			if !cursorTime.Equal(test.time) || cursorID != test.id || limit != test.limit {
+				t.Errorf("expected (%s, %d, %d), got (%s, %d, %d)", test.time, test.id, test.limit, cursorTime, cursorID, limit)
+			}
+		})
+	}
+}
@@ -1,144 +1,1043 @@
 package postgres
 
 import (
+	"context"
 	"database/sql"
+	"encoding/json"
+	"errors"
 	"fmt"
+	"html"
+	"strings"
 	"time"
 
+	"github.com/lib/pq"
 	"go.uber.org/zap"
+	"redcellpartners.com/users-posts-api/logging"
 	"redcellpartners.com/users-posts-api/model"
 	"redcellpartners.com/users-posts-api/store"
 )
 
+const (
+	// searchMatchStart and searchMatchStop mark the matched words in the
+	// headlines of search results. They are control characters rather than
+	// the mark tags themselves so the content around them can be HTML escaped.
+	searchMatchStart = "\x02"
+	searchMatchStop  = "\x03"
+
+	postUserConstraint = "posts_user_id_fkey"
+)
+
+var searchMatchMarkers = strings.NewReplacer(searchMatchStart, store.SEARCH_HIGHLIGHT_START, searchMatchStop, store.SEARCH_HIGHLIGHT_STOP)
+
+// postColumns selects a post along with its sorted tags, its reaction
+// counts, which come from the counter table rather than counting reactions,
+// and its latest revision, which pruning always keeps.
+const postColumns = `id, user_id, title, slug, content, status, published_at, created_at, updated_at, deleted_at,
+    COALESCE((SELECT array_agg(t.name ORDER BY t.name) FROM post_tags pt JOIN tags t ON t.id = pt.tag_id WHERE pt.post_id = posts.id), '{}'),
+    COALESCE((SELECT json_object_agg(rc.kind, rc.count) FROM post_reaction_counts rc WHERE rc.post_id = posts.id AND rc.count > 0), '{}'),
+    COALESCE((SELECT max(pr.revision) FROM post_revisions pr WHERE pr.post_id = posts.id), 0)`
+
 var _ store.PostStore = &PostgresPostClient{}
 
-type PostgresPostClient struct {
-	listPostsStmt  *sql.Stmt
-	createPostStmt *sql.Stmt
-	getPostStmt    *sql.Stmt
-	updatePostStmt *sql.Stmt
-	deletePostStmt *sql.Stmt
+const postRevisionColumns = "post_id, revision, title, content, actor, created_at"
+
+type PostgresPostClient struct {
+	db            *sql.DB
+	revisionsKept int
+
+	listPostsStmt        *statement
+	createPostStmt       *statement
+	getPostStmt          *statement
+	lockPostStmt         *statement
+	updatePostStmt       *statement
+	deletePostStmt       *statement
+	ensureTagsStmt       *statement
+	clearPostTagsStmt    *statement
+	insertPostTagsStmt   *statement
+	listTagsStmt         *statement
+	viewerReactionsStmt  *statement
+	listFeedStmt         *statement
+	searchPostsStmt      *statement
+	getPostBySlugStmt    *statement
+	lockSlugStmt         *statement
+	takenSlugsStmt       *statement
+	insertSlugStmt       *statement
+	setStatusStmt        *statement
+	lockDueStmt          *statement
+	lockDeletedPostStmt  *statement
+	restorePostStmt      *statement
+	purgePostsStmt       *statement
+	insertRevisionStmt   *statement
+	pruneRevisionsStmt   *statement
+	listRevisionsStmt    *statement
+	getRevisionStmt      *statement
+	insertAuditEventStmt *statement
+	activeUserStmt       *statement
+
+	logger *zap.Logger
+}
+
+// NewPostgresPostClient returns a post store keeping the latest
+// revisionsKept revisions of each post, or every revision when it is 0.
+func NewPostgresPostClient(db *sql.DB, revisionsKept int, logger *zap.Logger) (*PostgresPostClient, error) {
+	client := &PostgresPostClient{
+		db:            db,
+		revisionsKept: revisionsKept,
+		logger:        logger,
+	}
+
+	var err error
+
+	client.listPostsStmt, err = prepare(db, "posts.list", `SELECT `+postColumns+` FROM posts
+WHERE status = ANY($3::text[])
+  AND ($4::boolean OR deleted_at IS NULL)
+  AND (cardinality($1::text[]) = 0
+   OR (NOT $2::boolean AND EXISTS (SELECT 1 FROM post_tags pt JOIN tags t ON t.id = pt.tag_id WHERE pt.post_id = posts.id AND t.name = ANY($1)))
+   OR ($2::boolean AND (SELECT count(*) FROM post_tags pt JOIN tags t ON t.id = pt.tag_id WHERE pt.post_id = posts.id AND t.name = ANY($1)) = cardinality($1::text[])))
+LIMIT 100;`)
+	if err != nil {
+		return nil, err
+	}
+
+	client.createPostStmt, err = prepare(db, "posts.create", "INSERT INTO posts (user_id, title, content, created_at, status, published_at, slug) VALUES ($1, $2, $3, $4, $5, $6, $7) RETURNING id;")
+	if err != nil {
+		return nil, err
+	}
+
+	client.getPostStmt, err = prepare(db, "posts.get", "SELECT "+postColumns+" FROM posts WHERE id = $1 AND deleted_at IS NULL;")
+	if err != nil {
+		return nil, err
+	}
+
+	client.lockPostStmt, err = prepare(db, "posts.lock", "SELECT "+postColumns+" FROM posts WHERE id = $1 AND deleted_at IS NULL FOR UPDATE;")
+	if err != nil {
+		return nil, err
+	}
+
+	client.updatePostStmt, err = prepare(db, "posts.update", "UPDATE posts SET title = $2, content = $3, updated_at = $4, slug = $5 WHERE id = $1;")
+	if err != nil {
+		return nil, err
+	}
+
+	client.deletePostStmt, err = prepare(db, "posts.delete", "UPDATE posts SET deleted_at = $2 WHERE id = $1 AND deleted_at IS NULL;")
+	if err != nil {
+		return nil, err
+	}
+
+	client.ensureTagsStmt, err = prepare(db, "tags.ensure", "INSERT INTO tags (name) SELECT unnest($1::text[]) ON CONFLICT (name) DO NOTHING;")
+	if err != nil {
+		return nil, err
+	}
+
+	client.clearPostTagsStmt, err = prepare(db, "post_tags.clear", "DELETE FROM post_tags WHERE post_id = $1;")
+	if err != nil {
+		return nil, err
+	}
+
+	client.insertPostTagsStmt, err = prepare(db, "post_tags.insert", "INSERT INTO post_tags (post_id, tag_id) SELECT $1, id FROM tags WHERE name = ANY($2::text[]);")
+	if err != nil {
+		return nil, err
+	}
+
+	client.listTagsStmt, err = prepare(db, "tags.list", `SELECT t.name, count(*) FROM tags t JOIN post_tags pt ON pt.tag_id = t.id
+JOIN posts p ON p.id = pt.post_id AND p.status = 'published' AND p.deleted_at IS NULL
+GROUP BY t.name
+ORDER BY count(*) DESC, t.name
+LIMIT 1000;`)
+	if err != nil {
+		return nil, err
+	}
+
+	client.viewerReactionsStmt, err = prepare(db, "post_reactions.viewer", "SELECT post_id, kind FROM post_reactions WHERE user_id = $1 AND post_id = ANY($2::int[]) ORDER BY post_id, kind;")
+	if err != nil {
+		return nil, err
+	}
+
+	// each followed user contributes at most a page of its newest posts from
+	// idx_posts_user_id_published_at, so the cost grows with the number of
+	// follows rather than the number of their posts. Only the page that
+	// remains is read in full.
+	client.listFeedStmt, err = prepare(db, "posts.feed", `WITH feed AS (
+    SELECT p.id, p.published_at FROM follows f
+    CROSS JOIN LATERAL (
+        SELECT id, published_at FROM posts
+        WHERE posts.user_id = f.followee_id AND posts.status = 'published' AND posts.deleted_at IS NULL AND (posts.published_at, posts.id) < ($2, $3)
+        ORDER BY posts.published_at DESC, posts.id DESC
+        LIMIT $4
+    ) p
+    WHERE f.follower_id = $1
+    ORDER BY p.published_at DESC, p.id DESC
+    LIMIT $4
+)
+SELECT `+postColumns+` FROM posts WHERE id IN (SELECT id FROM feed) ORDER BY published_at DESC, id DESC;`)
+	if err != nil {
+		return nil, err
+	}
+
+	// the GIN index on search finds the matching posts, which are then ranked
+	// in full to find the page. Snippets are only cut for the page itself.
+	client.searchPostsStmt, err = prepare(db, "posts.search", `WITH query AS (
+    SELECT websearch_to_tsquery('english', $1) AS q
+), ranked AS (
+    SELECT posts.id AS post_id, ts_rank(posts.search, query.q) AS post_rank FROM posts, query
+    WHERE posts.search @@ query.q AND posts.status = 'published' AND posts.deleted_at IS NULL
+), page AS (
+    SELECT post_id, post_rank FROM ranked
+    WHERE $2::real IS NULL OR (post_rank, post_id) < ($2::real, $3)
+    ORDER BY post_rank DESC, post_id DESC
+    LIMIT $4
+)
+SELECT page.post_rank, ts_headline('english', posts.content, query.q, 'StartSel=`+searchMatchStart+`, StopSel=`+searchMatchStop+`, MaxFragments=2, MaxWords=30, MinWords=10'),
+    `+postColumns+`
+FROM page JOIN posts ON posts.id = page.post_id, query
+ORDER BY page.post_rank DESC, page.post_id DESC;`)
+	if err != nil {
+		return nil, err
+	}
+
+	// an earlier slug of a post finds it as well as its current one
+	client.getPostBySlugStmt, err = prepare(db, "posts.get_by_slug", "SELECT "+postColumns+" FROM posts WHERE id = (SELECT post_id FROM post_slugs WHERE slug = $1) AND deleted_at IS NULL;")
+	if err != nil {
+		return nil, err
+	}
+
+	// slugs sharing a root are picked one transaction at a time, so two posts
+	// with the same title can not both take the same free suffix
+	client.lockSlugStmt, err = prepare(db, "post_slugs.lock", "SELECT pg_advisory_xact_lock(hashtext('post_slugs'), hashtext($1));")
+	if err != nil {
+		return nil, err
+	}
+
+	client.takenSlugsStmt, err = prepare(db, "post_slugs.taken", "SELECT slug, post_id FROM post_slugs WHERE slug = $1 OR slug LIKE $2;")
+	if err != nil {
+		return nil, err
+	}
+
+	client.insertSlugStmt, err = prepare(db, "post_slugs.insert", "INSERT INTO post_slugs (slug, post_id, created_at) VALUES ($1, $2, $3) ON CONFLICT (slug) DO NOTHING;")
+	if err != nil {
+		return nil, err
+	}
+
+	client.setStatusStmt, err = prepare(db, "posts.set_status", "UPDATE posts SET status = $2, published_at = $3, updated_at = $4 WHERE id = $1;")
+	if err != nil {
+		return nil, err
+	}
+
+	// SKIP LOCKED lets replicas publish due posts at the same time, each
+	// taking the posts no other replica holds
+	client.lockDueStmt, err = prepare(db, "posts.lock_due", `SELECT id FROM posts
+WHERE status = 'scheduled' AND published_at <= $1 AND deleted_at IS NULL
+ORDER BY published_at
+LIMIT $2
+FOR UPDATE SKIP LOCKED;`)
+	if err != nil {
+		return nil, err
+	}
+
+	client.lockDeletedPostStmt, err = prepare(db, "posts.lock_deleted", "SELECT "+postColumns+" FROM posts WHERE id = $1 AND deleted_at IS NOT NULL FOR UPDATE;")
+	if err != nil {
+		return nil, err
+	}
+
+	client.restorePostStmt, err = prepare(db, "posts.restore", `UPDATE posts SET deleted_at = NULL
+WHERE id = $1 AND NOT EXISTS (SELECT 1 FROM users WHERE users.id = posts.user_id AND users.deleted_at IS NOT NULL);`)
+	if err != nil {
+		return nil, err
+	}
+
+	client.purgePostsStmt, err = prepare(db, "posts.purge", `DELETE FROM posts WHERE id IN (
+    SELECT id FROM posts WHERE deleted_at < $1 ORDER BY deleted_at LIMIT $2 FOR UPDATE SKIP LOCKED
+) RETURNING id;`)
+	if err != nil {
+		return nil, err
+	}
+
+	// revisions are only written with the post created or locked in the same
+	// transaction, so numbering them from the current maximum can not race
+	client.insertRevisionStmt, err = prepare(db, "post_revisions.insert", `INSERT INTO post_revisions (`+postRevisionColumns+`)
+SELECT $1, COALESCE(max(revision), 0) + 1, $2, $3, $4, $5 FROM post_revisions WHERE post_id = $1
+RETURNING revision;`)
+	if err != nil {
+		return nil, err
+	}
+
+	client.activeUserStmt, err = prepare(db, "users.active", "SELECT EXISTS (SELECT 1 FROM users WHERE id = $1 AND deleted_at IS NULL);")
+	if err != nil {
+		return nil, err
+	}
+
+	client.pruneRevisionsStmt, err = prepare(db, "post_revisions.prune", "DELETE FROM post_revisions WHERE post_id = $1 AND revision <= $2;")
+	if err != nil {
+		return nil, err
+	}
+
+	client.listRevisionsStmt, err = prepare(db, "post_revisions.list", "SELECT "+postRevisionColumns+" FROM post_revisions WHERE post_id = $1 ORDER BY revision DESC LIMIT 1000;")
+	if err != nil {
+		return nil, err
+	}
+
+	client.getRevisionStmt, err = prepare(db, "post_revisions.get", "SELECT "+postRevisionColumns+" FROM post_revisions WHERE post_id = $1 AND revision = $2;")
+	if err != nil {
+		return nil, err
+	}
+
+	client.insertAuditEventStmt, err = prepareInsertAuditEvent(db)
+	if err != nil {
+		return nil, err
+	}
+
+	return client, nil
+}
+
+// Close releases the client's prepared statements. The client must not be
+// used afterwards.
+func (client *PostgresPostClient) Close() error {
+	return closeStatements(
+		client.listPostsStmt,
+		client.createPostStmt,
+		client.getPostStmt,
+		client.lockPostStmt,
+		client.updatePostStmt,
+		client.deletePostStmt,
+		client.ensureTagsStmt,
+		client.clearPostTagsStmt,
+		client.insertPostTagsStmt,
+		client.listTagsStmt,
+		client.viewerReactionsStmt,
+		client.listFeedStmt,
+		client.searchPostsStmt,
+		client.getPostBySlugStmt,
+		client.lockSlugStmt,
+		client.takenSlugsStmt,
+		client.insertSlugStmt,
+		client.setStatusStmt,
+		client.lockDueStmt,
+		client.lockDeletedPostStmt,
+		client.restorePostStmt,
+		client.purgePostsStmt,
+		client.insertRevisionStmt,
+		client.pruneRevisionsStmt,
+		client.listRevisionsStmt,
+		client.getRevisionStmt,
+		client.insertAuditEventStmt,
+		client.activeUserStmt,
+	)
+}
+
+func (client *PostgresPostClient) ListPosts(ctx context.Context, filter store.PostFilter) ([]*model.Post, error) {
+	tags := filter.Tags
+	if tags == nil {
+		// a nil array is sent as NULL, which would match no posts
+		tags = []string{}
+	}
+
+	statuses := filter.Statuses
+	if len(statuses) == 0 {
+		statuses = []string{store.POST_STATUS_PUBLISHED}
+	}
+
+	rows, err := client.listPostsStmt.query(ctx, nil, pq.Array(tags), filter.MatchAllTags, pq.Array(statuses), filter.IncludeDeleted)
+	if err != nil {
+		logging.FromContext(ctx, client.logger).Error("unable to list all posts", zap.Error(err))
+		return nil, err
+	}
+
+	defer rows.Close()
+
+	posts := make([]*model.Post, 0)
+
+	for rows.Next() {
+		post, err := client.scanPost(rows)
+		if err != nil {
+			logging.FromContext(ctx, client.logger).Error("unable to scan post, skipping for now", zap.Error(err))
+			continue
+		}
+
+		posts = append(posts, post)
+	}
+
+	if err = client.attachViewerReactions(ctx, posts); err != nil {
+		return nil, err
+	}
+
+	return posts, nil
+}
+
+func (client *PostgresPostClient) CreatePost(ctx context.Context, post *model.Post) (*model.Post, error) {
+	var active bool
+
+	if err := client.activeUserStmt.queryRow(ctx, nil, post.CreatedByUser).Scan(&active); err != nil {
+		return nil, fmt.Errorf("unable to check user [%d]: %s", post.CreatedByUser, err.Error())
+	}
+
+	if !active {
+		return nil, store.ErrUnknownUser
+	}
+
+	tx, err := client.db.BeginTx(ctx, nil)
+	if err != nil {
+		return nil, fmt.Errorf("unable to begin create post transaction: %s", err.Error())
+	}
+
+	defer tx.Rollback()
+
+	var (
+		now         = time.Now()
+		status      = post.Status
+		publishedAt sql.NullTime
+	)
+
+	switch status {
+	case "", store.POST_STATUS_PUBLISHED:
+		status = store.POST_STATUS_PUBLISHED
+		publishedAt = sql.NullTime{Time: now, Valid: true}
+	case store.POST_STATUS_SCHEDULED:
+		if post.PublishedTime == nil {
+			return nil, fmt.Errorf("scheduled post is missing its publish time")
+		}
+
+		publishedAt = sql.NullTime{Time: *post.PublishedTime, Valid: true}
+	}
+
+	slug, err := client.pickSlug(ctx, tx, 0, post.Title)
+	if err != nil {
+		return nil, err
+	}
+
+	row := client.createPostStmt.queryRow(ctx, tx, post.CreatedByUser, post.Title, post.Content, now, status, publishedAt, slug)
+
+	var postID int64
+
+	err = row.Scan(&postID)
+
+	// the user may have been purged since it was checked
+	var pqErr *pq.Error
+	if errors.As(err, &pqErr) && pqErr.Code == foreignKeyViolation && pqErr.Constraint == postUserConstraint {
+		return nil, store.ErrUnknownUser
+	} else if err != nil {
+		return nil, fmt.Errorf("unable to scan created post id: %s", err.Error())
+	}
+
+	if _, err = client.insertSlugStmt.exec(ctx, tx, slug, postID, now); err != nil {
+		return nil, fmt.Errorf("unable to record slug of post [%d]: %s", postID, err.Error())
+	}
+
+	if err = client.setTags(ctx, tx, int(postID), post.Tags); err != nil {
+		return nil, err
+	}
+
+	if err = client.writeRevision(ctx, tx, int(postID), post.Title, post.Content, now); err != nil {
+		return nil, err
+	}
+
+	createdPost, err := client.scanPost(client.getPostStmt.queryRow(ctx, tx, postID))
+	if err != nil {
+		return nil, fmt.Errorf("unable to get created post: %s", err.Error())
+	}
+
+	if err = recordAuditEvent(ctx, tx, client.insertAuditEventStmt, store.AuditEntityPost, createdPost.ID, store.AuditActionCreate, nil, createdPost); err != nil {
+		return nil, err
+	}
+
+	if err = tx.Commit(); err != nil {
+		return nil, fmt.Errorf("unable to commit created post: %s", err.Error())
+	}
+
+	return createdPost, nil
+}
+
+func (client *PostgresPostClient) GetPost(ctx context.Context, id int) (*model.Post, error) {
+	post, err := client.scanPost(client.getPostStmt.queryRow(ctx, nil, id))
+	if err != nil && err == sql.ErrNoRows {
+		return nil, err
+	} else if err != nil {
+		return nil, fmt.Errorf("unable to scan post [%d]: %s", id, err.Error())
+	}
+
+	if err = client.attachViewerReactions(ctx, []*model.Post{post}); err != nil {
+		return nil, err
+	}
+
+	return post, nil
+}
+
+func (client *PostgresPostClient) GetPostBySlug(ctx context.Context, slug string) (*model.Post, error) {
+	post, err := client.scanPost(client.getPostBySlugStmt.queryRow(ctx, nil, slug))
+	if err != nil && err == sql.ErrNoRows {
+		return nil, err
+	} else if err != nil {
+		return nil, fmt.Errorf("unable to scan post by slug %q: %s", slug, err.Error())
+	}
+
+	if err = client.attachViewerReactions(ctx, []*model.Post{post}); err != nil {
+		return nil, err
+	}
+
+	return post, nil
+}
+
+func (client *PostgresPostClient) UpdatePost(ctx context.Context, postInput *model.Post) (*model.Post, error) {
+	tx, err := client.db.BeginTx(ctx, nil)
+	if err != nil {
+		return nil, fmt.Errorf("unable to begin update post transaction: %s", err.Error())
+	}
+
+	defer tx.Rollback()
+
+	before, err := client.scanPost(client.lockPostStmt.queryRow(ctx, tx, postInput.ID))
+	if err != nil && err == sql.ErrNoRows {
+		return nil, err
+	} else if err != nil {
+		return nil, fmt.Errorf("unable to lock post [%d]: %s", postInput.ID, err.Error())
+	}
+
+	post, err := client.update(ctx, tx, before, postInput.Title, postInput.Content, postInput.Tags)
+	if err != nil {
+		return nil, err
+	}
+
+	if err = tx.Commit(); err != nil {
+		return nil, fmt.Errorf("unable to commit updated post [%d]: %s", post.ID, err.Error())
+	}
+
+	return post, nil
+}
+
+// update writes a new title, content and, unless nil, tags to a post locked
+// in tx, along with its next revision and audit event. A title that slugifies
+// differently moves the post to a new slug, keeping the old one as a
+// redirect.
+func (client *PostgresPostClient) update(ctx context.Context, tx *sql.Tx, before *model.Post, title, content string, tags []string) (*model.Post, error) {
+	now := time.Now()
+
+	slug := before.Slug
+
+	if store.Slugify(title) != store.Slugify(before.Title) {
+		var err error
+
+		if slug, err = client.pickSlug(ctx, tx, before.ID, title); err != nil {
+			return nil, err
+		}
+
+		if _, err = client.insertSlugStmt.exec(ctx, tx, slug, before.ID, now); err != nil {
+			return nil, fmt.Errorf("unable to record slug of post [%d]: %s", before.ID, err.Error())
+		}
+	}
+
+	if _, err := client.updatePostStmt.exec(ctx, tx, before.ID, title, content, now, slug); err != nil {
+		return nil, fmt.Errorf("unable to update post [%d]: %s", before.ID, err.Error())
+	}
+
+	if tags != nil {
+		if err := client.setTags(ctx, tx, before.ID, tags); err != nil {
+			return nil, err
+		}
+	}
+
+	if err := client.writeRevision(ctx, tx, before.ID, title, content, now); err != nil {
+		return nil, err
+	}
+
+	post, err := client.scanPost(client.getPostStmt.queryRow(ctx, tx, before.ID))
+	if err != nil {
+		return nil, fmt.Errorf("unable to scan post [%d]: %s", before.ID, err.Error())
+	}
+
+	if err = recordAuditEvent(ctx, tx, client.insertAuditEventStmt, store.AuditEntityPost, post.ID, store.AuditActionUpdate, before, post); err != nil {
+		return nil, err
+	}
+
+	return post, nil
+}
+
+// pickSlug returns the slug for a post titled title: the slugified title, or
+// the first of it suffixed with -2, -3 and so on that no other post has had.
+// A slug the post itself had before is taken back. postID is 0 for a post
+// being created.
+func (client *PostgresPostClient) pickSlug(ctx context.Context, tx *sql.Tx, postID int, title string) (string, error) {
+	base := store.Slugify(title)
+
+	if _, err := client.lockSlugStmt.exec(ctx, tx, store.SlugRoot(base)); err != nil {
+		return "", fmt.Errorf("unable to lock slug %q: %s", base, err.Error())
+	}
+
+	rows, err := client.takenSlugsStmt.query(ctx, tx, base, base+"-%")
+	if err != nil {
+		return "", fmt.Errorf("unable to list slugs like %q: %s", base, err.Error())
+	}
+
+	defer rows.Close()
+
+	owners := make(map[string]int)
+
+	for rows.Next() {
+		var (
+			slug  string
+			owner int
+		)
+
+		if err := rows.Scan(&slug, &owner); err != nil {
+			return "", fmt.Errorf("unable to scan slug like %q: %s", base, err.Error())
+		}
+
+		owners[slug] = owner
+	}
+
+	if err = rows.Err(); err != nil {
+		return "", fmt.Errorf("unable to iterate slugs like %q: %s", base, err.Error())
+	}
+
+	return freeSlug(base, owners, postID), nil
+}
+
+// freeSlug returns base, or base suffixed with the lowest number from 2 up,
+// that owners does not give to a post other than postID.
+func freeSlug(base string, owners map[string]int, postID int) string {
+	for suffix := 1; ; suffix++ {
+		slug := base
+		if suffix > 1 {
+			slug = fmt.Sprintf("%s-%d", base, suffix)
+		}
+
+		if owner, taken := owners[slug]; !taken || owner == postID {
+			return slug
+		}
+	}
+}
+
+// writeRevision records the next revision of a post and drops the revisions
+// that fall out of retention.
+func (client *PostgresPostClient) writeRevision(ctx context.Context, tx *sql.Tx, postID int, title, content string, now time.Time) error {
+	var revision int
+
+	if err := client.insertRevisionStmt.queryRow(ctx, tx, postID, title, content, store.ActorFromContext(ctx), now).Scan(&revision); err != nil {
+		return fmt.Errorf("unable to write revision of post [%d]: %s", postID, err.Error())
+	}
+
+	if client.revisionsKept > 0 && revision > client.revisionsKept {
+		if _, err := client.pruneRevisionsStmt.exec(ctx, tx, postID, revision-client.revisionsKept); err != nil {
+			return fmt.Errorf("unable to prune revisions of post [%d]: %s", postID, err.Error())
+		}
+	}
+
+	return nil
+}
+
+func (client *PostgresPostClient) ListPostRevisions(ctx context.Context, postID int) ([]*model.PostRevision, error) {
+	rows, err := client.listRevisionsStmt.query(ctx, nil, postID)
+	if err != nil {
+		return nil, fmt.Errorf("unable to list revisions of post [%d]: %s", postID, err.Error())
+	}
+
+	defer rows.Close()
+
+	revisions := make([]*model.PostRevision, 0)
+
+	for rows.Next() {
+		revision, err := scanPostRevision(rows)
+		if err != nil {
+			return nil, fmt.Errorf("unable to scan revision of post [%d]: %s", postID, err.Error())
+		}
+
+		revisions = append(revisions, revision)
+	}
+
+	if err = rows.Err(); err != nil {
+		return nil, fmt.Errorf("unable to iterate revisions of post [%d]: %s", postID, err.Error())
+	}
 
-	logger *zap.Logger
+	return revisions, nil
 }
 
-func NewPostgresPostClient(db *sql.DB, logger *zap.Logger) (*PostgresPostClient, error) {
-	client := &PostgresPostClient{
-		logger: logger,
+func (client *PostgresPostClient) GetPostRevision(ctx context.Context, postID, revision int) (*model.PostRevision, error) {
+	postRevision, err := scanPostRevision(client.getRevisionStmt.queryRow(ctx, nil, postID, revision))
+	if err != nil && err == sql.ErrNoRows {
+		return nil, err
+	} else if err != nil {
+		return nil, fmt.Errorf("unable to scan revision [%d] of post [%d]: %s", revision, postID, err.Error())
 	}
 
-	var err error
+	return postRevision, nil
+}
 
-	client.listPostsStmt, err = db.Prepare("SELECT id, user_id, title, content, created_at, updated_at FROM posts LIMIT 100;")
+// RestorePostRevision writes the title and content of an earlier revision
+// back to the post as its newest revision.
+func (client *PostgresPostClient) RestorePostRevision(ctx context.Context, postID, revision int) (*model.Post, error) {
+	tx, err := client.db.BeginTx(ctx, nil)
 	if err != nil {
-		return nil, fmt.Errorf("unable to prepare list posts statement: %s", err.Error())
+		return nil, fmt.Errorf("unable to begin restore post transaction: %s", err.Error())
+	}
+
+	defer tx.Rollback()
+
+	before, err := client.scanPost(client.lockPostStmt.queryRow(ctx, tx, postID))
+	if err != nil && err == sql.ErrNoRows {
+		return nil, err
+	} else if err != nil {
+		return nil, fmt.Errorf("unable to lock post [%d]: %s", postID, err.Error())
+	}
+
+	restored, err := scanPostRevision(client.getRevisionStmt.queryRow(ctx, tx, postID, revision))
+	if err != nil && err == sql.ErrNoRows {
+		return nil, err
+	} else if err != nil {
+		return nil, fmt.Errorf("unable to scan revision [%d] of post [%d]: %s", revision, postID, err.Error())
 	}
 
-	client.createPostStmt, err = db.Prepare("INSERT INTO posts (user_id, title, content, created_at) VALUES ($1, $2, $3, $4) RETURNING id;")
+	post, err := client.update(ctx, tx, before, restored.Title, restored.Content, nil)
 	if err != nil {
-		return nil, fmt.Errorf("unable to prepare create post statement: %s", err.Error())
+		return nil, err
+	}
+
+	if err = tx.Commit(); err != nil {
+		return nil, fmt.Errorf("unable to commit restored post [%d]: %s", postID, err.Error())
 	}
 
-	client.getPostStmt, err = db.Prepare("SELECT id, user_id, title, content, created_at, updated_at FROM posts WHERE id = $1;")
+	return post, nil
+}
+
+func (client *PostgresPostClient) RestorePost(ctx context.Context, id int) (*model.Post, error) {
+	tx, err := client.db.BeginTx(ctx, nil)
 	if err != nil {
-		return nil, fmt.Errorf("unable to prepare get post statement: %s", err.Error())
+		return nil, fmt.Errorf("unable to begin restore post transaction: %s", err.Error())
+	}
+
+	defer tx.Rollback()
+
+	before, err := client.scanPost(client.lockDeletedPostStmt.queryRow(ctx, tx, id))
+	if err != nil && err == sql.ErrNoRows {
+		return nil, err
+	} else if err != nil {
+		return nil, fmt.Errorf("unable to lock deleted post [%d]: %s", id, err.Error())
 	}
 
-	client.updatePostStmt, err = db.Prepare("UPDATE posts SET title = $2, content = $3, updated_at = $4 WHERE id = $1 RETURNING id, user_id, title, content, created_at, updated_at;")
+	result, err := client.restorePostStmt.exec(ctx, tx, id)
 	if err != nil {
-		return nil, fmt.Errorf("unable to prepare update post statement: %s", err.Error())
+		return nil, fmt.Errorf("unable to restore post [%d]: %s", id, err.Error())
 	}
 
-	client.deletePostStmt, err = db.Prepare("DELETE FROM posts WHERE id = $1;")
+	rowsAffected, err := result.RowsAffected()
 	if err != nil {
-		return nil, fmt.Errorf("unable to prepare update post statement: %s", err.Error())
+		return nil, fmt.Errorf("error getting rows affected for post [%d]: %s", id, err.Error())
 	}
 
-	return client, nil
+	if rowsAffected == 0 {
+		return nil, store.ErrPostOwnerDeleted
+	}
+
+	post, err := client.scanPost(client.getPostStmt.queryRow(ctx, tx, id))
+	if err != nil {
+		return nil, fmt.Errorf("unable to scan post [%d]: %s", id, err.Error())
+	}
+
+	if err = recordAuditEvent(ctx, tx, client.insertAuditEventStmt, store.AuditEntityPost, id, store.AuditActionRestore, before, post); err != nil {
+		return nil, err
+	}
+
+	if err = tx.Commit(); err != nil {
+		return nil, fmt.Errorf("unable to commit restored post [%d]: %s", id, err.Error())
+	}
+
+	return post, nil
 }
 
-func (client *PostgresPostClient) ListPosts() ([]*model.Post, error) {
-	rows, err := client.listPostsStmt.Query()
+func (client *PostgresPostClient) PurgeDeletedPosts(ctx context.Context, cutoff time.Time, limit int) (int, error) {
+	tx, err := client.db.BeginTx(ctx, nil)
+	if err != nil {
+		return 0, fmt.Errorf("unable to begin purge posts transaction: %s", err.Error())
+	}
+
+	defer tx.Rollback()
+
+	ids, err := purge(ctx, tx, client.purgePostsStmt, cutoff, limit)
 	if err != nil {
-		client.logger.Error("unable to list all posts", zap.Error(err))
+		return 0, fmt.Errorf("unable to purge deleted posts: %s", err.Error())
+	}
+
+	for _, id := range ids {
+		if err = recordAuditEvent(ctx, tx, client.insertAuditEventStmt, store.AuditEntityPost, id, store.AuditActionPurge, nil, nil); err != nil {
+			return 0, err
+		}
+	}
+
+	if err = tx.Commit(); err != nil {
+		return 0, fmt.Errorf("unable to commit purged posts: %s", err.Error())
+	}
+
+	return len(ids), nil
+}
+
+func scanPostRevision(row rowScanner) (*model.PostRevision, error) {
+	revision := &model.PostRevision{}
+
+	if err := row.Scan(
+		&revision.PostID,
+		&revision.Revision,
+		&revision.Title,
+		&revision.Content,
+		&revision.Actor,
+		&revision.CreatedTime,
+	); err != nil {
 		return nil, err
 	}
 
-	defer rows.Close()
+	return revision, nil
+}
 
-	posts := make([]*model.Post, 0)
+func (client *PostgresPostClient) DeletePost(ctx context.Context, id int) error {
+	tx, err := client.db.BeginTx(ctx, nil)
+	if err != nil {
+		return fmt.Errorf("unable to begin delete post transaction: %s", err.Error())
+	}
+
+	defer tx.Rollback()
+
+	before, err := client.scanPost(client.lockPostStmt.queryRow(ctx, tx, id))
+	if err != nil && err == sql.ErrNoRows {
+		return err
+	} else if err != nil {
+		return fmt.Errorf("unable to lock post [%d]: %s", id, err.Error())
+	}
+
+	result, err := client.deletePostStmt.exec(ctx, tx, id, time.Now())
+	if err != nil {
+		return fmt.Errorf("unable to delete post [%d]: %s", id, err.Error())
+	}
+
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		return fmt.Errorf("error getting rows affected for post [%d]: %s", id, err.Error())
+	}
+
+	if rowsAffected != int64(1) {
+		return fmt.Errorf("deleted 0 or more than one post requested")
+	}
+
+	if err = recordAuditEvent(ctx, tx, client.insertAuditEventStmt, store.AuditEntityPost, id, store.AuditActionDelete, before, nil); err != nil {
+		return err
+	}
+
+	if err = tx.Commit(); err != nil {
+		return fmt.Errorf("unable to commit deleted post [%d]: %s", id, err.Error())
+	}
+
+	return nil
+}
+
+func (client *PostgresPostClient) PublishPost(ctx context.Context, id int, publishAt time.Time) (*model.Post, error) {
+	return client.changeStatus(ctx, id, func(post *model.Post, now time.Time) (string, *time.Time) {
+		if publishAt.After(now) {
+			return store.POST_STATUS_SCHEDULED, &publishAt
+		}
+
+		// an archived post comes back with its original publish time
+		if post.Status == store.POST_STATUS_ARCHIVED && post.PublishedTime != nil {
+			return store.POST_STATUS_PUBLISHED, post.PublishedTime
+		}
+
+		return store.POST_STATUS_PUBLISHED, &now
+	})
+}
+
+func (client *PostgresPostClient) ArchivePost(ctx context.Context, id int) (*model.Post, error) {
+	return client.changeStatus(ctx, id, func(post *model.Post, now time.Time) (string, *time.Time) {
+		return store.POST_STATUS_ARCHIVED, post.PublishedTime
+	})
+}
+
+// changeStatus moves the locked post to the status, and publish time, chosen
+// by next, if its lifecycle allows it.
+func (client *PostgresPostClient) changeStatus(ctx context.Context, id int, next func(post *model.Post, now time.Time) (string, *time.Time)) (*model.Post, error) {
+	tx, err := client.db.BeginTx(ctx, nil)
+	if err != nil {
+		return nil, fmt.Errorf("unable to begin post status transaction: %s", err.Error())
+	}
+
+	defer tx.Rollback()
+
+	before, err := client.scanPost(client.lockPostStmt.queryRow(ctx, tx, id))
+	if err != nil && err == sql.ErrNoRows {
+		return nil, err
+	} else if err != nil {
+		return nil, fmt.Errorf("unable to lock post [%d]: %s", id, err.Error())
+	}
+
+	now := time.Now()
+
+	status, publishedAt := next(before, now)
+	if !store.CanTransitionPost(before.Status, status) {
+		return nil, store.ErrInvalidPostTransition
+	}
+
+	post, err := client.setStatus(ctx, tx, before, status, publishedAt, now)
+	if err != nil {
+		return nil, err
+	}
+
+	if err = tx.Commit(); err != nil {
+		return nil, fmt.Errorf("unable to commit post [%d] status: %s", id, err.Error())
+	}
+
+	return post, nil
+}
+
+func (client *PostgresPostClient) PublishScheduledPosts(ctx context.Context, now time.Time, limit int) (int, error) {
+	tx, err := client.db.BeginTx(ctx, nil)
+	if err != nil {
+		return 0, fmt.Errorf("unable to begin publish scheduled posts transaction: %s", err.Error())
+	}
+
+	defer tx.Rollback()
+
+	rows, err := client.lockDueStmt.query(ctx, tx, now, limit)
+	if err != nil {
+		return 0, fmt.Errorf("unable to lock due scheduled posts: %s", err.Error())
+	}
+
+	ids := make([]int, 0, limit)
 
 	for rows.Next() {
-		var (
-			post        = &model.Post{}
-			timeUpdated sql.NullString
-		)
+		var id int
+		if err := rows.Scan(&id); err != nil {
+			rows.Close()
+			return 0, fmt.Errorf("unable to scan due scheduled post: %s", err.Error())
+		}
 
-		if err := rows.Scan(
-			&post.ID,
-			&post.CreatedByUser,
-			&post.Title,
-			&post.Content,
-			&post.CreatedTime,
-			&timeUpdated,
-		); err != nil {
-			client.logger.Error("unable to scan post, skipping for now", zap.Error(err))
-			continue
+		ids = append(ids, id)
+	}
+
+	rows.Close()
+
+	if err = rows.Err(); err != nil {
+		return 0, fmt.Errorf("unable to iterate due scheduled posts: %s", err.Error())
+	}
+
+	for _, id := range ids {
+		before, err := client.scanPost(client.getPostStmt.queryRow(ctx, tx, id))
+		if err != nil {
+			return 0, fmt.Errorf("unable to get scheduled post [%d]: %s", id, err.Error())
 		}
 
-		if timeUpdated.Valid {
-			updatedAt, err := time.Parse(time.RFC3339, timeUpdated.String)
-			if err != nil {
-				client.logger.Warn("unable to parse updated_at value", zap.Int("post_id", post.ID), zap.Error(err))
-			} else {
-				post.UpdatedTime = updatedAt
-			}
+		if _, err = client.setStatus(ctx, tx, before, store.POST_STATUS_PUBLISHED, before.PublishedTime, now); err != nil {
+			return 0, err
 		}
+	}
 
-		posts = append(posts, post)
+	if err = tx.Commit(); err != nil {
+		return 0, fmt.Errorf("unable to commit published scheduled posts: %s", err.Error())
 	}
 
-	return posts, nil
+	return len(ids), nil
 }
 
-func (client *PostgresPostClient) CreatePost(post *model.Post) (*model.Post, error) {
-	row := client.createPostStmt.QueryRow(post.CreatedByUser, post.Title, post.Content, time.Now())
+// setStatus writes the status of a post locked in tx and audits the change.
+func (client *PostgresPostClient) setStatus(ctx context.Context, tx *sql.Tx, before *model.Post, status string, publishedAt *time.Time, now time.Time) (*model.Post, error) {
+	if _, err := client.setStatusStmt.exec(ctx, tx, before.ID, status, publishedAt, now); err != nil {
+		return nil, fmt.Errorf("unable to set status of post [%d]: %s", before.ID, err.Error())
+	}
+
+	post, err := client.scanPost(client.getPostStmt.queryRow(ctx, tx, before.ID))
+	if err != nil {
+		return nil, fmt.Errorf("unable to scan post [%d]: %s", before.ID, err.Error())
+	}
 
-	var postID int64
+	if err = recordAuditEvent(ctx, tx, client.insertAuditEventStmt, store.AuditEntityPost, post.ID, store.AuditActionUpdate, before, post); err != nil {
+		return nil, err
+	}
+
+	return post, nil
+}
 
-	err := row.Scan(&postID)
+func (client *PostgresPostClient) ListTags(ctx context.Context) ([]*model.Tag, error) {
+	rows, err := client.listTagsStmt.query(ctx, nil)
 	if err != nil {
-		return nil, fmt.Errorf("unable to scan created post id: %s", err.Error())
+		return nil, fmt.Errorf("unable to list tags: %s", err.Error())
+	}
+
+	defer rows.Close()
+
+	tags := make([]*model.Tag, 0)
+
+	for rows.Next() {
+		tag := &model.Tag{}
+
+		if err := rows.Scan(&tag.Name, &tag.PostCount); err != nil {
+			return nil, fmt.Errorf("unable to scan tag: %s", err.Error())
+		}
+
+		tags = append(tags, tag)
+	}
+
+	if err = rows.Err(); err != nil {
+		return nil, fmt.Errorf("unable to iterate tags: %s", err.Error())
 	}
 
-	createdPost, err := client.GetPost(int(postID))
+	return tags, nil
+}
+
+// setTags replaces the post's tags within the transaction writing the post.
+func (client *PostgresPostClient) setTags(ctx context.Context, tx *sql.Tx, postID int, tags []string) error {
+	tags, err := store.NormalizeTags(tags)
 	if err != nil {
-		return nil, fmt.Errorf("unable to get created post: %s", err.Error())
+		return err
 	}
 
-	return createdPost, nil
+	if _, err = client.clearPostTagsStmt.exec(ctx, tx, postID); err != nil {
+		return fmt.Errorf("unable to clear tags of post [%d]: %s", postID, err.Error())
+	}
+
+	if len(tags) == 0 {
+		return nil
+	}
+
+	if _, err = client.ensureTagsStmt.exec(ctx, tx, pq.Array(tags)); err != nil {
+		return fmt.Errorf("unable to create tags for post [%d]: %s", postID, err.Error())
+	}
+
+	if _, err = client.insertPostTagsStmt.exec(ctx, tx, postID, pq.Array(tags)); err != nil {
+		return fmt.Errorf("unable to tag post [%d]: %s", postID, err.Error())
+	}
+
+	return nil
 }
 
-func (client *PostgresPostClient) GetPost(id int) (*model.Post, error) {
+func (client *PostgresPostClient) scanPost(row rowScanner) (*model.Post, error) {
 	var (
 		post        = &model.Post{}
 		timeUpdated sql.NullString
-		err         error
+		publishedAt sql.NullTime
+		deletedAt   sql.NullTime
+		reactions   []byte
 	)
 
-	row := client.getPostStmt.QueryRow(id)
-
-	if err = row.Scan(
+	if err := row.Scan(
 		&post.ID,
 		&post.CreatedByUser,
 		&post.Title,
+		&post.Slug,
 		&post.Content,
+		&post.Status,
+		&publishedAt,
 		&post.CreatedTime,
 		&timeUpdated,
-	); err != nil && err == sql.ErrNoRows {
+		&deletedAt,
+		pq.Array(&post.Tags),
+		&reactions,
+		&post.Revision,
+	); err != nil {
 		return nil, err
-	} else if err != nil {
-		return nil, fmt.Errorf("unable to scan post [%d]: %s", id, err.Error())
+	}
+
+	if err := json.Unmarshal(reactions, &post.Reactions); err != nil {
+		return nil, fmt.Errorf("unable to parse reaction counts: %s", err.Error())
+	}
+
+	if publishedAt.Valid {
+		post.PublishedTime = &publishedAt.Time
+	}
+
+	if deletedAt.Valid {
+		post.DeletedTime = &deletedAt.Time
 	}
 
 	if timeUpdated.Valid {
@@ -153,51 +1052,137 @@ func (client *PostgresPostClient) GetPost(id int) (*model.Post, error) {
 	return post, nil
 }
 
-func (client *PostgresPostClient) UpdatePost(postInput *model.Post) (*model.Post, error) {
-	row := client.updatePostStmt.QueryRow(postInput.ID, postInput.Title, postInput.Content, time.Now())
+func (client *PostgresPostClient) ListFeed(ctx context.Context, userID int, page store.Page) ([]*model.Post, error) {
+	cursorTime, cursorID, limit := pageArgs(page)
+
+	rows, err := client.listFeedStmt.query(ctx, nil, userID, cursorTime, cursorID, limit)
+	if err != nil {
+		return nil, fmt.Errorf("unable to list feed of user [%d]: %s", userID, err.Error())
+	}
+
+	defer rows.Close()
+
+	posts := make([]*model.Post, 0, limit)
+
+	for rows.Next() {
+		post, err := client.scanPost(rows)
+		if err != nil {
+			return nil, fmt.Errorf("unable to scan feed post: %s", err.Error())
+		}
+
+		posts = append(posts, post)
+	}
+
+	if err = rows.Err(); err != nil {
+		return nil, fmt.Errorf("unable to iterate feed of user [%d]: %s", userID, err.Error())
+	}
+
+	if err = client.attachViewerReactions(ctx, posts); err != nil {
+		return nil, err
+	}
+
+	return posts, nil
+}
+
+func (client *PostgresPostClient) SearchPosts(ctx context.Context, query string, page store.SearchPage) ([]*model.PostSearchResult, error) {
+	_, _, limit := pageArgs(store.Page{Limit: page.Limit})
 
 	var (
-		post        = &model.Post{}
-		timeUpdated sql.NullString
-		err         error
+		cursorRank sql.NullFloat64
+		cursorID   int
 	)
 
-	if err = row.Scan(
-		&post.ID,
-		&post.CreatedByUser,
-		&post.Title,
-		&post.Content,
-		&post.CreatedTime,
-		&timeUpdated,
-	); err != nil {
-		return nil, fmt.Errorf("unable to scan post [%d]: %s", postInput.ID, err.Error())
+	// the first page has no cursor, a NULL rank places no bound on it
+	if page.After != nil {
+		cursorRank = sql.NullFloat64{Float64: float64(page.After.Rank), Valid: true}
+		cursorID = page.After.ID
 	}
 
-	if timeUpdated.Valid {
-		updatedAt, err := time.Parse(time.RFC3339, timeUpdated.String)
+	rows, err := client.searchPostsStmt.query(ctx, nil, query, cursorRank, cursorID, limit)
+	if err != nil {
+		return nil, fmt.Errorf("unable to search posts: %s", err.Error())
+	}
+
+	defer rows.Close()
+
+	var (
+		results = make([]*model.PostSearchResult, 0, limit)
+		posts   = make([]*model.Post, 0, limit)
+	)
+
+	for rows.Next() {
+		result := &model.PostSearchResult{}
+
+		result.Post, err = client.scanPost(prefixedScanner{row: rows, prefix: []interface{}{&result.Rank, &result.Snippet}})
 		if err != nil {
-			client.logger.Warn("unable to parse time_update value", zap.Int("post_id", post.ID), zap.Error(err))
-		} else {
-			post.UpdatedTime = updatedAt
+			return nil, fmt.Errorf("unable to scan search result: %s", err.Error())
 		}
+
+		result.Snippet = snippetHTML(result.Snippet)
+
+		results = append(results, result)
+		posts = append(posts, result.Post)
 	}
-	return post, nil
+
+	if err = rows.Err(); err != nil {
+		return nil, fmt.Errorf("unable to iterate search results: %s", err.Error())
+	}
+
+	if err = client.attachViewerReactions(ctx, posts); err != nil {
+		return nil, err
+	}
+
+	return results, nil
 }
 
-func (client *PostgresPostClient) DeletePost(id int) error {
-	result, err := client.deletePostStmt.Exec(id)
-	if err != nil {
-		return fmt.Errorf("unable to delete post [%d]: %s", id, err.Error())
+// attachViewerReactions sets the reactions of the viewer in the context on
+// each of the posts, with a single query for all of them.
+func (client *PostgresPostClient) attachViewerReactions(ctx context.Context, posts []*model.Post) error {
+	viewer := store.ViewerFromContext(ctx)
+	if viewer == 0 || len(posts) == 0 {
+		return nil
 	}
 
-	rowsAffected, err := result.RowsAffected()
+	byID := make(map[int]*model.Post, len(posts))
+	ids := make([]int64, 0, len(posts))
+
+	for _, post := range posts {
+		byID[post.ID] = post
+		ids = append(ids, int64(post.ID))
+	}
+
+	rows, err := client.viewerReactionsStmt.query(ctx, nil, viewer, pq.Array(ids))
 	if err != nil {
-		return fmt.Errorf("error getting rows affected for post [%d]: %s", id, err.Error())
+		return fmt.Errorf("unable to list reactions of user [%d]: %s", viewer, err.Error())
 	}
 
-	if rowsAffected != int64(1) {
-		return fmt.Errorf("deleted 0 or more than one post requested")
+	defer rows.Close()
+
+	for rows.Next() {
+		var (
+			postID int
+			kind   string
+		)
+
+		if err := rows.Scan(&postID, &kind); err != nil {
+			return fmt.Errorf("unable to scan reaction of user [%d]: %s", viewer, err.Error())
+		}
+
+		if post, ok := byID[postID]; ok {
+			post.ViewerReactions = append(post.ViewerReactions, kind)
+		}
+	}
+
+	if err = rows.Err(); err != nil {
+		return fmt.Errorf("unable to iterate reactions of user [%d]: %s", viewer, err.Error())
 	}
 
 	return nil
 }
+
+// snippetHTML escapes a search result headline and turns its match markers
+// into mark tags, so the snippet is safe to render as HTML whatever the post
+// content holds.
+func snippetHTML(headline string) string {
+	return searchMatchMarkers.Replace(html.EscapeString(headline))
+}
@@ -0,0 +1,133 @@
+package postgres
+
+import (
+	"context"
+	"database/sql"
+	"database/sql/driver"
+	"errors"
+	"strings"
+	"testing"
+	"time"
+
+	"go.uber.org/zap"
+	"redcellpartners.com/users-posts-api/model"
+	"redcellpartners.com/users-posts-api/store"
+)
+
+func TestSnippetHTML(t *testing.T) {
+	tests := []struct {
+		name     string
+		headline string
+		expected string
+	}{
+		{name: "matches are marked", headline: "full \x02text\x03 search in \x02postgres\x03", expected: "full <mark>text</mark> search in <mark>postgres</mark>"},
+		{name: "script is escaped", headline: "<script>alert(1)</script> \x02postgres\x03", expected: "&lt;script&gt;alert(1)&lt;/script&gt; <mark>postgres</mark>"},
+		{name: "attributes are escaped", headline: "<img src=x onerror=\"alert(1)\"> \x02match\x03", expected: "&lt;img src=x onerror=&#34;alert(1)&#34;&gt; <mark>match</mark>"},
+		{name: "mark tags in the content are escaped", headline: "<mark>fake</mark> \x02real\x03", expected: "&lt;mark&gt;fake&lt;/mark&gt; <mark>real</mark>"},
+		{name: "entities are escaped", headline: "a &amp; b", expected: "a &amp;amp; b"},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			if snippet := snippetHTML(test.headline); snippet != test.expected {
+				t.Errorf("expected %q, got %q", test.expected, snippet)
+			}
+		})
+	}
+}
+
+func TestFreeSlug(t *testing.T) {
+	tests := []struct {
+		name     string
+		owners   map[string]int
+		postID   int
+		expected string
+	}{
+		{name: "free", owners: map[string]int{}, postID: 0, expected: "hello"},
+		{name: "taken", owners: map[string]int{"hello": 1}, postID: 0, expected: "hello-2"},
+		{name: "lowest free suffix", owners: map[string]int{"hello": 1, "hello-2": 2, "hello-4": 4}, postID: 0, expected: "hello-3"},
+		{name: "own slug is kept", owners: map[string]int{"hello": 1}, postID: 1, expected: "hello"},
+		{name: "own old suffixed slug is taken back", owners: map[string]int{"hello": 1, "hello-2": 2}, postID: 2, expected: "hello-2"},
+		{name: "other slugs sharing the prefix don't count", owners: map[string]int{"hello-world": 1}, postID: 0, expected: "hello"},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			if slug := freeSlug("hello", test.owners, test.postID); slug != test.expected {
+				t.Errorf("expected %q, got %q", test.expected, slug)
+			}
+		})
+	}
+}
+
+func TestMissingPostLock(t *testing.T) {
+	// every post lock finds nothing, as when the post is deleted after the
+	// route checked it exists
+	db := sql.OpenDB(fakeConnector{})
+	defer db.Close()
+
+	client, err := NewPostgresPostClient(db, 0, zap.NewNop())
+	if err != nil {
+		t.Fatalf("unable to create post client: %s", err.Error())
+	}
+
+	defer client.Close()
+
+	ctx := context.Background()
+
+	tests := []struct {
+		name   string
+		change func() error
+	}{
+		{name: "publish", change: func() error {
+			_, err := client.PublishPost(ctx, 5, time.Now())
+			return err
+		}},
+		{name: "archive", change: func() error {
+			_, err := client.ArchivePost(ctx, 5)
+			return err
+		}},
+		{name: "update", change: func() error {
+			_, err := client.UpdatePost(ctx, &model.Post{ID: 5, Title: "Hello", Content: "Hello"})
+			return err
+		}},
+		{name: "restore revision", change: func() error {
+			_, err := client.RestorePostRevision(ctx, 5, 1)
+			return err
+		}},
+		{name: "delete", change: func() error {
+			return client.DeletePost(ctx, 5)
+		}},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			if err := test.change(); err != sql.ErrNoRows {
+				t.Errorf("expected sql.ErrNoRows, got %v", err)
+			}
+		})
+	}
+}
+
+func TestCreatePostByDeletedUser(t *testing.T) {
+	// the users.active check finds no live user
+	db := sql.OpenDB(fakeConnector{respond: func(query string, args []driver.Value) *fakeRows {
+		if strings.Contains(query, "FROM users WHERE id = $1 AND deleted_at IS NULL") {
+			return &fakeRows{columns: []string{"exists"}, values: [][]driver.Value{{false}}}
+		}
+
+		return nil
+	}})
+	defer db.Close()
+
+	client, err := NewPostgresPostClient(db, 0, zap.NewNop())
+	if err != nil {
+		t.Fatalf("unable to create post client: %s", err.Error())
+	}
+
+	defer client.Close()
+
+	if _, err = client.CreatePost(context.Background(), &model.Post{CreatedByUser: 5, Title: "Hello", Content: "Hello"}); !errors.Is(err, store.ErrUnknownUser) {
+		t.Errorf("expected store.ErrUnknownUser, got %v", err)
+	}
+}
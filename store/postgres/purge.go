@@ -0,0 +1,32 @@
+package postgres
+
+import (
+	"context"
+	"database/sql"
+	"time"
+)
+
+// purge runs a purge statement, deleting up to limit rows deleted before the
+// cutoff and returning the ids of the rows it deleted. The statement skips
+// rows locked by another replica's purge.
+func purge(ctx context.Context, tx *sql.Tx, stmt *statement, cutoff time.Time, limit int) ([]int, error) {
+	rows, err := stmt.query(ctx, tx, cutoff, limit)
+	if err != nil {
+		return nil, err
+	}
+
+	defer rows.Close()
+
+	ids := make([]int, 0, limit)
+
+	for rows.Next() {
+		var id int
+		if err := rows.Scan(&id); err != nil {
+			return nil, err
+		}
+
+		ids = append(ids, id)
+	}
+
+	return ids, rows.Err()
+}
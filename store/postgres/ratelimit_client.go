@@ -0,0 +1,131 @@
+package postgres
+
+import (
+	"context"
+	"crypto/sha256"
+	"database/sql"
+	"encoding/hex"
+	"fmt"
+	"math"
+	"time"
+
+	"go.uber.org/zap"
+	"redcellpartners.com/users-posts-api/store"
+)
+
+var _ store.RateLimitStore = &PostgresRateLimitClient{}
+
+// PostgresRateLimitClient stores token buckets in postgres so that every
+// replica of the API draws from the same shared counters. Buckets are stored
+// under a hash of their key, keeping client addresses and identities out of
+// the table.
+type PostgresRateLimitClient struct {
+	db *sql.DB
+
+	ensureBucketStmt *statement
+	lockBucketStmt   *statement
+	updateBucketStmt *statement
+	pruneBucketsStmt *statement
+
+	logger *zap.Logger
+}
+
+func NewPostgresRateLimitClient(db *sql.DB, logger *zap.Logger) (*PostgresRateLimitClient, error) {
+	client := &PostgresRateLimitClient{
+		db:     db,
+		logger: logger,
+	}
+
+	var err error
+
+	client.ensureBucketStmt, err = prepare(db, "rate_limit_buckets.ensure", "INSERT INTO rate_limit_buckets (bucket_key, tokens, updated_at) VALUES ($1, $2, now()) ON CONFLICT (bucket_key) DO NOTHING;")
+	if err != nil {
+		return nil, err
+	}
+
+	client.lockBucketStmt, err = prepare(db, "rate_limit_buckets.lock", "SELECT tokens, EXTRACT(EPOCH FROM (now() - updated_at)) FROM rate_limit_buckets WHERE bucket_key = $1 FOR UPDATE;")
+	if err != nil {
+		return nil, err
+	}
+
+	client.updateBucketStmt, err = prepare(db, "rate_limit_buckets.update", "UPDATE rate_limit_buckets SET tokens = $2, updated_at = now() WHERE bucket_key = $1;")
+	if err != nil {
+		return nil, err
+	}
+
+	client.pruneBucketsStmt, err = prepare(db, "rate_limit_buckets.prune", "DELETE FROM rate_limit_buckets WHERE updated_at < $1;")
+	if err != nil {
+		return nil, err
+	}
+
+	return client, nil
+}
+
+// Close releases the client's prepared statements. The client must not be
+// used afterwards.
+func (client *PostgresRateLimitClient) Close() error {
+	return closeStatements(
+		client.ensureBucketStmt,
+		client.lockBucketStmt,
+		client.updateBucketStmt,
+		client.pruneBucketsStmt,
+	)
+}
+
+func (client *PostgresRateLimitClient) Take(ctx context.Context, key string, limit store.RateLimit) (*store.RateLimitResult, error) {
+	key = hashBucketKey(key)
+
+	tx, err := client.db.BeginTx(ctx, nil)
+	if err != nil {
+		return nil, fmt.Errorf("unable to begin rate limit transaction: %s", err.Error())
+	}
+
+	defer tx.Rollback()
+
+	if _, err = client.ensureBucketStmt.exec(ctx, tx, key, float64(limit.Burst)); err != nil {
+		return nil, fmt.Errorf("unable to ensure bucket [%s]: %s", key, err.Error())
+	}
+
+	var tokens, elapsed float64
+
+	if err = client.lockBucketStmt.queryRow(ctx, tx, key).Scan(&tokens, &elapsed); err != nil {
+		return nil, fmt.Errorf("unable to lock bucket [%s]: %s", key, err.Error())
+	}
+
+	tokens = math.Min(float64(limit.Burst), tokens+math.Max(elapsed, 0)*limit.Rate)
+
+	allowed := tokens >= 1
+	if allowed {
+		tokens--
+	}
+
+	if _, err = client.updateBucketStmt.exec(ctx, tx, key, tokens); err != nil {
+		return nil, fmt.Errorf("unable to update bucket [%s]: %s", key, err.Error())
+	}
+
+	if err = tx.Commit(); err != nil {
+		return nil, fmt.Errorf("unable to commit rate limit transaction: %s", err.Error())
+	}
+
+	return store.NewRateLimitResult(allowed, tokens, limit), nil
+}
+
+// Prune deletes buckets that have not been used since before the cutoff.
+func (client *PostgresRateLimitClient) Prune(ctx context.Context, olderThan time.Duration) {
+	result, err := client.pruneBucketsStmt.exec(ctx, nil, time.Now().Add(-olderThan))
+	if err != nil && ctx.Err() == nil {
+		client.logger.Warn("unable to prune rate limit buckets", zap.Error(err))
+		return
+	} else if err != nil {
+		return
+	}
+
+	if pruned, err := result.RowsAffected(); err == nil && pruned > 0 {
+		client.logger.Debug("pruned rate limit buckets", zap.Int64("pruned", pruned))
+	}
+}
+
+func hashBucketKey(key string) string {
+	hash := sha256.Sum256([]byte(key))
+	return hex.EncodeToString(hash[:])
+}
@@ -0,0 +1,143 @@
+package postgres
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+
+	"github.com/lib/pq"
+	"go.uber.org/zap"
+	"redcellpartners.com/users-posts-api/store"
+)
+
+const (
+	// foreignKeyViolation is the postgres error code for a missing referenced row.
+	foreignKeyViolation = "23503"
+
+	reactionUserConstraint = "post_reactions_user_id_fkey"
+)
+
+var _ store.ReactionStore = &PostgresReactionClient{}
+
+// PostgresReactionClient stores reactions and keeps post_reaction_counts in
+// step with them. A count only moves when the reaction row was actually
+// inserted or deleted in the same transaction, so concurrent and repeated
+// requests can not skew it.
+type PostgresReactionClient struct {
+	db *sql.DB
+
+	insertReactionStmt *statement
+	deleteReactionStmt *statement
+	incrementCountStmt *statement
+	decrementCountStmt *statement
+	activeUserStmt     *statement
+
+	logger *zap.Logger
+}
+
+func NewPostgresReactionClient(db *sql.DB, logger *zap.Logger) (*PostgresReactionClient, error) {
+	client := &PostgresReactionClient{
+		db:     db,
+		logger: logger,
+	}
+
+	var err error
+
+	client.insertReactionStmt, err = prepare(db, "post_reactions.insert", "INSERT INTO post_reactions (post_id, user_id, kind) VALUES ($1, $2, $3) ON CONFLICT DO NOTHING;")
+	if err != nil {
+		return nil, err
+	}
+
+	client.deleteReactionStmt, err = prepare(db, "post_reactions.delete", "DELETE FROM post_reactions WHERE post_id = $1 AND user_id = $2 AND kind = $3;")
+	if err != nil {
+		return nil, err
+	}
+
+	client.incrementCountStmt, err = prepare(db, "post_reaction_counts.increment", `INSERT INTO post_reaction_counts (post_id, kind, count) VALUES ($1, $2, 1)
+ON CONFLICT (post_id, kind) DO UPDATE SET count = post_reaction_counts.count + 1;`)
+	if err != nil {
+		return nil, err
+	}
+
+	client.decrementCountStmt, err = prepare(db, "post_reaction_counts.decrement", "UPDATE post_reaction_counts SET count = count - 1 WHERE post_id = $1 AND kind = $2;")
+	if err != nil {
+		return nil, err
+	}
+
+	client.activeUserStmt, err = prepare(db, "users.active", "SELECT EXISTS (SELECT 1 FROM users WHERE id = $1 AND deleted_at IS NULL);")
+	if err != nil {
+		return nil, err
+	}
+
+	return client, nil
+}
+
+// Close releases the client's prepared statements. The client must not be
+// used afterwards.
+func (client *PostgresReactionClient) Close() error {
+	return closeStatements(
+		client.insertReactionStmt,
+		client.deleteReactionStmt,
+		client.incrementCountStmt,
+		client.decrementCountStmt,
+		client.activeUserStmt,
+	)
+}
+
+func (client *PostgresReactionClient) AddReaction(ctx context.Context, postID, userID int, kind string) error {
+	var active bool
+
+	if err := client.activeUserStmt.queryRow(ctx, nil, userID).Scan(&active); err != nil {
+		return fmt.Errorf("unable to check user [%d]: %s", userID, err.Error())
+	}
+
+	if !active {
+		return store.ErrUnknownUser
+	}
+
+	return client.changeReaction(ctx, postID, userID, kind, client.insertReactionStmt, client.incrementCountStmt)
+}
+
+func (client *PostgresReactionClient) RemoveReaction(ctx context.Context, postID, userID int, kind string) error {
+	return client.changeReaction(ctx, postID, userID, kind, client.deleteReactionStmt, client.decrementCountStmt)
+}
+
+// changeReaction runs the reaction change and, only if it changed a row, the
+// matching count change in one transaction.
+func (client *PostgresReactionClient) changeReaction(ctx context.Context, postID, userID int, kind string, reactionStmt, countStmt *statement) error {
+	tx, err := client.db.BeginTx(ctx, nil)
+	if err != nil {
+		return fmt.Errorf("unable to begin reaction transaction: %s", err.Error())
+	}
+
+	defer tx.Rollback()
+
+	result, err := reactionStmt.exec(ctx, tx, postID, userID, kind)
+
+	var pqErr *pq.Error
+	if errors.As(err, &pqErr) && pqErr.Code == foreignKeyViolation && pqErr.Constraint == reactionUserConstraint {
+		return store.ErrUnknownUser
+	} else if err != nil {
+		return fmt.Errorf("unable to change %s reaction of user [%d] on post [%d]: %s", kind, userID, postID, err.Error())
+	}
+
+	changed, err := result.RowsAffected()
+	if err != nil {
+		return fmt.Errorf("error getting rows affected for reaction on post [%d]: %s", postID, err.Error())
+	}
+
+	if changed == 0 {
+		return nil
+	}
+
+	if _, err = countStmt.exec(ctx, tx, postID, kind); err != nil {
+		return fmt.Errorf("unable to count %s reactions on post [%d]: %s", kind, postID, err.Error())
+	}
+
+	if err = tx.Commit(); err != nil {
+		return fmt.Errorf("unable to commit reaction on post [%d]: %s", postID, err.Error())
+	}
+
+	return nil
+}
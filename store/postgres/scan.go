@@ -0,0 +1,19 @@
+package postgres
+
+// rowScanner is satisfied by both *sql.Row and *sql.Rows so a single scan
+// helper can be shared between single row and list queries.
+type rowScanner interface {
+	Scan(dest ...interface{}) error
+}
+
+// prefixedScanner scans the leading columns of a row into prefix and hands
+// the rest to the destinations of the wrapped scan, so a row helper can read
+// rows that select extra columns ahead of its own.
+type prefixedScanner struct {
+	row    rowScanner
+	prefix []interface{}
+}
+
+func (scanner prefixedScanner) Scan(dest ...interface{}) error {
+	return scanner.row.Scan(append(append([]interface{}{}, scanner.prefix...), dest...)...)
+}
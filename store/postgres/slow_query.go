@@ -0,0 +1,157 @@
+package postgres
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"go.uber.org/zap"
+	"redcellpartners.com/users-posts-api/logging"
+)
+
+const explainTimeout = time.Second * 5
+
+var slowQueryLog atomic.Pointer[SlowQueryLog]
+
+// SlowQueryLog logs statements that take longer than a threshold. Arguments
+// are never logged, only their types, since they hold user data. Each
+// statement is logged at most once per interval, and the number of slow
+// executions suppressed in between is reported with the next one.
+type SlowQueryLog struct {
+	db        *sql.DB
+	threshold time.Duration
+	explain   bool
+	interval  time.Duration
+
+	mutex      sync.Mutex
+	lastLogged map[string]time.Time
+	suppressed map[string]int
+
+	logger *zap.Logger
+}
+
+// NewSlowQueryLog returns a slow query log. When explain is set the plan of
+// each logged statement is captured with EXPLAIN (ANALYZE OFF), which plans
+// the statement without running it.
+func NewSlowQueryLog(db *sql.DB, threshold time.Duration, explain bool, interval time.Duration, logger *zap.Logger) *SlowQueryLog {
+	return &SlowQueryLog{
+		db:         db,
+		threshold:  threshold,
+		explain:    explain,
+		interval:   interval,
+		lastLogged: make(map[string]time.Time),
+		suppressed: make(map[string]int),
+		logger:     logger,
+	}
+}
+
+// InstallSlowQueryLog makes every statement of the postgres clients report to
+// the slow query log. A nil log disables slow query logging.
+func InstallSlowQueryLog(log *SlowQueryLog) {
+	slowQueryLog.Store(log)
+}
+
+// observe is called with the duration of every statement execution.
+func (log *SlowQueryLog) observe(ctx context.Context, s *statement, duration time.Duration, args []interface{}) {
+	if log == nil || log.threshold <= 0 || duration < log.threshold {
+		return
+	}
+
+	suppressed, ok := log.allow(s.name)
+	if !ok {
+		return
+	}
+
+	fields := []zap.Field{
+		zap.String("statement", s.name),
+		zap.String("sql", s.sql),
+		zap.Strings("arg_types", argTypes(args)),
+		zap.Duration("duration", duration),
+		zap.Duration("threshold", log.threshold),
+		zap.Int("suppressed", suppressed),
+	}
+
+	logger := logging.FromContext(ctx, log.logger)
+
+	if !log.explain {
+		logger.Warn("slow query", fields...)
+		return
+	}
+
+	// planning again takes a round trip, so it happens off the request path
+	go func() {
+		plan, err := log.explainPlan(s, args)
+		if err != nil {
+			fields = append(fields, zap.NamedError("explain_error", err))
+		} else {
+			fields = append(fields, zap.String("plan", plan))
+		}
+
+		logger.Warn("slow query", fields...)
+	}()
+}
+
+// allow reports whether the statement may be logged now, along with how many
+// slow executions of it were suppressed since it was last logged.
+func (log *SlowQueryLog) allow(name string) (int, bool) {
+	log.mutex.Lock()
+	defer log.mutex.Unlock()
+
+	now := time.Now()
+
+	if last, ok := log.lastLogged[name]; ok && now.Sub(last) < log.interval {
+		log.suppressed[name]++
+		return 0, false
+	}
+
+	suppressed := log.suppressed[name]
+
+	log.lastLogged[name] = now
+	delete(log.suppressed, name)
+
+	return suppressed, true
+}
+
+func (log *SlowQueryLog) explainPlan(s *statement, args []interface{}) (string, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), explainTimeout)
+	defer cancel()
+
+	rows, err := log.db.QueryContext(ctx, "EXPLAIN (ANALYZE OFF) "+strings.TrimSuffix(strings.TrimSpace(s.sql), ";"), args...)
+	if err != nil {
+		return "", fmt.Errorf("unable to explain %s: %s", s.name, err.Error())
+	}
+
+	defer rows.Close()
+
+	var lines []string
+
+	for rows.Next() {
+		var line string
+		if err := rows.Scan(&line); err != nil {
+			return "", fmt.Errorf("unable to scan plan of %s: %s", s.name, err.Error())
+		}
+
+		lines = append(lines, line)
+	}
+
+	if err = rows.Err(); err != nil {
+		return "", fmt.Errorf("unable to read plan of %s: %s", s.name, err.Error())
+	}
+
+	return strings.Join(lines, "\n"), nil
+}
+
+// argTypes describes the arguments without their values.
+func argTypes(args []interface{}) []string {
+	types := make([]string, 0, len(args))
+
+	for _, arg := range args {
+		types = append(types, fmt.Sprintf("%T", arg))
+	}
+
+	return types
+}
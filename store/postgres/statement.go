@@ -0,0 +1,150 @@
+package postgres
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"time"
+
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/trace"
+)
+
+const tracerName = "redcellpartners.com/users-posts-api/store/postgres"
+
+// statement is a named prepared statement. Every execution is wrapped in a
+// span named after the statement so SQL shows up in traces under the store
+// call that issued it, and is timed for the slow query log.
+//
+// The underlying sql.Stmt is prepared lazily on every pooled connection it
+// runs on, so when postgres restarts and the pool replaces its connections the
+// statement is re-prepared on the new ones without any handling here.
+type statement struct {
+	name string
+	sql  string
+	stmt *sql.Stmt
+}
+
+func prepare(db *sql.DB, name, query string) (*statement, error) {
+	stmt, err := db.Prepare(query)
+	if err != nil {
+		return nil, fmt.Errorf("unable to prepare %s statement: %s", name, err.Error())
+	}
+
+	return &statement{
+		name: name,
+		sql:  query,
+		stmt: stmt,
+	}, nil
+}
+
+// bind returns the statement to run, scoped to the transaction when one is
+// given.
+func (s *statement) bind(ctx context.Context, tx *sql.Tx) *sql.Stmt {
+	if tx == nil {
+		return s.stmt
+	}
+
+	return tx.StmtContext(ctx, s.stmt)
+}
+
+func (s *statement) startSpan(ctx context.Context) (context.Context, trace.Span) {
+	return otel.Tracer(tracerName).Start(ctx, s.name,
+		trace.WithSpanKind(trace.SpanKindClient),
+		trace.WithAttributes(
+			attribute.String("db.system", "postgresql"),
+			attribute.String("db.statement", s.sql),
+		),
+	)
+}
+
+func endSpan(span trace.Span, err error) {
+	if err != nil && err != sql.ErrNoRows {
+		span.RecordError(err)
+		span.SetStatus(codes.Error, err.Error())
+	}
+
+	span.End()
+}
+
+// rows are the result of a query. The query's span and slow query timing
+// last until they are closed, so reading a large result set counts too.
+type rows struct {
+	*sql.Rows
+	closed bool
+	end    func(err error)
+}
+
+// Close closes the rows and ends the query with the error that stopped the
+// iteration, if any.
+func (r *rows) Close() error {
+	err := r.Rows.Close()
+
+	if !r.closed {
+		r.closed = true
+		r.end(r.Rows.Err())
+	}
+
+	return err
+}
+
+func (s *statement) query(ctx context.Context, tx *sql.Tx, args ...interface{}) (*rows, error) {
+	ctx, span := s.startSpan(ctx)
+	start := time.Now()
+
+	end := func(err error) {
+		slowQueryLog.Load().observe(ctx, s, time.Since(start), args)
+		endSpan(span, err)
+	}
+
+	result, err := s.bind(ctx, tx).QueryContext(ctx, args...)
+	if err != nil {
+		end(err)
+		return nil, err
+	}
+
+	return &rows{Rows: result, end: end}, nil
+}
+
+func (s *statement) queryRow(ctx context.Context, tx *sql.Tx, args ...interface{}) *sql.Row {
+	ctx, span := s.startSpan(ctx)
+	start := time.Now()
+
+	row := s.bind(ctx, tx).QueryRowContext(ctx, args...)
+
+	slowQueryLog.Load().observe(ctx, s, time.Since(start), args)
+	endSpan(span, row.Err())
+
+	return row
+}
+
+func (s *statement) exec(ctx context.Context, tx *sql.Tx, args ...interface{}) (sql.Result, error) {
+	ctx, span := s.startSpan(ctx)
+	start := time.Now()
+
+	result, err := s.bind(ctx, tx).ExecContext(ctx, args...)
+
+	slowQueryLog.Load().observe(ctx, s, time.Since(start), args)
+	endSpan(span, err)
+
+	return result, err
+}
+
+// closeStatements closes every statement, returning the first error.
+func closeStatements(statements ...*statement) error {
+	var firstErr error
+
+	for _, s := range statements {
+		if s == nil {
+			continue
+		}
+
+		if err := s.stmt.Close(); err != nil && firstErr == nil {
+			firstErr = fmt.Errorf("unable to close %s statement: %s", s.name, err.Error())
+		}
+	}
+
+	return firstErr
+}
@@ -1,66 +1,206 @@
 package postgres
 
 import (
+	"context"
 	"database/sql"
+	"encoding/hex"
+	"errors"
 	"fmt"
+	"strings"
 	"time"
 
+	"github.com/lib/pq"
 	"go.uber.org/zap"
+	"redcellpartners.com/users-posts-api/encryption"
+	"redcellpartners.com/users-posts-api/logging"
 	"redcellpartners.com/users-posts-api/model"
 	"redcellpartners.com/users-posts-api/store"
 )
 
+const userColumns = "id, first_name, last_name, handle, email, email_ciphertext, email_key_id, created_at, updated_at, deleted_at"
+
+const (
+	// uniqueViolation is the postgres error code for a duplicate key.
+	uniqueViolation = "23505"
+
+	userHandleIndex     = "idx_users_handle"
+	userEmailHashIndex  = "idx_users_email_hash"
+	userEmailKey        = "users_email_key"
+	userEmailLowerIndex = "idx_users_email_lower"
+
+	// EMAIL_HASH_BACKFILL_BATCH_SIZE is how many plaintext emails are hashed
+	// per query when backfilling.
+	EMAIL_HASH_BACKFILL_BATCH_SIZE = 500
+)
+
 var _ store.UserStore = &PostgresUserClient{}
 
 type PostgresUserClient struct {
-	listUsersStmt  *sql.Stmt
-	createUserStmt *sql.Stmt
-	getUserStmt    *sql.Stmt
-	updateUserStmt *sql.Stmt
-	deleteUserStmt *sql.Stmt
+	db      *sql.DB
+	keyring *encryption.Keyring
+
+	listUsersStmt        *statement
+	createUserStmt       *statement
+	getUserStmt          *statement
+	getUserByEmailStmt   *statement
+	getUserByHandleStmt  *statement
+	lockUserStmt         *statement
+	updateUserStmt       *statement
+	deleteUserStmt       *statement
+	deleteUserPostsStmt  *statement
+	lockDeletedUserStmt  *statement
+	restoreUserStmt      *statement
+	restoreUserPostsStmt *statement
+	purgeUsersStmt       *statement
+	listUnrotatedStmt    *statement
+	rotateUserStmt       *statement
+	listUnhashedStmt     *statement
+	hashUserEmailStmt    *statement
+	insertAuditEventStmt *statement
 
 	logger *zap.Logger
 }
 
-func NewPostgresUserClient(db *sql.DB, logger *zap.Logger) (*PostgresUserClient, error) {
+// NewPostgresUserClient creates a user client. When a keyring is given user
+// emails are envelope encrypted at rest and looked up through a keyed hash,
+// otherwise they are stored in plaintext.
+func NewPostgresUserClient(db *sql.DB, keyring *encryption.Keyring, logger *zap.Logger) (*PostgresUserClient, error) {
 	client := &PostgresUserClient{
-		logger: logger,
+		db:      db,
+		keyring: keyring,
+		logger:  logger,
 	}
 
 	var err error
 
-	client.listUsersStmt, err = db.Prepare("SELECT id, first_name, last_name, email, created_at, updated_at FROM users LIMIT 100;")
+	client.listUsersStmt, err = prepare(db, "users.list", "SELECT "+userColumns+" FROM users WHERE $1::boolean OR deleted_at IS NULL LIMIT 100;")
+	if err != nil {
+		return nil, err
+	}
+
+	client.createUserStmt, err = prepare(db, "users.create", "INSERT INTO users (first_name, last_name, email, email_ciphertext, email_key_id, email_hash, created_at, handle) VALUES ($1, $2, $3, $4, $5, $6, $7, $8) RETURNING id;")
+	if err != nil {
+		return nil, err
+	}
+
+	client.getUserStmt, err = prepare(db, "users.get", "SELECT "+userColumns+" FROM users WHERE id = $1 AND deleted_at IS NULL;")
+	if err != nil {
+		return nil, err
+	}
+
+	client.getUserByEmailStmt, err = prepare(db, "users.get_by_email", "SELECT "+userColumns+" FROM users WHERE (email_hash = $1 OR lower(email) = $2) AND deleted_at IS NULL LIMIT 1;")
+	if err != nil {
+		return nil, err
+	}
+
+	client.getUserByHandleStmt, err = prepare(db, "users.get_by_handle", "SELECT "+userColumns+" FROM users WHERE handle = $1 AND deleted_at IS NULL;")
+	if err != nil {
+		return nil, err
+	}
+
+	client.lockUserStmt, err = prepare(db, "users.lock", "SELECT "+userColumns+" FROM users WHERE id = $1 AND deleted_at IS NULL FOR UPDATE;")
+	if err != nil {
+		return nil, err
+	}
+
+	client.updateUserStmt, err = prepare(db, "users.update", "UPDATE users SET first_name = $2, last_name = $3, email = $4, email_ciphertext = $5, email_key_id = $6, email_hash = $7, updated_at = $8, handle = $9 WHERE id = $1 RETURNING "+userColumns+";")
+	if err != nil {
+		return nil, err
+	}
+
+	client.deleteUserStmt, err = prepare(db, "users.delete", "UPDATE users SET deleted_at = $2 WHERE id = $1 AND deleted_at IS NULL;")
 	if err != nil {
-		return nil, fmt.Errorf("unable to prepare list users statement: %s", err.Error())
+		return nil, err
 	}
 
-	client.createUserStmt, err = db.Prepare("INSERT INTO users (first_name, last_name, email, created_at) VALUES ($1, $2, $3, $4) RETURNING id;")
+	// the user's posts are deleted at the very same time as the user, which
+	// is how a restore tells them apart from posts deleted on their own
+	client.deleteUserPostsStmt, err = prepare(db, "users.delete_posts", "UPDATE posts SET deleted_at = $2 WHERE user_id = $1 AND deleted_at IS NULL;")
 	if err != nil {
-		return nil, fmt.Errorf("unable to prepare create user statement: %s", err.Error())
+		return nil, err
 	}
 
-	client.getUserStmt, err = db.Prepare("SELECT id, first_name, last_name, email, created_at, updated_at FROM users WHERE id = $1;")
+	client.lockDeletedUserStmt, err = prepare(db, "users.lock_deleted", "SELECT "+userColumns+" FROM users WHERE id = $1 AND deleted_at IS NOT NULL FOR UPDATE;")
 	if err != nil {
-		return nil, fmt.Errorf("unable to prepare get user statement: %s", err.Error())
+		return nil, err
 	}
 
-	client.updateUserStmt, err = db.Prepare("UPDATE users SET first_name = $2, last_name = $3, email = $4, updated_at = $5 WHERE id = $1 RETURNING id, first_name, last_name, email, created_at, updated_at;")
+	client.restoreUserStmt, err = prepare(db, "users.restore", "UPDATE users SET deleted_at = NULL WHERE id = $1 RETURNING "+userColumns+";")
 	if err != nil {
-		return nil, fmt.Errorf("unable to prepare update user statement: %s", err.Error())
+		return nil, err
 	}
 
-	client.deleteUserStmt, err = db.Prepare("DELETE FROM users WHERE id = $1;")
+	client.restoreUserPostsStmt, err = prepare(db, "users.restore_posts", "UPDATE posts SET deleted_at = NULL WHERE user_id = $1 AND deleted_at = $2;")
 	if err != nil {
-		return nil, fmt.Errorf("unable to prepare update user statement: %s", err.Error())
+		return nil, err
+	}
+
+	// posts, comments, reactions and follows of the user go with it through
+	// ON DELETE CASCADE
+	client.purgeUsersStmt, err = prepare(db, "users.purge", `DELETE FROM users WHERE id IN (
+    SELECT id FROM users WHERE deleted_at < $1 ORDER BY deleted_at LIMIT $2 FOR UPDATE SKIP LOCKED
+) RETURNING id;`)
+	if err != nil {
+		return nil, err
+	}
+
+	client.listUnrotatedStmt, err = prepare(db, "users.list_unrotated", "SELECT id, email, email_ciphertext, email_key_id FROM users WHERE email_ciphertext IS NULL OR email_key_id <> $1 ORDER BY id LIMIT $2 FOR UPDATE SKIP LOCKED;")
+	if err != nil {
+		return nil, err
+	}
+
+	client.rotateUserStmt, err = prepare(db, "users.rotate", "UPDATE users SET email = NULL, email_ciphertext = $2, email_key_id = $3, email_hash = $4 WHERE id = $1;")
+	if err != nil {
+		return nil, err
+	}
+
+	client.listUnhashedStmt, err = prepare(db, "users.list_unhashed", "SELECT id, email FROM users WHERE email_hash IS NULL AND email IS NOT NULL AND id > $1 ORDER BY id LIMIT $2;")
+	if err != nil {
+		return nil, err
+	}
+
+	client.hashUserEmailStmt, err = prepare(db, "users.hash_email", "UPDATE users SET email_hash = $2 WHERE id = $1 AND email = $3 AND email_hash IS NULL;")
+	if err != nil {
+		return nil, err
+	}
+
+	client.insertAuditEventStmt, err = prepareInsertAuditEvent(db)
+	if err != nil {
+		return nil, err
 	}
 
 	return client, nil
 }
 
-func (client *PostgresUserClient) ListUsers() ([]*model.User, error) {
-	rows, err := client.listUsersStmt.Query()
+// Close releases the client's prepared statements. The client must not be
+// used afterwards.
+func (client *PostgresUserClient) Close() error {
+	return closeStatements(
+		client.listUsersStmt,
+		client.createUserStmt,
+		client.getUserStmt,
+		client.getUserByEmailStmt,
+		client.getUserByHandleStmt,
+		client.lockUserStmt,
+		client.updateUserStmt,
+		client.deleteUserStmt,
+		client.deleteUserPostsStmt,
+		client.lockDeletedUserStmt,
+		client.restoreUserStmt,
+		client.restoreUserPostsStmt,
+		client.purgeUsersStmt,
+		client.listUnrotatedStmt,
+		client.rotateUserStmt,
+		client.listUnhashedStmt,
+		client.hashUserEmailStmt,
+		client.insertAuditEventStmt,
+	)
+}
+
+func (client *PostgresUserClient) ListUsers(ctx context.Context, filter store.UserFilter) ([]*model.User, error) {
+	rows, err := client.listUsersStmt.query(ctx, nil, filter.IncludeDeleted)
 	if err != nil {
-		client.logger.Error("unable to list all users", zap.Error(err))
+		logging.FromContext(ctx, client.logger).Error("unable to list all users", zap.Error(err))
 		return nil, err
 	}
 
@@ -69,135 +209,529 @@ func (client *PostgresUserClient) ListUsers() ([]*model.User, error) {
 	users := make([]*model.User, 0)
 
 	for rows.Next() {
-		var (
-			user        = &model.User{}
-			timeUpdated sql.NullString
-		)
-
-		if err := rows.Scan(
-			&user.ID,
-			&user.FirstName,
-			&user.LastName,
-			&user.Email,
-			&user.TimeCreated,
-			&timeUpdated,
-		); err != nil {
-			client.logger.Error("unable to scan user, skipping for now", zap.Error(err))
+		user, err := client.scanUser(rows)
+		if err != nil {
+			logging.FromContext(ctx, client.logger).Error("unable to scan user, skipping for now", zap.Error(err))
 			continue
 		}
 
-		if timeUpdated.Valid {
-			updatedAt, err := time.Parse(time.RFC3339, timeUpdated.String)
-			if err != nil {
-				client.logger.Warn("unable to parse updated_at value", zap.Int("user_id", user.ID), zap.Error(err))
-			} else {
-				user.TimeUpdated = updatedAt
-			}
-		}
-
 		users = append(users, user)
 	}
 
 	return users, nil
 }
 
-func (client *PostgresUserClient) CreateUser(user *model.User) (*model.User, error) {
-	row := client.createUserStmt.QueryRow(user.FirstName, user.LastName, user.Email, time.Now())
+func (client *PostgresUserClient) CreateUser(ctx context.Context, user *model.User) (*model.User, error) {
+	tx, err := client.db.BeginTx(ctx, nil)
+	if err != nil {
+		return nil, fmt.Errorf("unable to begin create user transaction: %s", err.Error())
+	}
 
-	var userID int64
+	defer tx.Rollback()
+
+	email, err := client.encryptEmail(user.Email)
+	if err != nil {
+		return nil, err
+	}
 
-	err := row.Scan(&userID)
+	handle, err := storedHandle(user.Handle)
 	if err != nil {
+		return nil, err
+	}
+
+	row := client.createUserStmt.queryRow(ctx, tx, user.FirstName, user.LastName, email.plaintext, email.ciphertext, email.keyID, email.hash, time.Now(), handle)
+
+	var userID int64
+
+	err = row.Scan(&userID)
+	if isHandleTaken(err) {
+		return nil, store.ErrHandleTaken
+	} else if isEmailTaken(err) {
+		return nil, store.ErrEmailTaken
+	} else if err != nil {
 		return nil, fmt.Errorf("unable to scan created user id: %s", err.Error())
 	}
 
-	createdUser, err := client.GetUser(int(userID))
+	createdUser, err := client.scanUser(client.getUserStmt.queryRow(ctx, tx, userID))
 	if err != nil {
 		return nil, fmt.Errorf("unable to get created user: %s", err.Error())
 	}
 
+	if err = recordAuditEvent(ctx, tx, client.insertAuditEventStmt, store.AuditEntityUser, createdUser.ID, store.AuditActionCreate, nil, client.auditUser(createdUser)); err != nil {
+		return nil, err
+	}
+
+	if err = tx.Commit(); err != nil {
+		return nil, fmt.Errorf("unable to commit created user: %s", err.Error())
+	}
+
 	return createdUser, nil
 }
 
-func (client *PostgresUserClient) GetUser(id int) (*model.User, error) {
-	var (
-		user        = &model.User{}
-		timeUpdated sql.NullString
-		err         error
-	)
+func (client *PostgresUserClient) GetUser(ctx context.Context, id int) (*model.User, error) {
+	user, err := client.scanUser(client.getUserStmt.queryRow(ctx, nil, id))
+	if err != nil && err == sql.ErrNoRows {
+		return nil, err
+	} else if err != nil {
+		return nil, fmt.Errorf("unable to scan user [%d]: %s", id, err.Error())
+	}
+
+	return user, nil
+}
 
-	row := client.getUserStmt.QueryRow(id)
+func (client *PostgresUserClient) GetUserByHandle(ctx context.Context, handle string) (*model.User, error) {
+	handle, err := store.NormalizeHandle(handle)
+	if err != nil || handle == "" {
+		return nil, sql.ErrNoRows
+	}
 
-	if err = row.Scan(
-		&user.ID,
-		&user.FirstName,
-		&user.LastName,
-		&user.Email,
-		&user.TimeCreated,
-		&timeUpdated,
-	); err != nil && err == sql.ErrNoRows {
+	user, err := client.scanUser(client.getUserByHandleStmt.queryRow(ctx, nil, handle))
+	if err != nil && err == sql.ErrNoRows {
 		return nil, err
 	} else if err != nil {
-		return nil, fmt.Errorf("unable to scan user [%d]: %s", id, err.Error())
+		return nil, fmt.Errorf("unable to scan user by handle %q: %s", handle, err.Error())
 	}
 
-	if timeUpdated.Valid {
-		updatedAt, err := time.Parse(time.RFC3339, timeUpdated.String)
-		if err != nil {
-			client.logger.Warn("unable to parse updated_at value", zap.Int("user_id", user.ID), zap.Error(err))
-		} else {
-			user.TimeUpdated = updatedAt
-		}
+	return user, nil
+}
+
+// GetUserByEmail looks a user up by email address, matching both encrypted
+// rows (through the keyed hash) and rows not yet encrypted.
+func (client *PostgresUserClient) GetUserByEmail(ctx context.Context, email string) (*model.User, error) {
+	var hash []byte
+
+	if client.keyring != nil {
+		hash = client.keyring.Hash([]byte(normalizeEmail(email)))
+	}
+
+	user, err := client.scanUser(client.getUserByEmailStmt.queryRow(ctx, nil, hash, normalizeEmail(email)))
+	if err != nil && err == sql.ErrNoRows {
+		return nil, err
+	} else if err != nil {
+		return nil, fmt.Errorf("unable to scan user by email: %s", err.Error())
+	}
+
+	return user, nil
+}
+
+func (client *PostgresUserClient) UpdateUser(ctx context.Context, userInput *model.User) (*model.User, error) {
+	tx, err := client.db.BeginTx(ctx, nil)
+	if err != nil {
+		return nil, fmt.Errorf("unable to begin update user transaction: %s", err.Error())
+	}
+
+	defer tx.Rollback()
+
+	before, err := client.scanUser(client.lockUserStmt.queryRow(ctx, tx, userInput.ID))
+	if err != nil {
+		return nil, fmt.Errorf("unable to lock user [%d]: %s", userInput.ID, err.Error())
+	}
+
+	email, err := client.encryptEmail(userInput.Email)
+	if err != nil {
+		return nil, err
+	}
+
+	handle, err := storedHandle(userInput.Handle)
+	if err != nil {
+		return nil, err
+	}
+
+	row := client.updateUserStmt.queryRow(ctx, tx, userInput.ID, userInput.FirstName, userInput.LastName, email.plaintext, email.ciphertext, email.keyID, email.hash, time.Now(), handle)
+
+	user, err := client.scanUser(row)
+	if isHandleTaken(err) {
+		return nil, store.ErrHandleTaken
+	} else if isEmailTaken(err) {
+		return nil, store.ErrEmailTaken
+	} else if err != nil {
+		return nil, fmt.Errorf("unable to scan user [%d]: %s", userInput.ID, err.Error())
+	}
+
+	if err = recordAuditEvent(ctx, tx, client.insertAuditEventStmt, store.AuditEntityUser, user.ID, store.AuditActionUpdate, client.auditUser(before), client.auditUser(user)); err != nil {
+		return nil, err
+	}
+
+	if err = tx.Commit(); err != nil {
+		return nil, fmt.Errorf("unable to commit updated user [%d]: %s", user.ID, err.Error())
+	}
+
+	return user, nil
+}
+
+func (client *PostgresUserClient) DeleteUser(ctx context.Context, id int) error {
+	tx, err := client.db.BeginTx(ctx, nil)
+	if err != nil {
+		return fmt.Errorf("unable to begin delete user transaction: %s", err.Error())
+	}
+
+	defer tx.Rollback()
+
+	before, err := client.scanUser(client.lockUserStmt.queryRow(ctx, tx, id))
+	if err != nil {
+		return fmt.Errorf("unable to lock user [%d]: %s", id, err.Error())
+	}
+
+	now := time.Now()
+
+	result, err := client.deleteUserStmt.exec(ctx, tx, id, now)
+	if err != nil {
+		return fmt.Errorf("unable to delete user [%d]: %s", id, err.Error())
+	}
+
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		return fmt.Errorf("error getting rows affected for user [%d]: %s", id, err.Error())
+	}
+
+	if rowsAffected != int64(1) {
+		return fmt.Errorf("deleted 0 or more than one user requested")
+	}
+
+	if _, err = client.deleteUserPostsStmt.exec(ctx, tx, id, now); err != nil {
+		return fmt.Errorf("unable to delete posts of user [%d]: %s", id, err.Error())
+	}
+
+	if err = recordAuditEvent(ctx, tx, client.insertAuditEventStmt, store.AuditEntityUser, id, store.AuditActionDelete, client.auditUser(before), nil); err != nil {
+		return err
+	}
+
+	if err = tx.Commit(); err != nil {
+		return fmt.Errorf("unable to commit deleted user [%d]: %s", id, err.Error())
+	}
+
+	return nil
+}
+
+func (client *PostgresUserClient) RestoreUser(ctx context.Context, id int) (*model.User, error) {
+	tx, err := client.db.BeginTx(ctx, nil)
+	if err != nil {
+		return nil, fmt.Errorf("unable to begin restore user transaction: %s", err.Error())
+	}
+
+	defer tx.Rollback()
+
+	before, err := client.scanUser(client.lockDeletedUserStmt.queryRow(ctx, tx, id))
+	if err != nil && err == sql.ErrNoRows {
+		return nil, err
+	} else if err != nil {
+		return nil, fmt.Errorf("unable to lock deleted user [%d]: %s", id, err.Error())
+	}
+
+	if _, err = client.restoreUserPostsStmt.exec(ctx, tx, id, *before.TimeDeleted); err != nil {
+		return nil, fmt.Errorf("unable to restore posts of user [%d]: %s", id, err.Error())
+	}
+
+	user, err := client.scanUser(client.restoreUserStmt.queryRow(ctx, tx, id))
+	if err != nil {
+		return nil, fmt.Errorf("unable to restore user [%d]: %s", id, err.Error())
+	}
+
+	if err = recordAuditEvent(ctx, tx, client.insertAuditEventStmt, store.AuditEntityUser, id, store.AuditActionRestore, client.auditUser(before), client.auditUser(user)); err != nil {
+		return nil, err
+	}
+
+	if err = tx.Commit(); err != nil {
+		return nil, fmt.Errorf("unable to commit restored user [%d]: %s", id, err.Error())
 	}
 
 	return user, nil
 }
 
-func (client *PostgresUserClient) UpdateUser(userInput *model.User) (*model.User, error) {
-	row := client.updateUserStmt.QueryRow(userInput.ID, userInput.FirstName, userInput.LastName, userInput.Email, time.Now())
+func (client *PostgresUserClient) PurgeDeletedUsers(ctx context.Context, cutoff time.Time, limit int) (int, error) {
+	tx, err := client.db.BeginTx(ctx, nil)
+	if err != nil {
+		return 0, fmt.Errorf("unable to begin purge users transaction: %s", err.Error())
+	}
+
+	defer tx.Rollback()
+
+	ids, err := purge(ctx, tx, client.purgeUsersStmt, cutoff, limit)
+	if err != nil {
+		return 0, fmt.Errorf("unable to purge deleted users: %s", err.Error())
+	}
+
+	for _, id := range ids {
+		if err = recordAuditEvent(ctx, tx, client.insertAuditEventStmt, store.AuditEntityUser, id, store.AuditActionPurge, nil, nil); err != nil {
+			return 0, err
+		}
+	}
+
+	if err = tx.Commit(); err != nil {
+		return 0, fmt.Errorf("unable to commit purged users: %s", err.Error())
+	}
+
+	return len(ids), nil
+}
 
+func (client *PostgresUserClient) scanUser(row rowScanner) (*model.User, error) {
 	var (
-		user        = &model.User{}
-		timeUpdated sql.NullString
-		err         error
+		user            = &model.User{}
+		email           sql.NullString
+		emailCiphertext []byte
+		emailKeyID      sql.NullString
+		handle          sql.NullString
+		timeUpdated     sql.NullString
+		timeDeleted     sql.NullTime
 	)
 
-	if err = row.Scan(
+	if err := row.Scan(
 		&user.ID,
 		&user.FirstName,
 		&user.LastName,
-		&user.Email,
+		&handle,
+		&email,
+		&emailCiphertext,
+		&emailKeyID,
 		&user.TimeCreated,
 		&timeUpdated,
+		&timeDeleted,
 	); err != nil {
-		return nil, fmt.Errorf("unable to scan user [%d]: %s", userInput.ID, err.Error())
+		return nil, err
+	}
+
+	if emailCiphertext != nil {
+		if client.keyring == nil {
+			return nil, fmt.Errorf("user [%d] has an encrypted email but no keyring is configured", user.ID)
+		}
+
+		plaintext, err := client.keyring.Decrypt(emailCiphertext, emailKeyID.String)
+		if err != nil {
+			return nil, fmt.Errorf("unable to decrypt email of user [%d]: %s", user.ID, err.Error())
+		}
+
+		user.Email = string(plaintext)
+	} else {
+		user.Email = email.String
+	}
+
+	user.Handle = handle.String
+
+	if timeDeleted.Valid {
+		user.TimeDeleted = &timeDeleted.Time
 	}
 
 	if timeUpdated.Valid {
 		updatedAt, err := time.Parse(time.RFC3339, timeUpdated.String)
 		if err != nil {
-			client.logger.Warn("unable to parse time_update value", zap.Int("user_id", user.ID), zap.Error(err))
+			client.logger.Warn("unable to parse updated_at value", zap.Int("user_id", user.ID), zap.Error(err))
 		} else {
 			user.TimeUpdated = updatedAt
 		}
 	}
+
 	return user, nil
 }
 
-func (client *PostgresUserClient) DeleteUser(id int) error {
-	result, err := client.deleteUserStmt.Exec(id)
+// storedHandle returns the column value of a handle, NULL when the user has
+// none so any number of users can go without one.
+func storedHandle(handle string) (sql.NullString, error) {
+	handle, err := store.NormalizeHandle(handle)
 	if err != nil {
-		return fmt.Errorf("unable to delete user [%d]: %s", id, err.Error())
+		return sql.NullString{}, err
 	}
 
-	rowsAffected, err := result.RowsAffected()
+	return sql.NullString{String: handle, Valid: handle != ""}, nil
+}
+
+func isHandleTaken(err error) bool {
+	var pqErr *pq.Error
+	return errors.As(err, &pqErr) && pqErr.Code == uniqueViolation && pqErr.Constraint == userHandleIndex
+}
+
+// isEmailTaken reports whether err is another user already having the email,
+// either through its keyed hash or in plaintext in any case.
+func isEmailTaken(err error) bool {
+	var pqErr *pq.Error
+	if !errors.As(err, &pqErr) || pqErr.Code != uniqueViolation {
+		return false
+	}
+
+	switch pqErr.Constraint {
+	case userEmailHashIndex, userEmailKey, userEmailLowerIndex:
+		return true
+	default:
+		return false
+	}
+}
+
+type storedEmail struct {
+	plaintext  sql.NullString
+	ciphertext []byte
+	keyID      sql.NullString
+	hash       []byte
+}
+
+// encryptEmail returns the column values to store for an email, which is
+// only kept in plaintext when no keyring is configured.
+func (client *PostgresUserClient) encryptEmail(email string) (*storedEmail, error) {
+	if client.keyring == nil {
+		return &storedEmail{plaintext: sql.NullString{String: email, Valid: true}}, nil
+	}
+
+	ciphertext, keyID, err := client.keyring.Encrypt([]byte(email))
 	if err != nil {
-		return fmt.Errorf("error getting rows affected for user [%d]: %s", id, err.Error())
+		return nil, fmt.Errorf("unable to encrypt email: %s", err.Error())
 	}
 
-	if rowsAffected != int64(1) {
-		return fmt.Errorf("deleted 0 or more than one user requested")
+	return &storedEmail{
+		ciphertext: ciphertext,
+		keyID:      sql.NullString{String: keyID, Valid: true},
+		hash:       client.keyring.Hash([]byte(normalizeEmail(email))),
+	}, nil
+}
+
+// auditUser returns a copy of the user that is safe to write to the audit
+// log. With encryption enabled the email is replaced by its keyed hash so
+// changes are still visible without storing the address in plaintext.
+func (client *PostgresUserClient) auditUser(user *model.User) *model.User {
+	if client.keyring == nil {
+		return user
 	}
 
-	return nil
+	audited := *user
+	audited.Email = "hmac:" + hex.EncodeToString(client.keyring.Hash([]byte(normalizeEmail(user.Email))))
+
+	return &audited
+}
+
+// RotateEmailKeys re-encrypts up to batchSize users whose email is stored in
+// plaintext or under a key other than the primary key, returning how many
+// were rotated. Call it until it returns zero to rotate every row.
+func (client *PostgresUserClient) RotateEmailKeys(ctx context.Context, batchSize int) (int, error) {
+	if client.keyring == nil {
+		return 0, fmt.Errorf("no keyring is configured")
+	}
+
+	tx, err := client.db.BeginTx(ctx, nil)
+	if err != nil {
+		return 0, fmt.Errorf("unable to begin rotate keys transaction: %s", err.Error())
+	}
+
+	defer tx.Rollback()
+
+	rows, err := client.listUnrotatedStmt.query(ctx, tx, client.keyring.PrimaryKeyID(), batchSize)
+	if err != nil {
+		return 0, fmt.Errorf("unable to list users to rotate: %s", err.Error())
+	}
+
+	emails := make(map[int]string)
+
+	for rows.Next() {
+		var (
+			id              int
+			email           sql.NullString
+			emailCiphertext []byte
+			emailKeyID      sql.NullString
+		)
+
+		if err = rows.Scan(&id, &email, &emailCiphertext, &emailKeyID); err != nil {
+			rows.Close()
+			return 0, fmt.Errorf("unable to scan user to rotate: %s", err.Error())
+		}
+
+		if emailCiphertext == nil {
+			emails[id] = email.String
+			continue
+		}
+
+		plaintext, err := client.keyring.Decrypt(emailCiphertext, emailKeyID.String)
+		if err != nil {
+			rows.Close()
+			return 0, fmt.Errorf("unable to decrypt email of user [%d]: %s", id, err.Error())
+		}
+
+		emails[id] = string(plaintext)
+	}
+
+	rows.Close()
+
+	if err = rows.Err(); err != nil {
+		return 0, fmt.Errorf("unable to iterate users to rotate: %s", err.Error())
+	}
+
+	for id, email := range emails {
+		stored, err := client.encryptEmail(email)
+		if err != nil {
+			return 0, err
+		}
+
+		if _, err = client.rotateUserStmt.exec(ctx, tx, id, stored.ciphertext, stored.keyID, stored.hash); err != nil {
+			return 0, fmt.Errorf("unable to rotate email of user [%d]: %s", id, err.Error())
+		}
+	}
+
+	if err = tx.Commit(); err != nil {
+		return 0, fmt.Errorf("unable to commit rotated users: %s", err.Error())
+	}
+
+	return len(emails), nil
+}
+
+// BackfillEmailHashes stores the keyed hash of every email still in
+// plaintext, so the unique index on the hash also covers rows created before
+// encryption was enabled, and returns how many were hashed. A plaintext email
+// that another user already has encrypted is left unhashed and logged.
+func (client *PostgresUserClient) BackfillEmailHashes(ctx context.Context) (int, error) {
+	if client.keyring == nil {
+		return 0, fmt.Errorf("no keyring is configured")
+	}
+
+	var (
+		hashed = 0
+		lastID = 0
+	)
+
+	for {
+		rows, err := client.listUnhashedStmt.query(ctx, nil, lastID, EMAIL_HASH_BACKFILL_BATCH_SIZE)
+		if err != nil {
+			return hashed, fmt.Errorf("unable to list users to hash: %s", err.Error())
+		}
+
+		emails := make(map[int]string)
+
+		for rows.Next() {
+			var (
+				id    int
+				email string
+			)
+
+			if err = rows.Scan(&id, &email); err != nil {
+				rows.Close()
+				return hashed, fmt.Errorf("unable to scan user to hash: %s", err.Error())
+			}
+
+			emails[id] = email
+
+			if id > lastID {
+				lastID = id
+			}
+		}
+
+		rows.Close()
+
+		if err = rows.Err(); err != nil {
+			return hashed, fmt.Errorf("unable to iterate users to hash: %s", err.Error())
+		}
+
+		for id, email := range emails {
+			_, err = client.hashUserEmailStmt.exec(ctx, nil, id, client.keyring.Hash([]byte(normalizeEmail(email))), email)
+			if isEmailTaken(err) {
+				client.logger.Warn("another user has the email of this user, leaving it unhashed", zap.Int("user_id", id))
+				continue
+			} else if err != nil {
+				return hashed, fmt.Errorf("unable to hash email of user [%d]: %s", id, err.Error())
+			}
+
+			hashed++
+		}
+
+		if len(emails) < EMAIL_HASH_BACKFILL_BATCH_SIZE {
+			return hashed, nil
+		}
+	}
+}
+
+func normalizeEmail(email string) string {
+	return strings.ToLower(strings.TrimSpace(email))
 }
@@ -0,0 +1,34 @@
+package postgres
+
+import (
+	"errors"
+	"fmt"
+	"testing"
+
+	"github.com/lib/pq"
+)
+
+func TestIsEmailTaken(t *testing.T) {
+	tests := []struct {
+		name     string
+		err      error
+		expected bool
+	}{
+		{name: "keyed hash", err: &pq.Error{Code: uniqueViolation, Constraint: userEmailHashIndex}, expected: true},
+		{name: "plaintext", err: &pq.Error{Code: uniqueViolation, Constraint: userEmailKey}, expected: true},
+		{name: "plaintext in another case", err: &pq.Error{Code: uniqueViolation, Constraint: userEmailLowerIndex}, expected: true},
+		{name: "wrapped", err: fmt.Errorf("unable to create user: %w", &pq.Error{Code: uniqueViolation, Constraint: userEmailLowerIndex}), expected: true},
+		{name: "handle", err: &pq.Error{Code: uniqueViolation, Constraint: userHandleIndex}},
+		{name: "other violation", err: &pq.Error{Code: foreignKeyViolation, Constraint: userEmailLowerIndex}},
+		{name: "not a postgres error", err: errors.New("idx_users_email_lower")},
+		{name: "no error"},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			if taken := isEmailTaken(test.err); taken != test.expected {
+				t.Errorf("expected %t, got %t", test.expected, taken)
+			}
+		})
+	}
+}
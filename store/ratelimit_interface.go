@@ -0,0 +1,50 @@
+package store
+
+import (
+	"context"
+	"time"
+)
+
+// RateLimit describes a token bucket that refills at Rate tokens per second up
+// to a maximum of Burst tokens.
+type RateLimit struct {
+	Rate  float64
+	Burst int
+}
+
+// RateLimitResult is the state of a bucket after a token was requested from it.
+type RateLimitResult struct {
+	Allowed    bool
+	Limit      int
+	Remaining  int
+	RetryAfter time.Duration
+	ResetAfter time.Duration
+}
+
+type RateLimitStore interface {
+	Take(ctx context.Context, key string, limit RateLimit) (*RateLimitResult, error)
+}
+
+// NewRateLimitResult builds the result for a bucket holding tokens after the
+// take was applied.
+func NewRateLimitResult(allowed bool, tokens float64, limit RateLimit) *RateLimitResult {
+	result := &RateLimitResult{
+		Allowed:   allowed,
+		Limit:     limit.Burst,
+		Remaining: int(tokens),
+	}
+
+	if result.Remaining < 0 {
+		result.Remaining = 0
+	}
+
+	if limit.Rate > 0 {
+		result.ResetAfter = time.Duration((float64(limit.Burst) - tokens) / limit.Rate * float64(time.Second))
+
+		if !allowed {
+			result.RetryAfter = time.Duration((1 - tokens) / limit.Rate * float64(time.Second))
+		}
+	}
+
+	return result
+}
@@ -0,0 +1,76 @@
+package store
+
+import (
+	"testing"
+	"time"
+)
+
+func TestNewRateLimitResult(t *testing.T) {
+	limit := RateLimit{Rate: 2, Burst: 10}
+
+	tests := []struct {
+		name       string
+		allowed    bool
+		tokens     float64
+		remaining  int
+		retryAfter time.Duration
+		resetAfter time.Duration
+	}{
+		{
+			name:       "full bucket after a take",
+			allowed:    true,
+			tokens:     9,
+			remaining:  9,
+			resetAfter: time.Second / 2,
+		},
+		{
+			name:       "fractional tokens round down",
+			allowed:    true,
+			tokens:     4.5,
+			remaining:  4,
+			resetAfter: time.Second*2 + time.Second*3/4,
+		},
+		{
+			name:       "rejected take waits for the next whole token",
+			allowed:    false,
+			tokens:     0.5,
+			remaining:  0,
+			retryAfter: time.Second / 4,
+			resetAfter: time.Second*4 + time.Second*3/4,
+		},
+		{
+			name:       "empty bucket",
+			allowed:    false,
+			tokens:     0,
+			remaining:  0,
+			retryAfter: time.Second / 2,
+			resetAfter: time.Second * 5,
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			result := NewRateLimitResult(test.allowed, test.tokens, limit)
+
+			if result.Allowed != test.allowed {
+				t.Errorf("expected allowed %t, got %t", test.allowed, result.Allowed)
+			}
+
+			if result.Limit != limit.Burst {
+				t.Errorf("expected limit %d, got %d", limit.Burst, result.Limit)
+			}
+
+			if result.Remaining != test.remaining {
+				t.Errorf("expected %d remaining, got %d", test.remaining, result.Remaining)
+			}
+
+			if result.RetryAfter != test.retryAfter {
+				t.Errorf("expected retry after %s, got %s", test.retryAfter, result.RetryAfter)
+			}
+
+			if result.ResetAfter != test.resetAfter {
+				t.Errorf("expected reset after %s, got %s", test.resetAfter, result.ResetAfter)
+			}
+		})
+	}
+}
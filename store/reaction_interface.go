@@ -0,0 +1,46 @@
+package store
+
+import (
+	"context"
+	"errors"
+)
+
+// REACTION_KINDS are the reactions a user can leave on a post.
+var REACTION_KINDS = []string{"like", "love", "laugh", "wow", "sad", "angry"}
+
+// ErrUnknownUser is returned when a write references a user that does not
+// exist.
+var ErrUnknownUser = errors.New("user does not exist")
+
+func ValidReactionKind(kind string) bool {
+	for _, valid := range REACTION_KINDS {
+		if kind == valid {
+			return true
+		}
+	}
+
+	return false
+}
+
+// ReactionStore records reactions on posts. Each user can leave one reaction
+// of each kind per post, so adding or removing a reaction twice is a no-op.
+// The per post counts are returned on model.Post by the PostStore.
+type ReactionStore interface {
+	AddReaction(ctx context.Context, postID, userID int, kind string) error
+	RemoveReaction(ctx context.Context, postID, userID int, kind string) error
+}
+
+type viewerContextKey struct{}
+
+// WithViewer records the id of the user reading through the returned
+// context, so reads can include the viewer's own reactions.
+func WithViewer(ctx context.Context, userID int) context.Context {
+	return context.WithValue(ctx, viewerContextKey{}, userID)
+}
+
+// ViewerFromContext returns the viewing user's id, or 0 when the viewer is
+// unknown.
+func ViewerFromContext(ctx context.Context) int {
+	userID, _ := ctx.Value(viewerContextKey{}).(int)
+	return userID
+}
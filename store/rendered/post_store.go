@@ -0,0 +1,120 @@
+package rendered
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"redcellpartners.com/users-posts-api/markdown"
+	"redcellpartners.com/users-posts-api/model"
+	"redcellpartners.com/users-posts-api/store"
+)
+
+var _ store.PostStore = &PostStore{}
+
+// PostStore sets the excerpt and reading time of every post the wrapped post
+// store returns, rendering each revision's content once.
+type PostStore struct {
+	store.PostStore
+	renderer *markdown.Renderer
+}
+
+func NewPostStore(next store.PostStore, renderer *markdown.Renderer) *PostStore {
+	return &PostStore{
+		PostStore: next,
+		renderer:  renderer,
+	}
+}
+
+func (decorator *PostStore) ListPosts(ctx context.Context, filter store.PostFilter) ([]*model.Post, error) {
+	return decorator.renderAll(decorator.PostStore.ListPosts(ctx, filter))
+}
+
+func (decorator *PostStore) CreatePost(ctx context.Context, post *model.Post) (*model.Post, error) {
+	return decorator.render(decorator.PostStore.CreatePost(ctx, post))
+}
+
+func (decorator *PostStore) GetPost(ctx context.Context, id int) (*model.Post, error) {
+	return decorator.render(decorator.PostStore.GetPost(ctx, id))
+}
+
+func (decorator *PostStore) GetPostBySlug(ctx context.Context, slug string) (*model.Post, error) {
+	return decorator.render(decorator.PostStore.GetPostBySlug(ctx, slug))
+}
+
+func (decorator *PostStore) UpdatePost(ctx context.Context, post *model.Post) (*model.Post, error) {
+	return decorator.render(decorator.PostStore.UpdatePost(ctx, post))
+}
+
+func (decorator *PostStore) ListFeed(ctx context.Context, userID int, page store.Page) ([]*model.Post, error) {
+	return decorator.renderAll(decorator.PostStore.ListFeed(ctx, userID, page))
+}
+
+func (decorator *PostStore) SearchPosts(ctx context.Context, query string, page store.SearchPage) ([]*model.PostSearchResult, error) {
+	results, err := decorator.PostStore.SearchPosts(ctx, query, page)
+	if err != nil {
+		return nil, err
+	}
+
+	for _, result := range results {
+		if err = decorator.annotate(result.Post); err != nil {
+			return nil, err
+		}
+	}
+
+	return results, nil
+}
+
+func (decorator *PostStore) PublishPost(ctx context.Context, id int, publishAt time.Time) (*model.Post, error) {
+	return decorator.render(decorator.PostStore.PublishPost(ctx, id, publishAt))
+}
+
+func (decorator *PostStore) ArchivePost(ctx context.Context, id int) (*model.Post, error) {
+	return decorator.render(decorator.PostStore.ArchivePost(ctx, id))
+}
+
+func (decorator *PostStore) RestorePostRevision(ctx context.Context, postID, revision int) (*model.Post, error) {
+	return decorator.render(decorator.PostStore.RestorePostRevision(ctx, postID, revision))
+}
+
+func (decorator *PostStore) RestorePost(ctx context.Context, id int) (*model.Post, error) {
+	return decorator.render(decorator.PostStore.RestorePost(ctx, id))
+}
+
+func (decorator *PostStore) render(post *model.Post, err error) (*model.Post, error) {
+	if err != nil {
+		return nil, err
+	}
+
+	if err = decorator.annotate(post); err != nil {
+		return nil, err
+	}
+
+	return post, nil
+}
+
+func (decorator *PostStore) renderAll(posts []*model.Post, err error) ([]*model.Post, error) {
+	if err != nil {
+		return nil, err
+	}
+
+	for _, post := range posts {
+		if err = decorator.annotate(post); err != nil {
+			return nil, err
+		}
+	}
+
+	return posts, nil
+}
+
+func (decorator *PostStore) annotate(post *model.Post) error {
+	rendered, err := decorator.renderer.Render(post.ID, post.Revision, post.Content)
+	if err != nil {
+		return fmt.Errorf("unable to render post [%d]: %s", post.ID, err.Error())
+	}
+
+	post.Excerpt = rendered.Excerpt
+	post.ReadingMinutes = rendered.ReadingMinutes
+
+	return nil
+}
@@ -0,0 +1,286 @@
+package store
+
+import (
+	"encoding/base64"
+	"html"
+	"sort"
+	"strconv"
+	"strings"
+	"unicode"
+
+	"redcellpartners.com/users-posts-api/model"
+)
+
+const (
+	// SEARCH_HIGHLIGHT_START and SEARCH_HIGHLIGHT_STOP surround the matched
+	// words in search snippets. They are the only markup in a snippet, the
+	// rest of it is HTML escaped.
+	SEARCH_HIGHLIGHT_START = "<mark>"
+	SEARCH_HIGHLIGHT_STOP  = "</mark>"
+
+	MAX_SEARCH_QUERY_LENGTH = 200
+
+	// SEARCH_SNIPPET_RUNES is about how much content a substring search
+	// snippet shows around the first match.
+	SEARCH_SNIPPET_RUNES = 160
+)
+
+// SearchCursor is the position of the last result of a search page, the next
+// page starts with the results ranked strictly lower. The id breaks ties
+// between results with the same rank.
+type SearchCursor struct {
+	Rank float32
+	ID   int
+}
+
+// SearchPage asks for at most Limit results following After, or the first
+// page when After is nil.
+type SearchPage struct {
+	After *SearchCursor
+	Limit int
+}
+
+// String encodes the cursor as an opaque, URL safe token.
+func (cursor SearchCursor) String() string {
+	return base64.RawURLEncoding.EncodeToString([]byte(strconv.FormatFloat(float64(cursor.Rank), 'g', -1, 32) + "," + strconv.Itoa(cursor.ID)))
+}
+
+// ParseSearchCursor decodes a token returned by SearchCursor.String.
+func ParseSearchCursor(token string) (*SearchCursor, error) {
+	decoded, err := base64.RawURLEncoding.DecodeString(token)
+	if err != nil {
+		return nil, ErrInvalidCursor
+	}
+
+	rank, id, found := strings.Cut(string(decoded), ",")
+	if !found {
+		return nil, ErrInvalidCursor
+	}
+
+	cursor := &SearchCursor{}
+
+	parsedRank, err := strconv.ParseFloat(rank, 32)
+	if err != nil {
+		return nil, ErrInvalidCursor
+	}
+
+	cursor.Rank = float32(parsedRank)
+
+	if cursor.ID, err = strconv.Atoi(id); err != nil {
+		return nil, ErrInvalidCursor
+	}
+
+	return cursor, nil
+}
+
+// searchQuery is a search split into the terms a post must contain and the
+// terms it must not, following the web search syntax of Postgres: quoted
+// phrases are a single term and a leading dash excludes a term.
+type searchQuery struct {
+	include []string
+	exclude []string
+}
+
+func parseSearchQuery(query string) searchQuery {
+	var (
+		parsed = searchQuery{}
+		rest   = strings.ToLower(query)
+	)
+
+	for {
+		rest = strings.TrimLeftFunc(rest, unicode.IsSpace)
+		if rest == "" {
+			return parsed
+		}
+
+		excluded := strings.HasPrefix(rest, "-")
+		if excluded {
+			rest = rest[1:]
+		}
+
+		var term string
+
+		if strings.HasPrefix(rest, `"`) {
+			phrase, after, _ := strings.Cut(rest[1:], `"`)
+			term, rest = strings.Join(strings.Fields(phrase), " "), after
+		} else if end := strings.IndexFunc(rest, unicode.IsSpace); end >= 0 {
+			term, rest = rest[:end], rest[end:]
+		} else {
+			term, rest = rest, ""
+		}
+
+		if term == "" {
+			continue
+		}
+
+		if excluded {
+			parsed.exclude = append(parsed.exclude, term)
+		} else {
+			parsed.include = append(parsed.include, term)
+		}
+	}
+}
+
+// SearchPostsBySubstring is the search of post stores without full text
+// search. It ranks the published posts containing every term of the query,
+// case insensitively, with a term in the title counting twice as much as
+// one in the content, and returns the page of them following the cursor.
+func SearchPostsBySubstring(posts []*model.Post, query string, page SearchPage) []*model.PostSearchResult {
+	parsed := parseSearchQuery(query)
+	if len(parsed.include) == 0 {
+		return []*model.PostSearchResult{}
+	}
+
+	results := make([]*model.PostSearchResult, 0)
+
+	for _, post := range posts {
+		if post.Status != POST_STATUS_PUBLISHED || post.DeletedTime != nil {
+			continue
+		}
+
+		if rank, ok := substringRank(post, parsed); ok {
+			results = append(results, &model.PostSearchResult{
+				Post:    post,
+				Rank:    rank,
+				Snippet: substringSnippet(post.Content, parsed.include),
+			})
+		}
+	}
+
+	sort.Slice(results, func(i, j int) bool {
+		if results[i].Rank != results[j].Rank {
+			return results[i].Rank > results[j].Rank
+		}
+
+		return results[i].Post.ID > results[j].Post.ID
+	})
+
+	if page.After != nil {
+		start := sort.Search(len(results), func(i int) bool {
+			return results[i].Rank < page.After.Rank || (results[i].Rank == page.After.Rank && results[i].Post.ID < page.After.ID)
+		})
+		results = results[start:]
+	}
+
+	if page.Limit > 0 && len(results) > page.Limit {
+		results = results[:page.Limit]
+	}
+
+	return results
+}
+
+func substringRank(post *model.Post, query searchQuery) (float32, bool) {
+	var (
+		title   = strings.ToLower(post.Title)
+		content = strings.ToLower(post.Content)
+		rank    float32
+	)
+
+	for _, term := range query.exclude {
+		if strings.Contains(title, term) || strings.Contains(content, term) {
+			return 0, false
+		}
+	}
+
+	for _, term := range query.include {
+		inTitle, inContent := strings.Contains(title, term), strings.Contains(content, term)
+		if !inTitle && !inContent {
+			return 0, false
+		}
+
+		if inTitle {
+			rank += 2
+		}
+
+		if inContent {
+			rank++
+		}
+	}
+
+	return rank / float32(3*len(query.include)), true
+}
+
+// substringSnippet cuts the content around the first term it contains and
+// highlights every term within the cut.
+func substringSnippet(content string, terms []string) string {
+	var (
+		runes = []rune(content)
+		lower = []rune(strings.ToLower(content))
+		start = 0
+	)
+
+	// lower casing can change the number of runes, in which case the
+	// snippet starts from the beginning rather than at a wrong offset
+	if len(lower) == len(runes) {
+		for _, term := range terms {
+			if at := strings.Index(string(lower), term); at >= 0 {
+				start = len([]rune(string(lower)[:at]))
+				break
+			}
+		}
+	}
+
+	start -= SEARCH_SNIPPET_RUNES / 4
+	if start < 0 {
+		start = 0
+	}
+
+	end := start + SEARCH_SNIPPET_RUNES
+	if end > len(runes) {
+		end = len(runes)
+	}
+
+	snippet := string(runes[start:end])
+	if start > 0 {
+		snippet = "..." + snippet
+	}
+
+	if end < len(runes) {
+		snippet += "..."
+	}
+
+	return highlight(snippet, terms)
+}
+
+// highlight HTML escapes the text and marks every term in it, so the mark
+// tags are the only markup in the result.
+func highlight(text string, terms []string) string {
+	var (
+		lower       = strings.ToLower(text)
+		highlighted strings.Builder
+	)
+
+	// highlighting needs byte offsets in the lower cased text to match the
+	// original text
+	if len(lower) != len(text) {
+		return html.EscapeString(text)
+	}
+
+	unmatched := 0
+
+	for i := 0; i < len(text); {
+		matched := 0
+
+		for _, term := range terms {
+			if len(term) > matched && strings.HasPrefix(lower[i:], term) {
+				matched = len(term)
+			}
+		}
+
+		if matched == 0 {
+			i++
+			continue
+		}
+
+		highlighted.WriteString(html.EscapeString(text[unmatched:i]))
+		highlighted.WriteString(SEARCH_HIGHLIGHT_START)
+		highlighted.WriteString(html.EscapeString(text[i : i+matched]))
+		highlighted.WriteString(SEARCH_HIGHLIGHT_STOP)
+		i += matched
+		unmatched = i
+	}
+
+	highlighted.WriteString(html.EscapeString(text[unmatched:]))
+
+	return highlighted.String()
+}
@@ -0,0 +1,165 @@
+package store
+
+import (
+	"encoding/base64"
+	"fmt"
+	"strings"
+	"testing"
+
+	"redcellpartners.com/users-posts-api/model"
+)
+
+func TestSearchCursorRoundTrip(t *testing.T) {
+	tests := []struct {
+		name   string
+		cursor SearchCursor
+	}{
+		{name: "fractional rank", cursor: SearchCursor{Rank: 0.0759, ID: 42}},
+		{name: "zero rank", cursor: SearchCursor{Rank: 0, ID: 1}},
+		{name: "tiny rank", cursor: SearchCursor{Rank: 1e-20, ID: 7}},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			parsed, err := ParseSearchCursor(test.cursor.String())
+			if err != nil {
+				t.Fatalf("unexpected error: %s", err.Error())
+			}
+
+			// the rank must survive exactly or the next page would skip or
+			// repeat results with the same rank
+			if *parsed != test.cursor {
+				t.Errorf("expected %v, got %v", test.cursor, *parsed)
+			}
+		})
+	}
+}
+
+func TestParseSearchCursorRejectsInvalidTokens(t *testing.T) {
+	encode := func(value string) string {
+		return base64.RawURLEncoding.EncodeToString([]byte(value))
+	}
+
+	tests := []struct {
+		name  string
+		token string
+	}{
+		{name: "not base64", token: "not a cursor!"},
+		{name: "no separator", token: encode("0.5")},
+		{name: "bad rank", token: encode("high,1")},
+		{name: "bad id", token: encode("0.5,one")},
+		{name: "feed cursor", token: Cursor{ID: 1}.String()},
+		{name: "empty", token: encode("")},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			if cursor, err := ParseSearchCursor(test.token); err != ErrInvalidCursor {
+				t.Errorf("expected ErrInvalidCursor, got %v, %v", cursor, err)
+			}
+		})
+	}
+}
+
+func TestSearchPostsBySubstring(t *testing.T) {
+	posts := []*model.Post{
+		{ID: 1, Title: "Postgres tips", Content: "Full text search in POSTGRES.", Status: POST_STATUS_PUBLISHED},
+		{ID: 2, Title: "Databases", Content: "MySQL and postgres compared.", Status: POST_STATUS_PUBLISHED},
+		{ID: 3, Title: "Drafts", Content: "postgres draft", Status: POST_STATUS_DRAFT},
+		{ID: 4, Title: "Go", Content: "Nothing to see.", Status: POST_STATUS_PUBLISHED},
+	}
+
+	tests := []struct {
+		name     string
+		query    string
+		expected []int
+	}{
+		{name: "case insensitive, title matches first", query: "PostGres", expected: []int{1, 2}},
+		{name: "title only", query: "tips", expected: []int{1}},
+		{name: "excluded term", query: "postgres -mysql", expected: []int{1}},
+		{name: "phrase", query: `"full   text"`, expected: []int{1}},
+		{name: "every term must match", query: "postgres go", expected: []int{}},
+		{name: "only excluded terms", query: "-mysql", expected: []int{}},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			results := SearchPostsBySubstring(posts, test.query, SearchPage{Limit: 10})
+
+			ids := make([]int, 0, len(results))
+			for _, result := range results {
+				ids = append(ids, result.Post.ID)
+			}
+
+			if fmt.Sprint(ids) != fmt.Sprint(test.expected) {
+				t.Errorf("expected posts %v, got %v", test.expected, ids)
+			}
+		})
+	}
+}
+
+func TestSearchPostsBySubstringPages(t *testing.T) {
+	posts := make([]*model.Post, 0)
+	for id := 1; id <= 5; id++ {
+		posts = append(posts, &model.Post{ID: id, Title: "Post", Content: "same words", Status: POST_STATUS_PUBLISHED})
+	}
+
+	// the title match ranks post 6 first, the others tie and come newest id
+	// first
+	posts = append(posts, &model.Post{ID: 6, Title: "Words", Content: "same words", Status: POST_STATUS_PUBLISHED})
+
+	var (
+		page = SearchPage{Limit: 2}
+		ids  = make([]int, 0)
+	)
+
+	for pages := 0; pages < 10; pages++ {
+		results := SearchPostsBySubstring(posts, "words", page)
+
+		for _, result := range results {
+			ids = append(ids, result.Post.ID)
+		}
+
+		if len(results) < page.Limit {
+			break
+		}
+
+		last := results[len(results)-1]
+
+		// the cursor goes through its token like it does between requests
+		cursor, err := ParseSearchCursor(SearchCursor{Rank: last.Rank, ID: last.Post.ID}.String())
+		if err != nil {
+			t.Fatalf("unexpected error: %s", err.Error())
+		}
+
+		page.After = cursor
+	}
+
+	if expected := []int{6, 5, 4, 3, 2, 1}; fmt.Sprint(ids) != fmt.Sprint(expected) {
+		t.Errorf("expected posts %v across the pages, got %v", expected, ids)
+	}
+}
+
+func TestSubstringSnippet(t *testing.T) {
+	tests := []struct {
+		name     string
+		content  string
+		terms    []string
+		expected string
+	}{
+		{name: "matches are marked", content: "Full text search in Postgres", terms: []string{"postgres"}, expected: "Full text search in <mark>Postgres</mark>"},
+		{name: "script is escaped", content: "<script>alert(1)</script> postgres", terms: []string{"postgres"}, expected: "&lt;script&gt;alert(1)&lt;/script&gt; <mark>postgres</mark>"},
+		{name: "matched markup is escaped", content: "a <b> tag", terms: []string{"<b>"}, expected: "a <mark>&lt;b&gt;</mark> tag"},
+		{name: "mark tags in the content are escaped", content: "<mark>fake</mark> real", terms: []string{"real"}, expected: "&lt;mark&gt;fake&lt;/mark&gt; <mark>real</mark>"},
+		{name: "unmatched content is escaped", content: `"quoted" & more`, terms: []string{"missing"}, expected: "&#34;quoted&#34; &amp; more"},
+		{name: "long content is cut around the match", content: strings.Repeat("a ", 100) + "postgres" + strings.Repeat(" b", 100), terms: []string{"postgres"}, expected: "..." + strings.Repeat("a ", 20) + "<mark>postgres</mark>" + strings.Repeat(" b", 56) + "..."},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			if snippet := substringSnippet(test.content, test.terms); snippet != test.expected {
+				t.Errorf("expected %q, got %q", test.expected, snippet)
+			}
+		})
+	}
+}
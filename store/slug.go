@@ -0,0 +1,64 @@
+package store
+
+import (
+	"strings"
+	"unicode"
+
+	"golang.org/x/text/unicode/norm"
+)
+
+const MAX_SLUG_LENGTH = 80
+
+// Slugify turns a post title into the readable part of its URL: lower case
+// ASCII letters and digits with every run of anything else replaced by a
+// single dash, so "Hello, World!" becomes "hello-world". Accents are dropped
+// rather than the accented letters. A title with no letters or digits gives
+// "post".
+func Slugify(title string) string {
+	var (
+		slug strings.Builder
+		dash bool
+	)
+
+	for _, r := range norm.NFD.String(strings.ToLower(title)) {
+		if unicode.Is(unicode.Mn, r) {
+			continue
+		}
+
+		if (r >= 'a' && r <= 'z') || (r >= '0' && r <= '9') {
+			if dash && slug.Len() > 0 {
+				slug.WriteByte('-')
+			}
+
+			slug.WriteRune(r)
+			dash = false
+
+			if slug.Len() >= MAX_SLUG_LENGTH {
+				break
+			}
+
+			continue
+		}
+
+		dash = true
+	}
+
+	if slug.Len() == 0 {
+		return "post"
+	}
+
+	return slug.String()
+}
+
+// SlugRoot strips every trailing numeric suffix from a slug, so a slug and
+// all the suffixed slugs that could collide with it share a root.
+func SlugRoot(slug string) string {
+	for {
+		at := strings.LastIndexByte(slug, '-')
+		if at <= 0 || strings.Trim(slug[at+1:], "0123456789") != "" {
+			return slug
+		}
+
+		slug = slug[:at]
+	}
+}
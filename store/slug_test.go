@@ -0,0 +1,55 @@
+package store
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestSlugify(t *testing.T) {
+	tests := []struct {
+		name     string
+		title    string
+		expected string
+	}{
+		{name: "punctuation", title: "Hello, World!", expected: "hello-world"},
+		{name: "accents are dropped", title: "Crème brûlée, explained", expected: "creme-brulee-explained"},
+		{name: "runs collapse to one dash", title: "  Go --- and   Postgres  ", expected: "go-and-postgres"},
+		{name: "digits are kept", title: "Top 10 tips for 2024", expected: "top-10-tips-for-2024"},
+		{name: "other scripts are dropped", title: "Привет world", expected: "world"},
+		{name: "nothing left", title: "!!! ???", expected: "post"},
+		{name: "empty", title: "", expected: "post"},
+		{name: "cut at the maximum length", title: strings.Repeat("a", MAX_SLUG_LENGTH+10), expected: strings.Repeat("a", MAX_SLUG_LENGTH)},
+		{name: "no trailing dash when cut", title: strings.Repeat("a", MAX_SLUG_LENGTH) + " b", expected: strings.Repeat("a", MAX_SLUG_LENGTH)},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			if slug := Slugify(test.title); slug != test.expected {
+				t.Errorf("expected %q, got %q", test.expected, slug)
+			}
+		})
+	}
+}
+
+func TestSlugRoot(t *testing.T) {
+	tests := []struct {
+		slug     string
+		expected string
+	}{
+		{slug: "hello", expected: "hello"},
+		{slug: "hello-2", expected: "hello"},
+		{slug: "hello-2-3", expected: "hello"},
+		{slug: "top-10", expected: "top"},
+		{slug: "hello-world", expected: "hello-world"},
+		{slug: "2024", expected: "2024"},
+		{slug: "2024-2", expected: "2024"},
+	}
+
+	for _, test := range tests {
+		t.Run(test.slug, func(t *testing.T) {
+			if root := SlugRoot(test.slug); root != test.expected {
+				t.Errorf("expected %q, got %q", test.expected, root)
+			}
+		})
+	}
+}
@@ -0,0 +1,32 @@
+package substring
+
+import (
+	"context"
+
+	"redcellpartners.com/users-posts-api/model"
+	"redcellpartners.com/users-posts-api/store"
+)
+
+var _ store.PostStore = &PostStore{}
+
+// PostStore searches the published posts the wrapped post store lists by case
+// insensitive substring, for post stores without full text search. Only the
+// posts a single ListPosts call returns are searched.
+type PostStore struct {
+	store.PostStore
+}
+
+func NewPostStore(next store.PostStore) *PostStore {
+	return &PostStore{
+		PostStore: next,
+	}
+}
+
+func (decorator *PostStore) SearchPosts(ctx context.Context, query string, page store.SearchPage) ([]*model.PostSearchResult, error) {
+	posts, err := decorator.PostStore.ListPosts(ctx, store.PostFilter{Statuses: []string{store.POST_STATUS_PUBLISHED}})
+	if err != nil {
+		return nil, err
+	}
+
+	return store.SearchPostsBySubstring(posts, query, page), nil
+}
@@ -0,0 +1,56 @@
+package store
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+	"unicode/utf8"
+)
+
+const (
+	MAX_TAG_LENGTH    = 50
+	MAX_TAGS_PER_POST = 10
+)
+
+// NormalizeTag lower cases a tag and joins its words with dashes, so
+// " Go  Lang" and "go-lang" are the same tag.
+func NormalizeTag(tag string) string {
+	return strings.Join(strings.Fields(strings.ToLower(tag)), "-")
+}
+
+// NormalizeTags normalizes, de-duplicates and sorts tags, rejecting empty or
+// overly long tags and more than MAX_TAGS_PER_POST of them. A nil slice stays
+// nil so callers can tell "no tags given" from "no tags".
+func NormalizeTags(tags []string) ([]string, error) {
+	if tags == nil {
+		return nil, nil
+	}
+
+	seen := make(map[string]bool, len(tags))
+	normalized := make([]string, 0, len(tags))
+
+	for _, tag := range tags {
+		tag = NormalizeTag(tag)
+
+		if tag == "" {
+			return nil, fmt.Errorf("tags must not be empty")
+		}
+
+		if utf8.RuneCountInString(tag) > MAX_TAG_LENGTH {
+			return nil, fmt.Errorf("tag %q is longer than %d characters", tag, MAX_TAG_LENGTH)
+		}
+
+		if !seen[tag] {
+			seen[tag] = true
+			normalized = append(normalized, tag)
+		}
+	}
+
+	if len(normalized) > MAX_TAGS_PER_POST {
+		return nil, fmt.Errorf("a post can have at most %d tags", MAX_TAGS_PER_POST)
+	}
+
+	sort.Strings(normalized)
+
+	return normalized, nil
+}
@@ -0,0 +1,59 @@
+package store
+
+import (
+	"fmt"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestNormalizeTags(t *testing.T) {
+	tests := []struct {
+		name     string
+		tags     []string
+		expected []string
+		wantErr  bool
+	}{
+		{name: "nil stays nil", tags: nil, expected: nil},
+		{name: "empty stays empty", tags: []string{}, expected: []string{}},
+		{name: "lower cased and dashed", tags: []string{" Go  Lang", "Postgres"}, expected: []string{"go-lang", "postgres"}},
+		{name: "de-duplicated after normalizing", tags: []string{"go-lang", "Go Lang", "GO-LANG"}, expected: []string{"go-lang"}},
+		{name: "sorted", tags: []string{"zig", "ada", "go"}, expected: []string{"ada", "go", "zig"}},
+		{name: "longest tag", tags: []string{strings.Repeat("é", MAX_TAG_LENGTH)}, expected: []string{strings.Repeat("é", MAX_TAG_LENGTH)}},
+		{name: "most tags counted after de-duplicating", tags: append(numberedTags(MAX_TAGS_PER_POST), "Tag 00"), expected: numberedTags(MAX_TAGS_PER_POST)},
+		{name: "blank tag", tags: []string{"go", "  "}, wantErr: true},
+		{name: "too long", tags: []string{strings.Repeat("a", MAX_TAG_LENGTH+1)}, wantErr: true},
+		{name: "too many", tags: numberedTags(MAX_TAGS_PER_POST + 1), wantErr: true},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			tags, err := NormalizeTags(test.tags)
+			if test.wantErr {
+				if err == nil {
+					t.Fatalf("expected an error, got %v", tags)
+				}
+
+				return
+			}
+
+			if err != nil {
+				t.Fatalf("unexpected error: %s", err.Error())
+			}
+
+			if !reflect.DeepEqual(tags, test.expected) {
+				t.Errorf("expected %#v, got %#v", test.expected, tags)
+			}
+		})
+	}
+}
+
+func numberedTags(count int) []string {
+	tags := make([]string, 0, count)
+
+	for i := 0; i < count; i++ {
+		tags = append(tags, fmt.Sprintf("tag-%02d", i))
+	}
+
+	return tags
+}
@@ -1,13 +1,45 @@
 package store
 
 import (
+	"context"
+	"errors"
+	"time"
+
 	"redcellpartners.com/users-posts-api/model"
 )
 
+var (
+	ErrHandleTaken = errors.New("handle is already taken")
+	ErrEmailTaken  = errors.New("email is already taken")
+)
+
+// UserFilter narrows ListUsers. Deleted users are only listed with
+// IncludeDeleted.
+type UserFilter struct {
+	IncludeDeleted bool
+}
+
+// UserStore reads and writes users. Deleting a user soft deletes it along
+// with its posts, reads leave deleted users out and return sql.ErrNoRows for
+// them until they are restored or purged.
 type UserStore interface {
-	ListUsers() ([]*model.User, error)
-	CreateUser(*model.User) (*model.User, error)
-	GetUser(id int) (*model.User, error)
-	UpdateUser(user *model.User) (*model.User, error)
-	DeleteUser(id int) error
+	ListUsers(ctx context.Context, filter UserFilter) ([]*model.User, error)
+	CreateUser(ctx context.Context, user *model.User) (*model.User, error)
+	GetUser(ctx context.Context, id int) (*model.User, error)
+	GetUserByEmail(ctx context.Context, email string) (*model.User, error)
+	// GetUserByHandle finds a user by handle, case insensitively.
+	GetUserByHandle(ctx context.Context, handle string) (*model.User, error)
+	// CreateUser and UpdateUser return ErrHandleTaken when another user,
+	// deleted or not, has the handle, and ErrEmailTaken when one has the
+	// email. Handles are set as given, an empty one clears the user's handle.
+	UpdateUser(ctx context.Context, user *model.User) (*model.User, error)
+	DeleteUser(ctx context.Context, id int) error
+	// RestoreUser brings back a deleted user and the posts deleted with it,
+	// but not the posts deleted on their own before. It returns
+	// sql.ErrNoRows when there is no deleted user with the id.
+	RestoreUser(ctx context.Context, id int) (*model.User, error)
+	// PurgeDeletedUsers permanently deletes up to limit users deleted before
+	// the cutoff, with everything that belongs to them, and returns how many
+	// it purged.
+	PurgeDeletedUsers(ctx context.Context, cutoff time.Time, limit int) (int, error)
 }
@@ -0,0 +1,60 @@
+package tracing
+
+import (
+	"context"
+	"fmt"
+
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/exporters/otlp/otlptrace/otlptracehttp"
+	"go.opentelemetry.io/otel/propagation"
+	"go.opentelemetry.io/otel/sdk/resource"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/trace"
+)
+
+const (
+	ExporterNone = "none"
+	ExporterOTLP = "otlp"
+)
+
+// NewTracerProvider builds a tracer provider that hands finished spans to the
+// processor. Production code uses a batching processor around the OTLP
+// exporter, tests can pass sdktrace.NewSimpleSpanProcessor wrapping a
+// tracetest.InMemoryExporter and assert on the recorded spans.
+func NewTracerProvider(processor sdktrace.SpanProcessor, serviceName string, sampleRatio float64) *sdktrace.TracerProvider {
+	return sdktrace.NewTracerProvider(
+		sdktrace.WithSpanProcessor(processor),
+		sdktrace.WithSampler(sdktrace.ParentBased(sdktrace.TraceIDRatioBased(sampleRatio))),
+		sdktrace.WithResource(resource.NewSchemaless(attribute.String("service.name", serviceName))),
+	)
+}
+
+// NewOTLPProcessor returns a batching span processor exporting to an OTLP
+// HTTP collector at endpoint (host:port).
+func NewOTLPProcessor(ctx context.Context, endpoint string, insecure bool) (sdktrace.SpanProcessor, error) {
+	options := []otlptracehttp.Option{
+		otlptracehttp.WithEndpoint(endpoint),
+	}
+
+	if insecure {
+		options = append(options, otlptracehttp.WithInsecure())
+	}
+
+	exporter, err := otlptracehttp.New(ctx, options...)
+	if err != nil {
+		return nil, fmt.Errorf("unable to create otlp exporter: %s", err.Error())
+	}
+
+	return sdktrace.NewBatchSpanProcessor(exporter), nil
+}
+
+// Install makes the provider the global tracer provider used by the HTTP
+// middleware and the stores, and honors W3C traceparent and baggage headers.
+func Install(provider trace.TracerProvider) {
+	otel.SetTracerProvider(provider)
+	otel.SetTextMapPropagator(propagation.NewCompositeTextMapPropagator(
+		propagation.TraceContext{},
+		propagation.Baggage{},
+	))
+}
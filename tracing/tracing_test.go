@@ -0,0 +1,230 @@
+package tracing_test
+
+import (
+	"context"
+	"database/sql"
+	"database/sql/driver"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/go-chi/chi"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+	"go.opentelemetry.io/otel/trace"
+	"go.uber.org/zap"
+	"redcellpartners.com/users-posts-api/markdown"
+	"redcellpartners.com/users-posts-api/metrics"
+	"redcellpartners.com/users-posts-api/middleware"
+	"redcellpartners.com/users-posts-api/routes"
+	"redcellpartners.com/users-posts-api/store/instrumented"
+	"redcellpartners.com/users-posts-api/store/postgres"
+	"redcellpartners.com/users-posts-api/tracing"
+)
+
+const (
+	traceID      = "4bf92f3577b34da6a3ce929d0e0e4736"
+	parentSpanID = "00f067aa0ba902b7"
+)
+
+func TestRequestSpans(t *testing.T) {
+	exporter := tracetest.NewInMemoryExporter()
+	provider := tracing.NewTracerProvider(sdktrace.NewSimpleSpanProcessor(exporter), "users-posts-api-test", 1)
+	defer provider.Shutdown(context.Background())
+
+	tracing.Install(provider)
+
+	db := sql.OpenDB(fakeConnector{})
+	defer db.Close()
+
+	postClient, err := postgres.NewPostgresPostClient(db, 0, zap.NewNop())
+	if err != nil {
+		t.Fatalf("unable to create post client: %s", err.Error())
+	}
+
+	defer postClient.Close()
+
+	postStore := instrumented.NewPostStore(postClient, metrics.New())
+
+	postsResource := routes.NewPostsResource(
+		postStore,
+		markdown.NewRenderer(0),
+		routes.NewCommentsResource(nil, zap.NewNop()),
+		routes.NewReactionsResource(nil, zap.NewNop()),
+		routes.NewRevisionsResource(postStore, zap.NewNop()),
+		zap.NewNop(),
+	)
+
+	router := chi.NewRouter()
+	router.Use(middleware.TracingMiddleware)
+	router.Mount("/posts", postsResource.Routes())
+
+	r := httptest.NewRequest("GET", "/posts/1", nil)
+	r.Header.Set("traceparent", "00-"+traceID+"-"+parentSpanID+"-01")
+
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, r)
+
+	if w.Code != http.StatusOK {
+		t.Fatalf("expected status 200, got %d: %s", w.Code, w.Body.String())
+	}
+
+	spans := exporter.GetSpans()
+
+	var server *tracetest.SpanStub
+
+	for i := range spans {
+		if spans[i].SpanKind == trace.SpanKindServer {
+			server = &spans[i]
+		}
+	}
+
+	if server == nil {
+		t.Fatalf("expected a server span, got %v", spanNames(spans))
+	}
+
+	if server.Name != "GET /posts/{id}/" {
+		t.Errorf("expected the server span to be named by route pattern, got %q", server.Name)
+	}
+
+	if server.Parent.SpanID().String() != parentSpanID || !server.Parent.IsRemote() {
+		t.Errorf("expected the server span to continue the traceparent span %s, got parent %s", parentSpanID, server.Parent.SpanID())
+	}
+
+	// the post exists middleware and the handler both get the post
+	storeSpans := childrenNamed(spans, server.SpanContext, "post.GetPost")
+	if len(storeSpans) != 2 {
+		t.Fatalf("expected 2 post.GetPost spans under the server span, got spans %v", spanNames(spans))
+	}
+
+	for _, storeSpan := range storeSpans {
+		statementSpans := childrenNamed(spans, storeSpan.SpanContext, "posts.get")
+		if len(statementSpans) != 1 {
+			t.Fatalf("expected a posts.get span under post.GetPost, got spans %v", spanNames(spans))
+		}
+
+		if statementSpans[0].SpanKind != trace.SpanKindClient {
+			t.Errorf("expected the statement span to be a client span, got %s", statementSpans[0].SpanKind)
+		}
+	}
+
+	for _, span := range spans {
+		if span.SpanContext.TraceID().String() != traceID {
+			t.Errorf("expected span %q to be in trace %s, got %s", span.Name, traceID, span.SpanContext.TraceID())
+		}
+	}
+}
+
+func childrenNamed(spans tracetest.SpanStubs, parent trace.SpanContext, name string) []tracetest.SpanStub {
+	children := make([]tracetest.SpanStub, 0)
+
+	for _, span := range spans {
+		if span.Name == name && span.Parent.SpanID() == parent.SpanID() {
+			children = append(children, span)
+		}
+	}
+
+	return children
+}
+
+func spanNames(spans tracetest.SpanStubs) []string {
+	names := make([]string, 0, len(spans))
+
+	for _, span := range spans {
+		names = append(names, span.Name)
+	}
+
+	return names
+}
+
+// fakeConnector is a database that accepts every statement, finds post 1 and
+// nothing else, so the post store runs its real statements without postgres.
+type fakeConnector struct{}
+
+func (connector fakeConnector) Connect(context.Context) (driver.Conn, error) {
+	return fakeConn{}, nil
+}
+
+func (connector fakeConnector) Driver() driver.Driver {
+	return nil
+}
+
+type fakeConn struct{}
+
+func (conn fakeConn) Prepare(query string) (driver.Stmt, error) {
+	return fakeStmt{query: query}, nil
+}
+
+func (conn fakeConn) Close() error {
+	return nil
+}
+
+func (conn fakeConn) Begin() (driver.Tx, error) {
+	return fakeTx{}, nil
+}
+
+type fakeTx struct{}
+
+func (tx fakeTx) Commit() error {
+	return nil
+}
+
+func (tx fakeTx) Rollback() error {
+	return nil
+}
+
+type fakeStmt struct {
+	query string
+}
+
+func (stmt fakeStmt) Close() error {
+	return nil
+}
+
+func (stmt fakeStmt) NumInput() int {
+	return -1
+}
+
+func (stmt fakeStmt) Exec(args []driver.Value) (driver.Result, error) {
+	return driver.RowsAffected(0), nil
+}
+
+func (stmt fakeStmt) Query(args []driver.Value) (driver.Rows, error) {
+	if !strings.Contains(stmt.query, "FROM posts WHERE id = $1") || len(args) != 1 || args[0] != int64(1) {
+		return &fakeRows{}, nil
+	}
+
+	return &fakeRows{
+		columns: []string{"id", "user_id", "title", "slug", "content", "status", "published_at", "created_at", "updated_at", "deleted_at", "tags", "reactions", "revision"},
+		values: [][]driver.Value{
+			{int64(1), int64(1), "Hello", "hello", "Hello *world*", "published", nil, time.Now(), nil, nil, []byte("{}"), []byte("{}"), int64(1)},
+		},
+	}, nil
+}
+
+type fakeRows struct {
+	columns []string
+	values  [][]driver.Value
+}
+
+func (rows *fakeRows) Columns() []string {
+	return rows.columns
+}
+
+func (rows *fakeRows) Close() error {
+	return nil
+}
+
+func (rows *fakeRows) Next(dest []driver.Value) error {
+	if len(rows.values) == 0 {
+		return io.EOF
+	}
+
+	copy(dest, rows.values[0])
+	rows.values = rows.values[1:]
+
+	return nil
+}